@@ -1,13 +1,22 @@
 package main
 
 import (
+	"TimBerk/gophermart/internal/app/client"
+	"TimBerk/gophermart/internal/app/handlers"
+	"TimBerk/gophermart/internal/app/health"
+	"TimBerk/gophermart/internal/app/keys"
+	"TimBerk/gophermart/internal/app/metrics"
 	"TimBerk/gophermart/internal/app/settings/config"
 	"TimBerk/gophermart/internal/app/settings/logger"
 	"TimBerk/gophermart/internal/app/settings/router"
 	"TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/internal/app/tracing"
 	"TimBerk/gophermart/internal/app/worker"
 	"context"
 	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
 	"net/http"
 	"os"
 	"os/signal"
@@ -16,35 +25,102 @@ import (
 	"time"
 )
 
+// appStore is served by the handlers and polled by the background workers.
+type appStore interface {
+	handlers.Store
+	worker.OrderStore
+	worker.IdempotencyStore
+}
+
+func initStore(cfg *config.Config) (appStore, error) {
+	switch cfg.StoreType {
+	case config.StoreMemory:
+		logger.Log.Warn("Using in-memory store: data will be lost on restart")
+		return store.NewMemoryStore(), nil
+	case config.StorePostgres, "":
+		return store.NewPostgresStore(cfg)
+	default:
+		return nil, fmt.Errorf("unknown store type %q", cfg.StoreType)
+	}
+}
+
+// healthStore is implemented by both stores, it is checked by the readiness probe.
+type healthStore interface {
+	Ping(ctx context.Context) error
+	CheckMigrations(ctx context.Context) error
+}
+
+func initProbes(cfg *config.Config, dataStore handlers.Store, heartbeat *worker.Heartbeat) *health.Checker {
+	probes := health.NewChecker()
+	if db, ok := dataStore.(healthStore); ok {
+		probes.Add("database", db.Ping)
+		probes.Add("migrations", db.CheckMigrations)
+	}
+	probes.Add("accrual_worker", heartbeat.Check(time.Duration(cfg.HeartbeatTimeout)*time.Second))
+	if cfg.ReadyCheckAccrual {
+		probes.Add("accrual_system", client.NewClient(cfg.AccrualSystemAddress).Ping)
+	}
+	return probes
+}
+
 func main() {
 	ctx := context.Background()
 	cfg := config.NewConfig()
-	logger.Initialize(cfg.LogLevel)
+	if err := logger.Initialize(cfg.LogLevel, cfg.LogFormat); err != nil {
+		logrus.Fatal("Init logger: ", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		logger.Log.Fatal("Invalid config: ", err)
+	}
+
+	// Log entries made with the context of a span carry its trace ID
+	logrus.AddHook(tracing.LogHook{})
+	logger.Log.AddHook(tracing.LogHook{})
+
+	shutdownTracing, err := tracing.Init(ctx, cfg)
+	if err != nil {
+		logger.Log.Fatal("Init tracing: ", err)
+	}
 
-	pgStore, err := store.NewPostgresStore(cfg)
+	dataStore, err := initStore(cfg)
 	if err != nil {
 		logger.Log.Fatal("Read Store: ", err)
 	}
 
+	// Pool stats and the order backlog are read from the store on each scrape
+	if collector, ok := dataStore.(prometheus.Collector); ok {
+		metrics.Registry.MustRegister(collector)
+	}
+
+	keySet, err := keys.Load(cfg)
+	if err != nil {
+		logger.Log.Fatal("Read JWT keys: ", err)
+	}
+
 	// Workers for updating order status
 	workerUpdateCtx, cancelUpdate := context.WithCancel(ctx)
 	defer cancelUpdate()
 
+	heartbeat := &worker.Heartbeat{}
 	var wgBackgroud sync.WaitGroup
 	wgBackgroud.Add(1)
-	go worker.UpdateStateOrders(workerUpdateCtx, cfg, pgStore, &wgBackgroud)
+	go worker.UpdateStateOrders(workerUpdateCtx, cfg, dataStore, heartbeat, &wgBackgroud)
+	wgBackgroud.Add(1)
+	go worker.PurgeIdempotencyKeys(workerUpdateCtx, cfg, dataStore, &wgBackgroud)
+
+	probes := initProbes(cfg, dataStore, heartbeat)
 
 	// Create a channel to listen for shutdown signals
 	shutdownChan := make(chan os.Signal, 1)
 	signal.Notify(shutdownChan, os.Interrupt, syscall.SIGTERM)
 
-	router := router.InitRouter(pgStore, cfg, ctx)
+	router := router.InitRouter(dataStore, cfg, keySet, probes)
 	server := &http.Server{
 		Addr:    cfg.RunAddress,
 		Handler: router,
 	}
 
-	wgBackgroud.Add(2)
+	wgBackgroud.Add(1)
 	go func() {
 		defer wgBackgroud.Done()
 		logger.Log.WithField("address", cfg.RunAddress).Info("Starting server")
@@ -56,14 +132,23 @@ func main() {
 	<-shutdownChan
 	logger.Log.Info("Shutdown signal received")
 
+	// Fail readiness first, so load balancers stop sending requests before the listener is closed
+	probes.Shutdown()
+	if cfg.ShutdownDelay > 0 {
+		time.Sleep(time.Duration(cfg.ShutdownDelay) * time.Second)
+	}
+
 	// Create a context with timeout for shutdown
 	ctxShutdown, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	if errShutdown := server.Shutdown(ctxShutdown); err != nil {
+	if errShutdown := server.Shutdown(ctxShutdown); errShutdown != nil {
 		logger.Log.WithField("error", errShutdown).Fatal("Server shutdown error")
 	}
 
+	// Stop accrual workers, requests in flight are cancelled
+	cancelUpdate()
+
 	// Wait for all goroutines to complete
 	done := make(chan struct{})
 	go func() {
@@ -79,5 +164,10 @@ func main() {
 		logger.Log.Info("Timeout waiting for goroutines to finish")
 	}
 
+	// Export spans finished before the shutdown
+	if errTracing := shutdownTracing(ctxShutdown); errTracing != nil {
+		logger.Log.WithField("error", errTracing).Error("Tracing shutdown error")
+	}
+
 	logger.Log.Info("Server exited properly")
 }
@@ -1,22 +1,48 @@
 package client
 
 import (
+	"TimBerk/gophermart/internal/app/metrics"
 	model "TimBerk/gophermart/internal/app/models/order"
+	"TimBerk/gophermart/internal/app/tracing"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
-const checkOrderURI string = "/api/orders/"
+const (
+	checkOrderURI     string = "/api/orders/"
+	requestTimeout           = 10 * time.Second
+	defaultRetryAfter        = time.Minute
+)
+
+type APIError struct {
+	Message    string
+	RetryAfter time.Duration
+	StatusCode int
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
 
 type Client struct {
-	url string
+	url        string
+	httpClient *http.Client
 }
 
 func NewClient(baseURL string) *Client {
-	return &Client{url: baseURL}
+	return &Client{url: baseURL, httpClient: &http.Client{Timeout: requestTimeout}}
 }
 
 func (c Client) getFullPath(path string) (string, error) {
@@ -30,24 +56,91 @@ func (c Client) getFullPath(path string) (string, error) {
 	return parsedURL.String(), nil
 }
 
-func (c Client) GetStatus(order string) (*model.OrderAccrual, error) {
+// parseRetryAfter accepts both forms of the header: delay in seconds and HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return defaultRetryAfter
+}
+
+// Ping checks that the accrual system answers. Any answer but a server error counts,
+// the root path is not a part of the accrual API.
+func (c Client) Ping(ctx context.Context) error {
+	fullPath, err := c.getFullPath("/")
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullPath, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("accrual system answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetStatus asks the accrual system about the order. The request is traced as a client span
+// and carries the traceparent header, so the accrual system can continue the trace.
+func (c Client) GetStatus(ctx context.Context, order string) (*model.OrderAccrual, error) {
 	action := "C.GetStatus"
 
+	ctx, span := tracing.Tracer().Start(ctx, "accrual GetStatus",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("order.number", order)),
+	)
+	defer span.End()
+
 	fullPath, err := c.getFullPath(checkOrderURI + order)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{"action": "C.Register", "order": order, "error": err}).Error("failed to build path")
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "C.Register", "order": order, "error": err}).Error("failed to build path")
 		return nil, err
 	}
 
-	resp, err := http.Get(fullPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullPath, nil)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{"action": action, "order": order, "error": err}).Error("failed to send request")
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": action, "order": order, "error": err}).Error("failed to build request")
+		return nil, err
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveAccrual(start, metrics.AccrualError)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": action, "order": order, "error": err}).Error("failed to send request")
 		return nil, err
 	}
 	defer resp.Body.Close()
+	metrics.ObserveAccrual(start, metrics.AccrualOutcome(resp.StatusCode))
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 
-	logrus.WithFields(logrus.Fields{"action": action, "order": order, "response": resp, "body": resp.Body}).Info("get info about order")
+	logrus.WithContext(ctx).WithFields(logrus.Fields{"action": action, "order": order, "status": resp.StatusCode}).Info("get info about order")
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &APIError{
+			Message:    "too many requests",
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			StatusCode: resp.StatusCode,
+		}
+	}
 	if resp.StatusCode > 202 {
 		return nil, fmt.Errorf("order not ready")
 	}
@@ -57,7 +150,7 @@ func (c Client) GetStatus(order string) (*model.OrderAccrual, error) {
 	var orderAccrual model.OrderAccrual
 	err = decoder.Decode(&orderAccrual)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{"action": action, "order": order, "error": err}).Error("failed to decode response")
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": action, "order": order, "error": err}).Error("failed to decode response")
 		return nil, err
 	}
 
@@ -2,12 +2,19 @@ package client
 
 import (
 	model "TimBerk/gophermart/internal/app/models/order"
+	"TimBerk/gophermart/pkg/money"
+	"TimBerk/gophermart/pkg/utils"
+	"context"
 	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestNewClient(t *testing.T) {
@@ -44,6 +51,7 @@ func TestGetStatus(t *testing.T) {
 		orderID        string
 		mockResponse   interface{}
 		mockStatus     int
+		retryAfter     string
 		wantResult     *model.OrderAccrual
 		wantErr        bool
 		expectedErrMsg string
@@ -60,7 +68,7 @@ func TestGetStatus(t *testing.T) {
 			wantResult: &model.OrderAccrual{
 				Number:  "50405077004",
 				Status:  "PROCESSED",
-				Accrual: func() *float64 { f := 100.50; return &f }(),
+				Accrual: utils.PtrAmount(money.MustParse("100.50")),
 			},
 			wantErr: false,
 		},
@@ -78,9 +86,10 @@ func TestGetStatus(t *testing.T) {
 			orderID:        "50405077004",
 			mockResponse:   "",
 			mockStatus:     http.StatusTooManyRequests,
+			retryAfter:     "30",
 			wantResult:     nil,
 			wantErr:        true,
-			expectedErrMsg: "order not ready",
+			expectedErrMsg: "too many requests",
 		},
 		{
 			name:    "invalid json response",
@@ -93,7 +102,7 @@ func TestGetStatus(t *testing.T) {
 			mockStatus:     http.StatusOK,
 			wantResult:     nil,
 			wantErr:        true,
-			expectedErrMsg: "expected number",
+			expectedErrMsg: "invalid money amount",
 		},
 	}
 
@@ -103,6 +112,9 @@ func TestGetStatus(t *testing.T) {
 				assert.Equal(t, "/api/orders/"+tt.orderID, r.URL.Path)
 				assert.Equal(t, http.MethodGet, r.Method)
 
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
 				w.WriteHeader(tt.mockStatus)
 
 				switch v := tt.mockResponse.(type) {
@@ -115,7 +127,7 @@ func TestGetStatus(t *testing.T) {
 			defer ts.Close()
 			c := NewClient(ts.URL)
 
-			got, err := c.GetStatus(tt.orderID)
+			got, err := c.GetStatus(context.Background(), tt.orderID)
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -129,3 +141,77 @@ func TestGetStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestGetStatusRetryAfter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	_, err := NewClient(ts.URL).GetStatus(context.Background(), "50405077004")
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, 30*time.Second, apiErr.RetryAfter)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5"))
+	assert.Equal(t, defaultRetryAfter, parseRetryAfter(""))
+	assert.Equal(t, defaultRetryAfter, parseRetryAfter("-1"))
+
+	delay := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.InDelta(t, time.Hour.Seconds(), delay.Seconds(), 2)
+}
+
+func TestGetStatusPropagatesTrace(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	provider := sdktrace.NewTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer provider.Shutdown(context.Background())
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "parent")
+	defer span.End()
+
+	_, err := NewClient(server.URL).GetStatus(ctx, "50405077004")
+	require.Error(t, err)
+	assert.Contains(t, traceparent, span.SpanContext().TraceID().String(), "the trace must continue in the accrual system")
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "not found is an answer", status: http.StatusNotFound},
+		{name: "server error", status: http.StatusServiceUnavailable, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := NewClient(server.URL).Ping(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	assert.Error(t, NewClient("http://127.0.0.1:1").Ping(context.Background()), "connection refused")
+}
@@ -1,6 +1,7 @@
 package converter
 
 import (
+	"TimBerk/gophermart/internal/app/models/balance"
 	model "TimBerk/gophermart/internal/app/models/order"
 	"TimBerk/gophermart/internal/app/store"
 )
@@ -11,11 +12,24 @@ func OrderDBToOrderAPI(record store.OrderRecord) model.OrderDetailResponse {
 		Status: string(record.Status),
 	}
 
-	if record.Accrual.Valid {
-		item.Accrual = &record.Accrual.Float64
-	} else {
-		item.Accrual = nil
-	}
+	item.Accrual = record.Accrual.Ptr()
 
 	return item
 }
+
+func LedgerDBToHistoryAPI(records []store.LedgerEntry) balance.HistoryList {
+	items := make(balance.HistoryList, 0, len(records))
+	for _, record := range records {
+		items = append(items, balance.HistoryEntry{
+			ID:        record.ID,
+			Type:      string(record.Kind),
+			Debit:     string(record.Debit),
+			Credit:    string(record.Credit),
+			Amount:    record.Amount,
+			Order:     record.Order,
+			Comment:   record.Comment,
+			CreatedAt: record.CreatedAt,
+		})
+	}
+	return items
+}
@@ -3,8 +3,8 @@ package converter
 import (
 	model "TimBerk/gophermart/internal/app/models/order"
 	"TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/money"
 	"TimBerk/gophermart/pkg/utils"
-	"database/sql"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -20,12 +20,12 @@ func TestOrderDBToOrderAPI(t *testing.T) {
 				UserID:  100,
 				Order:   "ORDER123",
 				Status:  store.Processed,
-				Accrual: sql.NullFloat64{Float64: 150.75, Valid: true},
+				Accrual: money.NewNullAmount(money.MustParse("150.75")),
 			},
 			expected: model.OrderDetailResponse{
 				Number:  "ORDER123",
 				Status:  "PROCESSED",
-				Accrual: utils.PtrFloat64(150.75),
+				Accrual: utils.PtrAmount(money.MustParse("150.75")),
 			},
 		},
 		"record without accrual": {
@@ -34,7 +34,7 @@ func TestOrderDBToOrderAPI(t *testing.T) {
 				UserID:  100,
 				Order:   "ORDER456",
 				Status:  store.New,
-				Accrual: sql.NullFloat64{Valid: false},
+				Accrual: money.NullAmount{},
 			},
 			expected: model.OrderDetailResponse{
 				Number:  "ORDER456",
@@ -48,7 +48,7 @@ func TestOrderDBToOrderAPI(t *testing.T) {
 				UserID:  0,
 				Order:   "",
 				Status:  store.Status(""),
-				Accrual: sql.NullFloat64{Valid: false},
+				Accrual: money.NullAmount{},
 			},
 			expected: model.OrderDetailResponse{
 				Number:  "",
@@ -62,12 +62,12 @@ func TestOrderDBToOrderAPI(t *testing.T) {
 				UserID:  100,
 				Order:   "ORDER789",
 				Status:  store.Processing,
-				Accrual: sql.NullFloat64{Float64: 0, Valid: true},
+				Accrual: money.NewNullAmount(0),
 			},
 			expected: model.OrderDetailResponse{
 				Number:  "ORDER789",
 				Status:  "PROCESSING",
-				Accrual: utils.PtrFloat64(0),
+				Accrual: utils.PtrAmount(0),
 			},
 		},
 		"record with negative accrual": {
@@ -76,12 +76,12 @@ func TestOrderDBToOrderAPI(t *testing.T) {
 				UserID:  100,
 				Order:   "ORDER987",
 				Status:  store.Invalid,
-				Accrual: sql.NullFloat64{Float64: -50.25, Valid: true},
+				Accrual: money.NewNullAmount(money.MustParse("-50.25")),
 			},
 			expected: model.OrderDetailResponse{
 				Number:  "ORDER987",
 				Status:  "INVALID",
-				Accrual: utils.PtrFloat64(-50.25),
+				Accrual: utils.PtrAmount(money.MustParse("-50.25")),
 			},
 		},
 	}
@@ -93,7 +93,7 @@ func TestOrderDBToOrderAPI(t *testing.T) {
 			assert.Equal(t, tc.expected, result)
 			if tc.input.Accrual.Valid {
 				assert.NotNil(t, result.Accrual)
-				assert.Equal(t, tc.input.Accrual.Float64, *result.Accrual)
+				assert.Equal(t, tc.input.Accrual.Amount, *result.Accrual)
 			} else {
 				assert.Nil(t, result.Accrual)
 			}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/responses"
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// storeErrors maps domain errors of the store to responses, the first match wins.
+// Errors missing here are server errors.
+var storeErrors = []struct {
+	err     error
+	status  int
+	message string
+}{
+	{store.ErrNotFound, http.StatusNotFound, "not found"},
+	{store.ErrInvalidCursor, http.StatusBadRequest, "failed to validate request params"},
+	{store.ErrDuplicateUser, http.StatusConflict, "user was registered"},
+	{store.ErrOrderOwnedByOther, http.StatusConflict, "failed to check order: it was uploaded another user"},
+	{store.ErrDuplicateOrder, http.StatusConflict, "order was already uploaded"},
+	{store.ErrDuplicateWithdrawal, http.StatusConflict, "withdrawal for the order was already made"},
+	{store.ErrInsufficientFunds, http.StatusPaymentRequired, "failed to use balance: it's less than sum"},
+	{store.ErrInvalidStatusTransition, http.StatusConflict, "order status can't be changed"},
+	{store.ErrUserHasBalance, http.StatusConflict, "account has positive balance, confirm deletion with force"},
+	{store.ErrUserHasPendingOrders, http.StatusConflict, "account has orders in processing, confirm deletion with force"},
+	{store.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh token was already used, session is revoked"},
+	{store.ErrRefreshTokenInvalid, http.StatusUnauthorized, "invalid refresh token"},
+	{context.DeadlineExceeded, http.StatusServiceUnavailable, "request timed out"},
+	{context.Canceled, http.StatusServiceUnavailable, "request was cancelled"},
+}
+
+// writeStoreError answers with the response of a known domain error. Other errors are logged
+// with the message and answered with 500.
+func writeStoreError(w http.ResponseWriter, err error, message string, logFields *logrus.Entry) {
+	for _, known := range storeErrors {
+		if errors.Is(err, known.err) {
+			logFields.WithField("error", err).Warning(known.message)
+			responses.WriteJSONError(w, known.message, known.status)
+			return
+		}
+	}
+
+	logFields.WithField("error", err).Error(message)
+	responses.WriteJSONError(w, message, http.StatusInternalServerError)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"TimBerk/gophermart/internal/app/store"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteStoreError(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "not found",
+			err:            fmt.Errorf("lock order error: %w", store.ErrNotFound),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"not found"}`,
+		},
+		{
+			name:           "order of another user",
+			err:            store.ErrOrderOwnedByOther,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"failed to check order: it was uploaded another user"}`,
+		},
+		{
+			name:           "duplicate withdrawal",
+			err:            fmt.Errorf("create withdrawals error: %w", store.ErrDuplicateWithdrawal),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"withdrawal for the order was already made"}`,
+		},
+		{
+			name:           "insufficient funds",
+			err:            store.ErrInsufficientFunds,
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   `{"error":"failed to use balance: it's less than sum"}`,
+		},
+		{
+			name:           "status transition",
+			err:            &store.StatusTransitionError{Order: "12345678903", From: store.Processed, To: store.New},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"order status can't be changed"}`,
+		},
+		{
+			name:           "query timeout",
+			err:            fmt.Errorf("failed to get orders: %w", context.DeadlineExceeded),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"error":"request timed out"}`,
+		},
+		{
+			name:           "unknown error",
+			err:            errors.New("connection reset"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"failed to do something"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			writeStoreError(rr, tt.err, "failed to do something", logrus.WithField("action", "Test"))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	middlewareAuth "TimBerk/gophermart/internal/app/middlewares/auth"
+	"TimBerk/gophermart/internal/app/models/auth"
+	"TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/responses"
+	"TimBerk/gophermart/pkg/validators"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// confirmPassword checks the password of the authorized user before a sensitive change.
+// Failures are counted like failed logins, so a stolen token can't be used to guess the password.
+func (h *Handler) confirmPassword(w http.ResponseWriter, r *http.Request, userID int64, password string, logFields *logrus.Entry) bool {
+	var errMessage string
+
+	username, _ := r.Context().Value(middlewareAuth.UsernameKey).(string)
+	ip := clientIP(r)
+
+	lockout, err := h.store.LoginLockout(r.Context(), username, ip)
+	if err != nil {
+		errMessage = "failed to check login attempts"
+		logFields.WithField("error", err).Error(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+		return false
+	}
+	if lockout > 0 {
+		errMessage = "too many login attempts, try later"
+		logFields.WithField("lockout", lockout).Warning(errMessage)
+		writeRetryAfter(w, lockout)
+		responses.WriteJSONError(w, errMessage, http.StatusTooManyRequests)
+		return false
+	}
+
+	user, err := h.store.GetUser(r.Context(), username)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && user.ID != userID) {
+		errMessage = "User is not authorized"
+		logFields.Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
+		return false
+	}
+	if err != nil {
+		writeStoreError(w, err, "failed to find user", logFields)
+		return false
+	}
+
+	if valid, _ := h.passwordHasher().Verify(password, user.PasswordHash); !valid {
+		attempt := store.LoginAttempt{Username: username, IP: ip, Reason: store.LoginReasonWrongPassword}
+		if _, err = h.store.RecordLoginFailure(r.Context(), attempt, h.loginPolicies()); err != nil {
+			logFields.WithField("error", err).Error("failed to record login attempt")
+		}
+
+		errMessage = "incorrect password"
+		logFields.Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// ChangePassword replaces the password and revokes every session of the user,
+// the caller gets a new pair of tokens.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var requestData auth.ChangePasswordRequest
+	var errMessage string
+	action := "ChangePassword"
+
+	userID, ok := validators.ValidateAuthorization(w, r, action)
+	if !ok {
+		return
+	}
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
+
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		errMessage = "failed to parse request data"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+	if err = requestData.Validate(); err != nil {
+		errMessage = "failed to validate request data"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+
+	if !h.confirmPassword(w, r, userID, requestData.CurrentPassword, logFields) {
+		return
+	}
+
+	username, _ := r.Context().Value(middlewareAuth.UsernameKey).(string)
+	if err = h.passwordPolicy().Validate(username, requestData.NewPassword); err != nil {
+		errMessage = err.Error()
+		logFields.Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+
+	passwordHash, err := h.passwordHasher().Hash(requestData.NewPassword)
+	if err != nil {
+		errMessage = "failed to prepare password"
+		logFields.WithField("error", err).Error(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+		return
+	}
+	generation, err := h.store.ChangePassword(r.Context(), userID, passwordHash)
+	if err != nil {
+		writeStoreError(w, err, "failed to change password", logFields)
+		return
+	}
+	logFields.Info("password changed, sessions revoked")
+
+	refreshToken, err := h.startSession(r.Context(), userID)
+	if err == nil {
+		err = h.writeTokens(w, username, userID, generation, refreshToken)
+	}
+	if err != nil {
+		errMessage = "failed to generate token"
+		logFields.WithField("error", err).Error(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteAccount anonymizes the account after the password confirmation. A positive balance
+// or orders in processing keep the account unless the request is forced.
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var requestData auth.DeleteRequest
+	var errMessage string
+	action := "DeleteAccount"
+
+	userID, ok := validators.ValidateAuthorization(w, r, action)
+	if !ok {
+		return
+	}
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
+
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		errMessage = "failed to parse request data"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+	if requestData.Password == "" {
+		errMessage = "password is required and cannot be empty"
+		logFields.Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+
+	if !h.confirmPassword(w, r, userID, requestData.Password, logFields) {
+		return
+	}
+
+	if err = h.store.DeleteUser(r.Context(), userID, requestData.Force); err != nil {
+		writeStoreError(w, err, "failed to delete account", logFields)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: "token", Value: "", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: refreshCookie, Value: "", Path: "/api/user", MaxAge: -1, HttpOnly: true})
+
+	logFields.WithField("force", requestData.Force).Info("account deleted")
+	responses.WriteJSONEmpty(w, http.StatusOK)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"TimBerk/gophermart/internal/app/middlewares/auth"
+	storeModel "TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/money"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const accountPassword = "Tr0ub4dor&3"
+
+func newAccountHandler(t *testing.T) (*Handler, *storeModel.MemoryStore, int64) {
+	h, dataStore, _ := newAuthHandler(t)
+	usePasswordConfig(h)
+	h.cfg.LoginMaxAttempts, h.cfg.LoginLockout, h.cfg.LoginWindow = 5, 60, 15
+
+	passwordHash, err := h.passwordHasher().Hash(accountPassword)
+	require.NoError(t, err)
+	userID, err := dataStore.AddUser(context.Background(), "gopher", passwordHash)
+	require.NoError(t, err)
+	return h, dataStore, userID
+}
+
+func accountRequest(method string, target string, body string, userID int64) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader([]byte(body)))
+	ctx := context.WithValue(req.Context(), auth.UserIDKey, userID)
+	ctx = context.WithValue(ctx, auth.UsernameKey, "gopher")
+	return req.WithContext(ctx)
+}
+
+func TestChangePassword(t *testing.T) {
+	h, dataStore, userID := newAccountHandler(t)
+	oldRefresh, err := h.startSession(context.Background(), userID)
+	require.NoError(t, err)
+	_, oldToken, err := generateToken(h.cfg, h.keys, "gopher", userID, 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "missing new password",
+			body:           `{"current_password":"` + accountPassword + `"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"failed to validate request data"}`,
+		},
+		{
+			name:           "wrong current password",
+			body:           `{"current_password":"wrong","new_password":"N3w-Secret!"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"incorrect password"}`,
+		},
+		{
+			name:           "weak new password",
+			body:           `{"current_password":"` + accountPassword + `","new_password":"qwerty"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"password is too weak: it must be at least 8 characters long"}`,
+		},
+		{
+			name:           "changed",
+			body:           `{"current_password":"` + accountPassword + `","new_password":"N3w-Secret!"}`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	var newToken string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.ChangePassword(rr, accountRequest(http.MethodPut, "/api/user/password", tt.body, userID))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			} else {
+				newToken = decodeTokens(t, rr).Token
+			}
+		})
+	}
+
+	user, err := dataStore.GetUser(context.Background(), "gopher")
+	require.NoError(t, err)
+	valid, _ := h.passwordHasher().Verify("N3w-Secret!", user.PasswordHash)
+	assert.True(t, valid)
+
+	assert.Equal(t, http.StatusUnauthorized, refresh(h, "", oldRefresh).Code, "old sessions are revoked")
+
+	// Tokens issued in the same second before and after the change are told apart by the generation.
+	oldClaims, newClaims := &JWTRecord{}, &JWTRecord{}
+	_, err = jwt.ParseWithClaims(oldToken, oldClaims, h.keys.Keyfunc)
+	require.NoError(t, err)
+	_, err = jwt.ParseWithClaims(newToken, newClaims, h.keys.Keyfunc)
+	require.NoError(t, err)
+
+	revoked, err := dataStore.IsAccessTokenRevoked(context.Background(), userID, oldClaims.ID, oldClaims.SessionGeneration)
+	require.NoError(t, err)
+	assert.True(t, revoked, "token issued just before the change is revoked")
+	revoked, err = dataStore.IsAccessTokenRevoked(context.Background(), userID, newClaims.ID, newClaims.SessionGeneration)
+	require.NoError(t, err)
+	assert.False(t, revoked, "token issued with the change is kept")
+}
+
+func TestDeleteAccount(t *testing.T) {
+	h, dataStore, userID := newAccountHandler(t)
+	ctx := context.Background()
+	require.NoError(t, dataStore.AddOrder(ctx, userID, mockOrderID))
+	require.NoError(t, dataStore.UpdateOrderStatus(ctx, userID, mockOrderID, storeModel.Processed, money.FromUnits(100)))
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "missing password",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"password is required and cannot be empty"}`,
+		},
+		{
+			name:           "wrong password",
+			body:           `{"password":"wrong","force":true}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"incorrect password"}`,
+		},
+		{
+			name:           "positive balance",
+			body:           `{"password":"` + accountPassword + `"}`,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"account has positive balance, confirm deletion with force"}`,
+		},
+		{
+			name:           "forced",
+			body:           `{"password":"` + accountPassword + `","force":true}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "already deleted",
+			body:           `{"password":"` + accountPassword + `","force":true}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"User is not authorized"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.DeleteAccount(rr, accountRequest(http.MethodDelete, "/api/user", tt.body, userID))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+
+	_, err := dataStore.GetUser(ctx, "gopher")
+	assert.Error(t, err)
+	current, err := dataStore.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromUnits(100), current.Current, "ledger history is kept")
+}
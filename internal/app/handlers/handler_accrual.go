@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	model "TimBerk/gophermart/internal/app/models/order"
+	"TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/internal/app/worker"
+	"TimBerk/gophermart/pkg/responses"
+	"errors"
+	"fmt"
+	"github.com/mailru/easyjson"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+)
+
+// accrualCallbackMaxBytes bounds a pushed result, it holds one order only.
+const accrualCallbackMaxBytes = 16 << 10
+
+// AccrualCallback accepts a result pushed by the accrual system, the request signature is checked by the router.
+func (h *Handler) AccrualCallback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var errMessage string
+	action := "AccrualCallback"
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action})
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, accrualCallbackMaxBytes))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		errMessage = fmt.Sprintf("request body must be at most %d bytes", accrualCallbackMaxBytes)
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		errMessage = "failed to read request body"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+
+	var requestData model.OrderAccrual
+	if err = easyjson.Unmarshal(body, &requestData); err != nil || requestData.Number == "" {
+		errMessage = "failed to parse request data"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+	logFields = logFields.WithField("order", requestData.Number)
+
+	order, err := h.store.GetOrder(r.Context(), requestData.Number)
+	if errors.Is(err, store.ErrNotFound) {
+		errMessage = "order not found"
+		logFields.Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err, "failed to find order", logFields)
+		return
+	}
+
+	err = worker.ApplyAccrual(r.Context(), h.store, order.UserID, order.Order, &requestData)
+
+	// A redelivered callback finds the order already in the reported status, it is not an error for the sender.
+	var transitionErr *store.StatusTransitionError
+	if errors.As(err, &transitionErr) && transitionErr.From == transitionErr.To {
+		logFields.Info("accrual already applied")
+		err = nil
+	}
+
+	switch {
+	case errors.Is(err, worker.ErrMissingAccrual):
+		errMessage = "failed to validate request data"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		writeStoreError(w, err, "failed to update order", logFields)
+		return
+	}
+
+	logFields.Info("accrual applied")
+	responses.WriteJSONEmpty(w, http.StatusOK)
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	storeModel "TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/money"
+	"bytes"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccrualCallback(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	record := storeModel.OrderRecord{ID: 1, UserID: mockUserID, Order: mockOrderID, Status: storeModel.Processing}
+	processed := `{"order":"` + mockOrderID + `","status":"PROCESSED","accrual":100.5}`
+
+	tests := []struct {
+		name           string
+		setupMocks     func(*MockStore)
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "processed order",
+			setupMocks: func(store *MockStore) {
+				store.On("GetOrder", mock.Anything, mockOrderID).Return(record, nil)
+				store.On("UpdateOrderStatus", mock.Anything, mockUserID, mockOrderID, storeModel.Processed,
+					money.MustParse("100.5")).Return(nil)
+			},
+			requestBody:    processed,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "registered order keeps new status",
+			setupMocks: func(store *MockStore) {
+				store.On("GetOrder", mock.Anything, mockOrderID).Return(record, nil)
+				store.On("UpdateOrderStatus", mock.Anything, mockUserID, mockOrderID, storeModel.New,
+					money.Amount(0)).Return(nil)
+			},
+			requestBody:    `{"order":"` + mockOrderID + `","status":"REGISTERED"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid body",
+			setupMocks:     func(*MockStore) {},
+			requestBody:    `{"order":`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"failed to parse request data"}`,
+		},
+		{
+			name:           "body too large",
+			setupMocks:     func(*MockStore) {},
+			requestBody:    `{"order":"` + mockOrderID + `","status":"PROCESSED","accrual":1}` + strings.Repeat(" ", accrualCallbackMaxBytes),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "missing order number",
+			setupMocks:     func(*MockStore) {},
+			requestBody:    `{"status":"PROCESSED","accrual":1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"failed to parse request data"}`,
+		},
+		{
+			name: "unknown order",
+			setupMocks: func(store *MockStore) {
+				store.On("GetOrder", mock.Anything, mockOrderID).Return(storeModel.OrderRecord{}, storeModel.ErrNotFound)
+			},
+			requestBody:    processed,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"order not found"}`,
+		},
+		{
+			name: "processed without accrual",
+			setupMocks: func(store *MockStore) {
+				store.On("GetOrder", mock.Anything, mockOrderID).Return(record, nil)
+			},
+			requestBody:    `{"order":"` + mockOrderID + `","status":"PROCESSED"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error":"failed to validate request data"}`,
+		},
+		{
+			name: "redelivered callback",
+			setupMocks: func(store *MockStore) {
+				store.On("GetOrder", mock.Anything, mockOrderID).Return(record, nil)
+				store.On("UpdateOrderStatus", mock.Anything, mockUserID, mockOrderID, storeModel.Processed,
+					money.MustParse("100.5")).Return(&storeModel.StatusTransitionError{
+					Order: mockOrderID, From: storeModel.Processed, To: storeModel.Processed})
+			},
+			requestBody:    processed,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "order is already final",
+			setupMocks: func(store *MockStore) {
+				store.On("GetOrder", mock.Anything, mockOrderID).Return(record, nil)
+				store.On("UpdateOrderStatus", mock.Anything, mockUserID, mockOrderID, storeModel.Processed,
+					money.MustParse("100.5")).Return(&storeModel.StatusTransitionError{
+					Order: mockOrderID, From: storeModel.Invalid, To: storeModel.Processed})
+			},
+			requestBody:    processed,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"order status can't be changed"}`,
+		},
+		{
+			name: "database error",
+			setupMocks: func(store *MockStore) {
+				store.On("GetOrder", mock.Anything, mockOrderID).Return(record, nil)
+				store.On("UpdateOrderStatus", mock.Anything, mockUserID, mockOrderID, storeModel.Processed,
+					money.MustParse("100.5")).Return(errors.New("db error"))
+			},
+			requestBody:    processed,
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"failed to update order"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			tt.setupMocks(mockStore)
+			h := &Handler{store: mockStore}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/internal/accrual/callback", bytes.NewReader([]byte(tt.requestBody)))
+			rr := httptest.NewRecorder()
+
+			h.AccrualCallback(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
@@ -1,39 +1,203 @@
 package handlers
 
 import (
+	"TimBerk/gophermart/internal/app/keys"
+	middlewareAuth "TimBerk/gophermart/internal/app/middlewares/auth"
 	"TimBerk/gophermart/internal/app/models/auth"
 	"TimBerk/gophermart/internal/app/settings/config"
+	"TimBerk/gophermart/internal/app/store"
 	"TimBerk/gophermart/pkg/responses"
 	"TimBerk/gophermart/pkg/secure"
+	"TimBerk/gophermart/pkg/validators"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/mailru/easyjson"
 	"github.com/sirupsen/logrus"
+	"io"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 type JWTRecord struct {
-	Username string `json:"username"`
-	UserID   int64  `json:"id"`
+	Username          string `json:"username"`
+	UserID            int64  `json:"id"`
+	SessionGeneration int64  `json:"gen,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func generateToken(cfg *config.Config, userData auth.RequestData, userID int64) (time.Time, string, error) {
+const (
+	refreshCookie    = "refresh_token"
+	refreshTokenSize = 32
+
+	// dummyPassword only feeds the hash checked for unknown usernames, it never matches a login.
+	dummyPassword = "gophermart-unknown-user"
+)
+
+func generateToken(cfg *config.Config, keySet *keys.KeySet, username string, userID int64, generation int64) (time.Time, string, error) {
+	tokenID, err := secure.GenerateToken(16)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
 	durationTime := time.Duration(cfg.ExpireJWT) * time.Minute
 	expirationTime := time.Now().Add(durationTime)
 	claims := &JWTRecord{
-		Username: userData.Username,
-		UserID:   userID,
+		Username:          username,
+		UserID:            userID,
+		SessionGeneration: generation,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	generatedToken, err := token.SignedString(cfg.KeyJWT)
+	generatedToken, err := keySet.Sign(claims)
 	return expirationTime, generatedToken, err
 }
 
+func (h *Handler) passwordPolicy() secure.PasswordPolicy {
+	policy := secure.PasswordPolicy{MinLength: h.cfg.PasswordMinLength, MinClasses: h.cfg.PasswordMinClasses}
+	if h.cfg.PasswordHash == secure.AlgorithmBcrypt {
+		policy.MaxLength = secure.BcryptMaxLength
+	}
+	return policy
+}
+
+func (h *Handler) passwordHasher() secure.PasswordHasher {
+	return h.cfg.PasswordHasher()
+}
+
+// dummyPasswordHash is verified for unknown usernames, so they are answered as slowly as a wrong
+// password and the response time doesn't tell which usernames exist. It is made with the
+// configured costs on first use.
+func (h *Handler) dummyPasswordHash(logFields *logrus.Entry) string {
+	h.dummyHashOnce.Do(func() {
+		hash, err := h.passwordHasher().Hash(dummyPassword)
+		if err != nil {
+			logFields.WithField("error", err).Error("failed to make dummy password hash")
+			return
+		}
+		h.dummyHash = hash
+	})
+	return h.dummyHash
+}
+
+// upgradePasswordHash rehashes the password after a successful login when the stored hash
+// uses another algorithm or outdated costs. Failures are only logged, the login goes on.
+func (h *Handler) upgradePasswordHash(ctx context.Context, userID int64, password string, logFields *logrus.Entry) {
+	passwordHash, err := h.passwordHasher().Hash(password)
+	if err == nil {
+		err = h.store.UpdatePasswordHash(ctx, userID, passwordHash)
+	}
+	if err != nil {
+		logFields.WithField("error", err).Error("failed to upgrade password hash")
+		return
+	}
+	logFields.Info("password hash upgraded")
+}
+
+// loginPolicies builds throttling policies, lockouts are configured in seconds and the window in minutes.
+func (h *Handler) loginPolicies() map[store.LoginScope]store.LoginPolicy {
+	policy := store.LoginPolicy{
+		Lockout:    time.Duration(h.cfg.LoginLockout) * time.Second,
+		MaxLockout: time.Duration(h.cfg.LoginMaxLockout) * time.Second,
+		Window:     time.Duration(h.cfg.LoginWindow) * time.Minute,
+	}
+
+	userPolicy, ipPolicy := policy, policy
+	userPolicy.MaxAttempts = h.cfg.LoginMaxAttempts
+	ipPolicy.MaxAttempts = h.cfg.LoginIPMaxAttempts
+	return map[store.LoginScope]store.LoginPolicy{store.LoginScopeUser: userPolicy, store.LoginScopeIP: ipPolicy}
+}
+
+// clientIP returns the address of the connection, forwarded headers are not trusted.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeRetryAfter(w http.ResponseWriter, delay time.Duration) {
+	seconds := int64(math.Ceil(delay.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(max(seconds, 1), 10))
+}
+
+func (h *Handler) refreshTTL() time.Duration {
+	return time.Duration(h.cfg.ExpireRefresh) * time.Minute
+}
+
+// startSession creates the first refresh token of a new family for the user.
+func (h *Handler) startSession(ctx context.Context, userID int64) (string, error) {
+	familyID, err := secure.GenerateToken(16)
+	if err != nil {
+		return "", err
+	}
+	refreshToken, err := secure.GenerateToken(refreshTokenSize)
+	if err != nil {
+		return "", err
+	}
+
+	err = h.store.AddRefreshToken(ctx, userID, familyID, secure.HashToken(refreshToken), h.refreshTTL())
+	return refreshToken, err
+}
+
+// writeTokens issues an access token of the session generation and sends it with the refresh token
+// in cookies and in the body.
+func (h *Handler) writeTokens(w http.ResponseWriter, username string, userID int64, generation int64, refreshToken string) error {
+	expirationTime, tokenString, err := generateToken(h.cfg, h.keys, username, userID, generation)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:    "token",
+		Value:   tokenString,
+		Expires: expirationTime,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookie,
+		Value:    refreshToken,
+		Path:     "/api/user",
+		Expires:  time.Now().Add(h.refreshTTL()),
+		HttpOnly: true,
+	})
+
+	responses.WriteJSONToken(w, tokenString, refreshToken)
+	return nil
+}
+
+// getRefreshToken reads the refresh token from the request body or, if it is empty, from the cookie.
+func getRefreshToken(r *http.Request) (string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		var requestData auth.RefreshRequest
+		if err = easyjson.Unmarshal(body, &requestData); err != nil {
+			return "", err
+		}
+		if requestData.RefreshToken != "" {
+			return requestData.RefreshToken, nil
+		}
+	}
+
+	cookie, err := r.Cookie(refreshCookie)
+	if err != nil {
+		return "", nil
+	}
+	return cookie.Value, nil
+}
+
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var userData auth.RequestData
 	var errMessage string
@@ -43,7 +207,7 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&userData)
 	if err != nil {
 		errMessage = "failed to parse request data"
-		logrus.WithFields(logrus.Fields{"action": action, "error": err}).Error(errMessage)
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "error": err}).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
 		return
 	}
@@ -51,55 +215,58 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	err = userData.Validate()
 	if err != nil {
 		errMessage = "failed to validate request data"
-		logrus.WithFields(logrus.Fields{"action": action, "error": err}).Error(errMessage)
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "error": err}).Error(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+
+	err = h.passwordPolicy().Validate(userData.Username, userData.Password)
+	if err != nil {
+		errMessage = err.Error()
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "user": userData.Username}).Warning(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
 		return
 	}
 
-	userID, err := h.store.CheckUser(h.ctx, userData.Username)
+	userID, err := h.store.CheckUser(r.Context(), userData.Username)
 	if err != nil {
 		errMessage = "failed to find user"
-		logrus.WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
 		return
 	}
 	if userID != 0 {
 		errMessage = "user was registered"
-		logrus.WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusConflict)
 		return
 	}
 
-	hashedPassword, err := secure.HashPassword(userData.Password)
+	hashedPassword, err := h.passwordHasher().Hash(userData.Password)
 	if err != nil {
 		errMessage = "failed to prepare password"
-		logrus.WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
 		return
 	}
-	userID, err = h.store.AddUser(h.ctx, userData.Username, hashedPassword)
+	// A parallel registration of the same login is reported by the store as a duplicate.
+	userID, err = h.store.AddUser(r.Context(), userData.Username, hashedPassword)
 	if err != nil {
-		errMessage = "failed to register user"
-		logrus.WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+		writeStoreError(w, err, "failed to register user", logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "user": userData.Username}))
 		return
 	}
 
-	expirationTime, tokenString, err := generateToken(h.cfg, userData, userID)
+	// Sessions of a new user start at the first generation.
+	refreshToken, err := h.startSession(r.Context(), userID)
+	if err == nil {
+		err = h.writeTokens(w, userData.Username, userID, 0, refreshToken)
+	}
 	if err != nil {
 		errMessage = "failed to generate token"
-		logrus.WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
 		return
 	}
-
-	http.SetCookie(w, &http.Cookie{
-		Name:    "token",
-		Value:   tokenString,
-		Expires: expirationTime,
-	})
-
-	responses.WriteJSONToken(w, tokenString)
 }
 
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
@@ -111,7 +278,7 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&userData)
 	if err != nil {
 		errMessage = "failed to parse request data"
-		logrus.WithFields(logrus.Fields{"action": action, "error": err}).Error(errMessage)
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "error": err}).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
 		return
 	}
@@ -119,39 +286,184 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	err = userData.Validate()
 	if err != nil {
 		errMessage = "failed to validate request data"
-		logrus.WithFields(logrus.Fields{"action": action, "error": err}).Error(errMessage)
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "error": err}).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
 		return
 	}
 
-	user, err := h.store.GetUser(h.ctx, userData.Username)
+	ip := clientIP(r)
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userData.Username, "ip": ip})
+
+	// Locked logins are rejected before the password hash is checked.
+	lockout, err := h.store.LoginLockout(r.Context(), userData.Username, ip)
 	if err != nil {
-		errMessage = "failed to find user"
-		logrus.WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
+		errMessage = "failed to check login attempts"
+		logFields.WithField("error", err).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
 		return
 	}
+	if lockout > 0 {
+		errMessage = "too many login attempts, try later"
+		logFields.WithField("lockout", lockout).Warning(errMessage)
+		writeRetryAfter(w, lockout)
+		responses.WriteJSONError(w, errMessage, http.StatusTooManyRequests)
+		return
+	}
+
+	user, err := h.store.GetUser(r.Context(), userData.Username)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeStoreError(w, err, "failed to find user", logFields)
+		return
+	}
+
+	reason := store.LoginReasonUnknownUser
+	valid, rehash := false, false
+	if err == nil {
+		reason = store.LoginReasonWrongPassword
+		valid, rehash = h.passwordHasher().Verify(userData.Password, user.PasswordHash)
+	} else {
+		h.passwordHasher().Verify(userData.Password, h.dummyPasswordHash(logFields))
+	}
+
+	if !valid {
+		attempt := store.LoginAttempt{Username: userData.Username, IP: ip, Reason: reason}
+		lockout, err = h.store.RecordLoginFailure(r.Context(), attempt, h.loginPolicies())
+		if err != nil {
+			logFields.WithField("error", err).Error("failed to record login attempt")
+		}
+		if lockout > 0 {
+			logFields.WithField("lockout", lockout).Warning("login is locked")
+		}
 
-	if !secure.CheckPasswordHash(userData.Password, user.PasswordHash) {
 		errMessage = "incorrect pair username and password"
-		logrus.WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
+		logFields.WithField("reason", reason).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
 		return
 	}
 
-	expirationTime, tokenString, err := generateToken(h.cfg, userData, user.ID)
+	if err = h.store.ResetLoginFailures(r.Context(), userData.Username); err != nil {
+		logFields.WithField("error", err).Error("failed to reset login attempts")
+	}
+	if rehash {
+		h.upgradePasswordHash(r.Context(), user.ID, userData.Password, logFields)
+	}
+
+	refreshToken, err := h.startSession(r.Context(), user.ID)
+	if err == nil {
+		err = h.writeTokens(w, userData.Username, user.ID, user.SessionGeneration, refreshToken)
+	}
 	if err != nil {
 		errMessage = "failed to generate token"
-		logrus.WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "user": userData.Username, "error": err}).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
 		return
 	}
+}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:    "token",
-		Value:   tokenString,
-		Expires: expirationTime,
-	})
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var errMessage string
+	action := "RefreshToken"
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action})
+
+	refreshToken, err := getRefreshToken(r)
+	if err != nil {
+		errMessage = "failed to parse request data"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+	if refreshToken == "" {
+		errMessage = "refresh token is required"
+		logFields.Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
+		return
+	}
+
+	newToken, err := secure.GenerateToken(refreshTokenSize)
+	if err != nil {
+		errMessage = "failed to generate token"
+		logFields.WithField("error", err).Error(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+		return
+	}
+
+	record, err := h.store.RotateRefreshToken(r.Context(), secure.HashToken(refreshToken), secure.HashToken(newToken), h.refreshTTL())
+	if err != nil {
+		writeStoreError(w, err, "failed to refresh token", logFields.WithField("user", record.UserID))
+		return
+	}
+
+	if err = h.writeTokens(w, record.Username, record.UserID, record.SessionGeneration, newToken); err != nil {
+		errMessage = "failed to generate token"
+		logFields.WithFields(logrus.Fields{"user": record.UserID, "error": err}).Error(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+		return
+	}
+}
+
+// Logout revokes the access token of the request and the session of the refresh token, if it is sent.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var errMessage string
+	action := "Logout"
+
+	userID, ok := validators.ValidateAuthorization(w, r, action)
+	if !ok {
+		return
+	}
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
+
+	tokenID, _ := r.Context().Value(middlewareAuth.TokenIDKey).(string)
+	expiresAt, _ := r.Context().Value(middlewareAuth.TokenExpiresKey).(time.Time)
+	if tokenID != "" {
+		if err := h.store.RevokeAccessToken(r.Context(), tokenID, time.Until(expiresAt)); err != nil {
+			errMessage = "failed to revoke token"
+			logFields.WithField("error", err).Error(errMessage)
+			responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	refreshToken, err := getRefreshToken(r)
+	if err != nil {
+		errMessage = "failed to parse request data"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+	if refreshToken != "" {
+		if err = h.store.RevokeRefreshFamily(r.Context(), secure.HashToken(refreshToken)); err != nil {
+			errMessage = "failed to revoke token"
+			logFields.WithField("error", err).Error(errMessage)
+			responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: "token", Value: "", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: refreshCookie, Value: "", Path: "/api/user", MaxAge: -1, HttpOnly: true})
+
+	logFields.Info("user logged out")
+	responses.WriteJSONEmpty(w, http.StatusOK)
+}
+
+// GetJWKS publishes public keys for verifying access tokens. Keys are cached briefly,
+// so a scheduled key is seen by verifiers before it starts signing.
+func (h *Handler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	jsonRecord, err := json.Marshal(h.keys.JWKS())
+	if err != nil {
+		errMessage := "failed to encode keys"
+		logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": "GetJWKS", "error": err}).Error(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+		return
+	}
 
-	responses.WriteJSONToken(w, tokenString)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonRecord)
 }
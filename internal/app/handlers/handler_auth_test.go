@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"TimBerk/gophermart/internal/app/keys"
+	"TimBerk/gophermart/internal/app/middlewares/auth"
+	"TimBerk/gophermart/internal/app/settings/config"
+	storeModel "TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/responses"
+	"TimBerk/gophermart/pkg/secure"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAuthHandler(t *testing.T) (*Handler, *storeModel.MemoryStore, int64) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	dataStore := storeModel.NewMemoryStore()
+	userID, err := dataStore.AddUser(context.Background(), "user", "hash")
+	require.NoError(t, err)
+
+	cfg := &config.Config{ExpireJWT: 60, ExpireRefresh: 60}
+	keySet, err := keys.NewKeySet(time.Hour, keys.NewHMACKey("test", []byte("secret")))
+	require.NoError(t, err)
+	return &Handler{store: dataStore, cfg: cfg, keys: keySet}, dataStore, userID
+}
+
+func refresh(h *Handler, body string, cookie string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", bytes.NewReader([]byte(body)))
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: refreshCookie, Value: cookie})
+	}
+	rr := httptest.NewRecorder()
+	h.RefreshToken(rr, req)
+	return rr
+}
+
+func decodeTokens(t *testing.T, rr *httptest.ResponseRecorder) responses.TokenResponse {
+	var tokens responses.TokenResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tokens))
+	require.NotEmpty(t, tokens.Token)
+	require.NotEmpty(t, tokens.RefreshToken)
+	return tokens
+}
+
+func TestRefreshToken(t *testing.T) {
+	h, _, userID := newAuthHandler(t)
+
+	first, err := h.startSession(context.Background(), userID)
+	require.NoError(t, err)
+
+	rr := refresh(h, `{"refresh_token":"`+first+`"}`, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	second := decodeTokens(t, rr)
+	assert.NotEqual(t, first, second.RefreshToken, "refresh token must rotate")
+
+	claims := &JWTRecord{}
+	_, err = jwt.ParseWithClaims(second.Token, claims, h.keys.Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, "user", claims.Username)
+	assert.Equal(t, userID, claims.UserID)
+	assert.NotEmpty(t, claims.ID)
+
+	rr = refresh(h, "", second.RefreshToken)
+	require.Equal(t, http.StatusOK, rr.Code, "token is read from the cookie")
+	third := decodeTokens(t, rr)
+
+	rr = refresh(h, `{"refresh_token":"`+first+`"}`, "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"error":"refresh token was already used, session is revoked"}`, rr.Body.String())
+
+	rr = refresh(h, `{"refresh_token":"`+third.RefreshToken+`"}`, "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "reuse must revoke the whole family")
+	assert.JSONEq(t, `{"error":"invalid refresh token"}`, rr.Body.String())
+
+	other, err := h.startSession(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, refresh(h, `{"refresh_token":"`+other+`"}`, "").Code, "other sessions are not affected")
+}
+
+func TestRefreshTokenErrors(t *testing.T) {
+	h, _, _ := newAuthHandler(t)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "missing token",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"refresh token is required"}`,
+		},
+		{
+			name:           "unknown token",
+			body:           `{"refresh_token":"unknown"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"invalid refresh token"}`,
+		},
+		{
+			name:           "invalid body",
+			body:           `{"refresh_token":`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"failed to parse request data"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := refresh(h, tt.body, "")
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	h, dataStore, userID := newAuthHandler(t)
+
+	refreshToken, err := h.startSession(context.Background(), userID)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/logout", nil)
+	req.AddCookie(&http.Cookie{Name: refreshCookie, Value: refreshToken})
+	ctx := context.WithValue(req.Context(), auth.UserIDKey, userID)
+	ctx = context.WithValue(ctx, auth.TokenIDKey, "token-id")
+	ctx = context.WithValue(ctx, auth.TokenExpiresKey, time.Now().Add(time.Hour))
+	rr := httptest.NewRecorder()
+
+	h.Logout(rr, req.WithContext(ctx))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	revoked, err := dataStore.IsAccessTokenRevoked(context.Background(), userID, "token-id", 0)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	assert.Equal(t, http.StatusUnauthorized, refresh(h, "", refreshToken).Code)
+
+	rr = httptest.NewRecorder()
+	h.Logout(rr, httptest.NewRequest(http.MethodPost, "/api/user/logout", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func login(h *Handler, username string, remoteAddr string) *httptest.ResponseRecorder {
+	body := `{"login":"` + username + `","password":"wrong"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/user/login", bytes.NewReader([]byte(body)))
+	req.RemoteAddr = remoteAddr
+	rr := httptest.NewRecorder()
+	h.Login(rr, req)
+	return rr
+}
+
+func TestLoginThrottling(t *testing.T) {
+	h, _, _ := newAuthHandler(t)
+	h.cfg.LoginMaxAttempts = 2
+	h.cfg.LoginIPMaxAttempts = 3
+	h.cfg.LoginLockout = 60
+	h.cfg.LoginMaxLockout = 600
+	h.cfg.LoginWindow = 15
+
+	rr := login(h, "user", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"error":"incorrect pair username and password"}`, rr.Body.String())
+
+	rr = login(h, "user", "10.0.0.2:1234")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "the attempt which reaches the limit is still answered")
+
+	rr = login(h, "user", "10.0.0.3:1234")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "username is locked from another address")
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"too many login attempts, try later"}`, rr.Body.String())
+
+	for _, username := range []string{"first", "second", "third"} {
+		rr = login(h, username, "10.0.0.9:1234")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "unknown users are answered as a wrong password")
+	}
+	rr = login(h, "user-4", "10.0.0.9:4321")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "address is locked for every username")
+}
+
+func TestLoginUnknownUserVerifiesDummyHash(t *testing.T) {
+	h, _, _ := newAuthHandler(t)
+	usePasswordConfig(h)
+
+	rr := login(h, "unknown", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.True(t, strings.HasPrefix(h.dummyHash, "$argon2id$v=19$m=64,t=1,p=1$"),
+		"unknown users pay the configured hash cost: %q", h.dummyHash)
+
+	valid, _ := h.passwordHasher().Verify("wrong", h.dummyHash)
+	assert.False(t, valid)
+}
+
+func usePasswordConfig(h *Handler) {
+	h.cfg.PasswordMinLength = 8
+	h.cfg.PasswordMinClasses = 3
+	h.cfg.PasswordHash = secure.AlgorithmArgon2id
+	h.cfg.BcryptCost = bcrypt.MinCost
+	h.cfg.Argon2Time, h.cfg.Argon2Memory, h.cfg.Argon2Threads = 1, 64, 1
+}
+
+func TestRegisterPasswordPolicy(t *testing.T) {
+	h, dataStore, _ := newAuthHandler(t)
+	usePasswordConfig(h)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "too short",
+			body:           `{"login":"gopher","password":"Ab1!"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"password is too weak: it must be at least 8 characters long"}`,
+		},
+		{
+			name:           "common password",
+			body:           `{"login":"gopher","password":"Password1"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"password is too weak: it is too common"}`,
+		},
+		{
+			name:           "strong password",
+			body:           `{"login":"gopher","password":"Tr0ub4dor&3"}`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/register", bytes.NewReader([]byte(tt.body)))
+			rr := httptest.NewRecorder()
+			h.Register(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+
+	user, err := dataStore.GetUser(context.Background(), "gopher")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(user.PasswordHash, "$argon2id$"))
+}
+
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	h, dataStore, _ := newAuthHandler(t)
+	usePasswordConfig(h)
+
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte("Tr0ub4dor&3"), bcrypt.MinCost)
+	require.NoError(t, err)
+	userID, err := dataStore.AddUser(context.Background(), "legacy", string(legacyHash))
+	require.NoError(t, err)
+
+	body := `{"login":"legacy","password":"Tr0ub4dor&3"}`
+	rr := httptest.NewRecorder()
+	h.Login(rr, httptest.NewRequest(http.MethodPost, "/api/user/login", bytes.NewReader([]byte(body))))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	user, err := dataStore.GetUser(context.Background(), "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, userID, user.ID)
+	assert.True(t, strings.HasPrefix(user.PasswordHash, "$argon2id$"), "bcrypt hash is replaced on login")
+
+	valid, rehash := h.passwordHasher().Verify("Tr0ub4dor&3", user.PasswordHash)
+	assert.True(t, valid)
+	assert.False(t, rehash)
+}
@@ -1,17 +1,40 @@
 package handlers
 
 import (
+	"TimBerk/gophermart/internal/app/converter"
 	model "TimBerk/gophermart/internal/app/models/balance"
+	"TimBerk/gophermart/internal/app/store"
 	"TimBerk/gophermart/pkg/responses"
 	"TimBerk/gophermart/pkg/validators"
 	"encoding/json"
 	"errors"
-	"github.com/jackc/pgx/v5"
 	"github.com/mailru/easyjson"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"strconv"
 )
 
+const (
+	historyDefaultLimit = 50
+	historyMaxLimit     = 500
+
+	withdrawalsDefaultLimit = 50
+	withdrawalsMaxLimit     = 500
+)
+
+// withdrawalPageParams switch GET /api/user/withdrawals to pages, without them all withdrawals are returned.
+var withdrawalPageParams = []string{"limit", "cursor", "from", "to", "summary"}
+
+func isWithdrawalPageRequest(r *http.Request) bool {
+	query := r.URL.Query()
+	for _, param := range withdrawalPageParams {
+		if query.Has(param) {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *Handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -21,14 +44,12 @@ func (h *Handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logFields := initLogFields(logrus.Fields{"action": action, "user": userID})
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
 
 	var errMessage string
-	balance, err := h.store.GetBalance(h.ctx, userID)
+	balance, err := h.store.GetBalance(r.Context(), userID)
 	if err != nil {
-		errMessage = "failed to find balance"
-		logFields.WithField("error", err).Error(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
+		writeStoreError(w, err, "failed to find balance", logFields)
 		return
 	}
 
@@ -56,85 +77,173 @@ func (h *Handler) WithdrawBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logFields := initLogFields(logrus.Fields{"action": action, "user": userID})
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
 
 	var errMessage string
-	balance, err := h.store.GetBalance(h.ctx, userID)
+	var requestData model.WithdrawnRequest
+	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
-		errMessage = "failed to get balance"
+		errMessage = "failed to parse request data"
 		logFields.WithField("error", err).Error(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
+		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
 		return
 	}
-	if balance.Current <= 0.00 {
-		errMessage = "failed to use balance: it's empty"
+
+	err = requestData.Validate()
+	if err != nil {
+		errMessage = "failed to validate request data"
 		logFields.WithField("error", err).Error(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusPaymentRequired)
+		responses.WriteJSONError(w, errMessage, http.StatusUnprocessableEntity)
 		return
 	}
 
-	var requestData model.WithdrawnRequest
-	err = json.NewDecoder(r.Body).Decode(&requestData)
+	// The balance is checked by the store under the row lock, a separate read here would race with other withdrawals.
+	err = h.store.AddWithdrawal(r.Context(), userID, requestData.Number, requestData.Sum)
 	if err != nil {
-		errMessage = "failed to parse request data"
+		writeStoreError(w, err, "failed to update order", logFields)
+		return
+	}
+}
+
+func (h *Handler) GetWithdraw(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var errMessage string
+	action := "GetWithdraw"
+
+	userID, ok := validators.ValidateAuthorization(w, r, action)
+	if !ok {
+		return
+	}
+
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
+
+	if isWithdrawalPageRequest(r) {
+		h.getWithdrawalPage(w, r, userID, logFields)
+		return
+	}
+
+	records, err := h.store.GetOrderWithdrawals(r.Context(), userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeStoreError(w, err, "failed to find orders", logFields)
+		return
+	}
+	if len(records) == 0 {
+		logFields.Info("Not found user orders")
+		responses.WriteJSONEmpty(w, http.StatusNoContent)
+		return
+	}
+
+	jsonRecords, err := easyjson.Marshal(records)
+	if err != nil {
+		errMessage = "failed to parse orders"
 		logFields.WithField("error", err).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
 		return
 	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonRecords)
+}
 
-	err = requestData.Validate()
+// getWithdrawalPage returns a page of withdrawals, newest first, the next page is announced in headers.
+// With summary=true the page is wrapped in an object with the count and the sum of the whole period.
+func (h *Handler) getWithdrawalPage(w http.ResponseWriter, r *http.Request, userID int64, logFields *logrus.Entry) {
+	var errMessage string
+
+	filter, err := parsePageFilter(r, withdrawalsDefaultLimit, withdrawalsMaxLimit)
+	var withSummary bool
+	if value := r.URL.Query().Get("summary"); err == nil && value != "" {
+		withSummary, err = strconv.ParseBool(value)
+	}
 	if err != nil {
-		errMessage = "failed to validate request data"
-		logFields.WithField("error", err).Error(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusUnprocessableEntity)
+		errMessage = "failed to validate request params"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
 		return
 	}
 
-	if balance.Current-float64(requestData.Sum) < 0.00 {
-		errMessage = "failed to use balance: it's less than sum"
-		logFields.WithField("error", err).Error(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusPaymentRequired)
+	records, next, err := h.store.GetWithdrawalPage(r.Context(), userID, filter)
+	if err != nil {
+		writeStoreError(w, err, "failed to find withdrawals", logFields)
 		return
 	}
+	if records == nil {
+		records = model.WithdrawnList{}
+	}
 
-	err = h.store.AddWithdrawal(h.ctx, userID, requestData.Number, requestData.Sum)
+	var jsonRecords []byte
+	if withSummary {
+		summary, errSummary := h.store.GetWithdrawalSummary(r.Context(), userID, filter)
+		if errSummary != nil {
+			writeStoreError(w, errSummary, "failed to sum withdrawals", logFields)
+			return
+		}
+		jsonRecords, err = easyjson.Marshal(model.WithdrawnPage{Withdrawals: records, Summary: &summary})
+	} else {
+		if len(records) == 0 && filter.After == nil {
+			logFields.Info("Not found user withdrawals")
+			responses.WriteJSONEmpty(w, http.StatusNoContent)
+			return
+		}
+		jsonRecords, err = easyjson.Marshal(records)
+	}
 	if err != nil {
-		errMessage = "failed to update order"
+		errMessage = "failed to parse withdrawals"
 		logFields.WithField("error", err).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
 		return
 	}
+
+	if next != nil {
+		writeNextPage(w, r, next.String())
+	}
+	logFields.WithField("count", len(records)).Info("Return page of withdrawals")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonRecords)
 }
 
-func (h *Handler) GetWithdraw(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetBalanceHistory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var errMessage string
-	action := "GetWithdraw"
+	action := "GetBalanceHistory"
 
 	userID, ok := validators.ValidateAuthorization(w, r, action)
 	if !ok {
 		return
 	}
 
-	logFields := initLogFields(logrus.Fields{"action": action, "user": userID})
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
 
-	records, err := h.store.GetOrderWithdrawals(h.ctx, userID)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		errMessage = "failed to find orders"
-		logFields.WithField("error", err).Error(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+	limit, cursor, err := parsePage(r, historyDefaultLimit, historyMaxLimit)
+	if err != nil {
+		errMessage = "failed to validate request params"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
 		return
 	}
-	if len(records) == 0 {
-		logFields.Info("Not found user orders")
+
+	records, err := h.store.GetLedgerEntries(r.Context(), userID, cursor, limit+1)
+	if err != nil {
+		writeStoreError(w, err, "failed to find balance history", logFields)
+		return
+	}
+	if len(records) == 0 && cursor == 0 {
+		logFields.Info("Not found user balance history")
 		responses.WriteJSONEmpty(w, http.StatusNoContent)
 		return
 	}
 
-	jsonRecords, err := easyjson.Marshal(records)
+	var response model.HistoryResponse
+	if len(records) > limit {
+		records = records[:limit]
+		response.NextCursor = strconv.FormatInt(records[len(records)-1].ID, 10)
+	}
+	response.Entries = converter.LedgerDBToHistoryAPI(records)
+
+	jsonRecords, err := easyjson.Marshal(response)
 	if err != nil {
-		errMessage = "failed to parse orders"
+		errMessage = "failed to parse balance history"
 		logFields.WithField("error", err).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
 		return
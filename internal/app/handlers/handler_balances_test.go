@@ -3,16 +3,21 @@ package handlers
 import (
 	"TimBerk/gophermart/internal/app/middlewares/auth"
 	model "TimBerk/gophermart/internal/app/models/balance"
+	storeModel "TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/money"
+	"TimBerk/gophermart/pkg/secure"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
-	"github.com/jackc/pgx/v5"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 )
@@ -32,8 +37,8 @@ func TestGetBalance(t *testing.T) {
 			setupMocks: func(store *MockStore) {
 				store.On("GetBalance", mock.Anything, mockUserID).Return(
 					model.Balance{
-						Current:   100.5,
-						Withdrawn: 20.0,
+						Current:   money.MustParse("100.5"),
+						Withdrawn: money.FromUnits(20),
 					}, nil)
 			},
 			setupRequest: func() *http.Request {
@@ -64,7 +69,7 @@ func TestGetBalance(t *testing.T) {
 				reqCtx := context.WithValue(req.Context(), auth.UserIDKey, mockUserID)
 				return req.WithContext(reqCtx)
 			},
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusInternalServerError,
 			expectedBody: `{"error":"failed to find balance"}`,
 		},
 	}
@@ -73,7 +78,7 @@ func TestGetBalance(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStore := new(MockStore)
 			tt.setupMocks(mockStore)
-			handler := &Handler{store: mockStore, ctx: context.Background()}
+			handler := &Handler{store: mockStore}
 
 			req := tt.setupRequest()
 			rr := httptest.NewRecorder()
@@ -101,11 +106,9 @@ func TestWithdrawBalance(t *testing.T) {
 		{
 			name: "successful withdrawal",
 			setupMocks: func(store *MockStore) {
-				store.On("GetBalance", mock.Anything, mockUserID).Return(
-					model.Balance{Current: 100.0}, nil)
-				store.On("AddWithdrawal", mock.Anything, mockUserID, mockOrderID, 50.0).Return(nil)
+				store.On("AddWithdrawal", mock.Anything, mockUserID, mockOrderID, money.FromUnits(50)).Return(nil)
 			},
-			requestBody:    model.WithdrawnRequest{Number: mockOrderID, Sum: 50.0},
+			requestBody:    model.WithdrawnRequest{Number: mockOrderID, Sum: money.FromUnits(50)},
 			isAuth:         true,
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
@@ -113,39 +116,39 @@ func TestWithdrawBalance(t *testing.T) {
 		{
 			name:           "unauthorized access",
 			setupMocks:     func(*MockStore) {},
-			requestBody:    model.WithdrawnRequest{Number: mockOrderID, Sum: 50.0},
+			requestBody:    model.WithdrawnRequest{Number: mockOrderID, Sum: money.FromUnits(50)},
 			expectedStatus: http.StatusUnauthorized,
 			expectedBody:   `{"error":"User is not authorized"}`,
 		},
 		{
-			name: "empty balance",
-			setupMocks: func(store *MockStore) {
-				store.On("GetBalance", mock.Anything, mockUserID).Return(
-					model.Balance{Current: 0.0}, nil)
-			},
-			requestBody:    model.WithdrawnRequest{Number: mockOrderID, Sum: 50.0},
-			isAuth:         true,
-			expectedStatus: http.StatusPaymentRequired,
-			expectedBody:   `{"error":"failed to use balance: it's empty"}`,
-		},
-		{
-			name: "invalid request body",
-			setupMocks: func(store *MockStore) {
-				store.On("GetBalance", mock.Anything, mockUserID).Return(
-					model.Balance{Current: 100.0}, nil)
-			},
+			name:           "invalid request body",
+			setupMocks:     func(*MockStore) {},
 			requestBody:    "invalid json",
 			isAuth:         true,
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"error":"failed to parse request data"}`,
 		},
 		{
-			name: "validation error",
-			setupMocks: func(store *MockStore) {
-				store.On("GetBalance", mock.Anything, mockUserID).Return(
-					model.Balance{Current: 100.0}, nil)
-			},
-			requestBody:    model.WithdrawnRequest{Number: "", Sum: 50.0},
+			name:           "validation error",
+			setupMocks:     func(*MockStore) {},
+			requestBody:    model.WithdrawnRequest{Number: "", Sum: money.FromUnits(50)},
+			isAuth:         true,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error":"failed to validate request data"}`,
+		},
+		{
+			// A zero withdrawal would use up the order number without a ledger entry.
+			name:           "zero sum",
+			setupMocks:     func(*MockStore) {},
+			requestBody:    model.WithdrawnRequest{Number: mockOrderID},
+			isAuth:         true,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error":"failed to validate request data"}`,
+		},
+		{
+			name:           "negative sum",
+			setupMocks:     func(*MockStore) {},
+			requestBody:    model.WithdrawnRequest{Number: mockOrderID, Sum: money.FromUnits(-5)},
 			isAuth:         true,
 			expectedStatus: http.StatusUnprocessableEntity,
 			expectedBody:   `{"error":"failed to validate request data"}`,
@@ -153,10 +156,10 @@ func TestWithdrawBalance(t *testing.T) {
 		{
 			name: "insufficient funds",
 			setupMocks: func(store *MockStore) {
-				store.On("GetBalance", mock.Anything, mockUserID).Return(
-					model.Balance{Current: 30.0}, nil)
+				store.On("AddWithdrawal", mock.Anything, mockUserID, mockOrderID, money.FromUnits(50)).Return(
+					storeModel.ErrInsufficientFunds)
 			},
-			requestBody:    model.WithdrawnRequest{Number: mockOrderID, Sum: 50.0},
+			requestBody:    model.WithdrawnRequest{Number: mockOrderID, Sum: money.FromUnits(50)},
 			isAuth:         true,
 			expectedStatus: http.StatusPaymentRequired,
 			expectedBody:   `{"error":"failed to use balance: it's less than sum"}`,
@@ -164,12 +167,10 @@ func TestWithdrawBalance(t *testing.T) {
 		{
 			name: "database error on withdrawal",
 			setupMocks: func(store *MockStore) {
-				store.On("GetBalance", mock.Anything, mockUserID).Return(
-					model.Balance{Current: 100.0}, nil)
-				store.On("AddWithdrawal", mock.Anything, mockUserID, mockOrderID, 50.0).Return(
+				store.On("AddWithdrawal", mock.Anything, mockUserID, mockOrderID, money.FromUnits(50)).Return(
 					errors.New("db error"))
 			},
-			requestBody:    model.WithdrawnRequest{Number: mockOrderID, Sum: 50.0},
+			requestBody:    model.WithdrawnRequest{Number: mockOrderID, Sum: money.FromUnits(50)},
 			isAuth:         true,
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"error":"failed to update order"}`,
@@ -180,7 +181,7 @@ func TestWithdrawBalance(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStore := new(MockStore)
 			tt.setupMocks(mockStore)
-			h := &Handler{store: mockStore, ctx: context.Background()}
+			h := &Handler{store: mockStore}
 
 			var bodyBytes []byte
 			switch v := tt.requestBody.(type) {
@@ -209,6 +210,54 @@ func TestWithdrawBalance(t *testing.T) {
 	}
 }
 
+func TestWithdrawBalanceParallel(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	ctx := context.Background()
+	dataStore := storeModel.NewMemoryStore()
+	userID, err := dataStore.AddUser(ctx, "user", "hash")
+	require.NoError(t, err)
+	require.NoError(t, dataStore.AddOrder(ctx, userID, mockOrderID))
+	require.NoError(t, dataStore.UpdateOrderStatus(ctx, userID, mockOrderID, storeModel.Processed, money.FromUnits(100)))
+	h := &Handler{store: dataStore}
+
+	const requests = 20
+	var numbers []string
+	for number := int64(1000); len(numbers) < requests; number++ {
+		if secure.CheckLuhn(number) {
+			numbers = append(numbers, strconv.FormatInt(number, 10))
+		}
+	}
+
+	statuses := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body, _ := json.Marshal(model.WithdrawnRequest{Number: numbers[i], Sum: money.FromUnits(40)})
+			req := httptest.NewRequest("POST", "/withdraw", bytes.NewReader(body))
+			req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, userID))
+			rr := httptest.NewRecorder()
+			h.WithdrawBalance(rr, req)
+			statuses <- rr.Code
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	assert.Equal(t, map[int]int{http.StatusOK: 2, http.StatusPaymentRequired: requests - 2}, counts)
+
+	balance, err := dataStore.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromUnits(20), balance.Current)
+	assert.Equal(t, money.FromUnits(80), balance.Withdrawn)
+}
+
 func TestHandler_GetWithdraw(t *testing.T) {
 	logrus.SetLevel(logrus.PanicLevel)
 	testTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -225,7 +274,7 @@ func TestHandler_GetWithdraw(t *testing.T) {
 			setupMocks: func(store *MockStore) {
 				store.On("GetOrderWithdrawals", mock.Anything, mockUserID).Return(
 					model.WithdrawnList{
-						model.WithdrawnResponse{Number: mockOrderID, Sum: 50.0, CreatedAt: testTime},
+						model.WithdrawnResponse{Number: mockOrderID, Sum: money.FromUnits(50), CreatedAt: testTime},
 					}, nil)
 			},
 			isAuth:         true,
@@ -252,7 +301,7 @@ func TestHandler_GetWithdraw(t *testing.T) {
 			name: "no rows error - treated as empty list",
 			setupMocks: func(store *MockStore) {
 				store.On("GetOrderWithdrawals", mock.Anything, mockUserID).Return(
-					model.WithdrawnList{}, pgx.ErrNoRows)
+					model.WithdrawnList{}, storeModel.ErrNotFound)
 			},
 			isAuth:         true,
 			expectedStatus: http.StatusNoContent,
@@ -274,7 +323,7 @@ func TestHandler_GetWithdraw(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStore := new(MockStore)
 			tt.setupMocks(mockStore)
-			h := &Handler{store: mockStore, ctx: context.Background()}
+			h := &Handler{store: mockStore}
 
 			req := httptest.NewRequest("GET", "/withdrawals", nil)
 			if tt.isAuth {
@@ -295,3 +344,177 @@ func TestHandler_GetWithdraw(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBalanceHistory(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+	testTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []storeModel.LedgerEntry{
+		{ID: 3, UserID: mockUserID, Kind: storeModel.LedgerWithdrawal, Debit: storeModel.AccountCurrent,
+			Credit: storeModel.AccountWithdrawn, Amount: money.FromUnits(50), Order: mockOrderID, CreatedAt: testTime},
+		{ID: 2, UserID: mockUserID, Kind: storeModel.LedgerAccrual, Debit: storeModel.AccountAccrual,
+			Credit: storeModel.AccountCurrent, Amount: money.MustParse("100.5"), Order: "12345678903", CreatedAt: testTime},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		setupMocks     func(*MockStore)
+		isAuth         bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:  "first page with next cursor",
+			query: "?limit=1",
+			setupMocks: func(store *MockStore) {
+				store.On("GetLedgerEntries", mock.Anything, mockUserID, int64(0), 2).Return(entries, nil)
+			},
+			isAuth:         true,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"entries":[{"id":3,"type":"WITHDRAWAL","debit":"current","credit":"withdrawn","amount":50,
+				"order":"50405077004","created_at":"2023-01-01T00:00:00Z"}],"next_cursor":"3"}`,
+		},
+		{
+			name:  "last page",
+			query: "?cursor=3",
+			setupMocks: func(store *MockStore) {
+				store.On("GetLedgerEntries", mock.Anything, mockUserID, int64(3), 51).Return(entries[1:], nil)
+			},
+			isAuth:         true,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"entries":[{"id":2,"type":"ACCRUAL","debit":"accrual","credit":"current","amount":100.5,
+				"order":"12345678903","created_at":"2023-01-01T00:00:00Z"}]}`,
+		},
+		{
+			name:  "empty history",
+			query: "",
+			setupMocks: func(store *MockStore) {
+				store.On("GetLedgerEntries", mock.Anything, mockUserID, int64(0), 51).Return([]storeModel.LedgerEntry{}, nil)
+			},
+			isAuth:         true,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "invalid limit",
+			query:          "?limit=0",
+			setupMocks:     func(*MockStore) {},
+			isAuth:         true,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"failed to validate request params"}`,
+		},
+		{
+			name:           "invalid cursor",
+			query:          "?cursor=abc",
+			setupMocks:     func(*MockStore) {},
+			isAuth:         true,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"failed to validate request params"}`,
+		},
+		{
+			name:           "unauthorized access",
+			setupMocks:     func(*MockStore) {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"User is not authorized"}`,
+		},
+		{
+			name: "database error",
+			setupMocks: func(store *MockStore) {
+				store.On("GetLedgerEntries", mock.Anything, mockUserID, int64(0), 51).Return(
+					[]storeModel.LedgerEntry{}, errors.New("db error"))
+			},
+			isAuth:         true,
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"failed to find balance history"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			tt.setupMocks(mockStore)
+			h := &Handler{store: mockStore}
+
+			req := httptest.NewRequest("GET", "/balance/history"+tt.query, nil)
+			if tt.isAuth {
+				reqCtx := context.WithValue(req.Context(), auth.UserIDKey, mockUserID)
+				req = req.WithContext(reqCtx)
+			}
+			rr := httptest.NewRecorder()
+
+			h.GetBalanceHistory(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			} else {
+				assert.Empty(t, rr.Body.String())
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetWithdrawPage(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	ctx := context.Background()
+	dataStore := storeModel.NewMemoryStore()
+	userID, err := dataStore.AddUser(ctx, "user", "hash")
+	require.NoError(t, err)
+	tx, err := dataStore.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, dataStore.AddBalance(ctx, tx, userID, money.FromUnits(100)))
+	require.NoError(t, tx.Commit(ctx))
+
+	numbers := []string{"12345678903", "49927398716", "79927398713"}
+	for _, number := range numbers {
+		require.NoError(t, dataStore.AddWithdrawal(ctx, userID, number, money.FromUnits(10)))
+	}
+	h := &Handler{store: dataStore}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, userID))
+		rr := httptest.NewRecorder()
+		h.GetWithdraw(rr, req)
+		return rr
+	}
+	numbersOf := func(records model.WithdrawnList) []string {
+		var result []string
+		for _, record := range records {
+			result = append(result, record.Number)
+		}
+		return result
+	}
+
+	rr := get("/api/user/withdrawals?limit=2")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var records model.WithdrawnList
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &records))
+	assert.Equal(t, []string{numbers[2], numbers[1]}, numbersOf(records))
+	cursor := rr.Header().Get("X-Next-Cursor")
+	require.NotEmpty(t, cursor)
+	assert.Equal(t, `</api/user/withdrawals?cursor=`+cursor+`&limit=2>; rel="next"`, rr.Header().Get("Link"))
+
+	rr = get("/api/user/withdrawals?limit=2&summary=true&cursor=" + cursor)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var page model.WithdrawnPage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Equal(t, []string{numbers[0]}, numbersOf(page.Withdrawals))
+	require.NotNil(t, page.Summary)
+	assert.Equal(t, model.WithdrawnSummary{Count: 3, Sum: money.FromUnits(30)}, *page.Summary)
+	assert.Empty(t, rr.Header().Get("X-Next-Cursor"))
+
+	rr = get("/api/user/withdrawals?from=2000-01-01&to=2000-02-01")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = get("/api/user/withdrawals?from=2000-01-01&to=2000-02-01&summary=1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"withdrawals":[],"summary":{"count":0,"sum":0}}`, rr.Body.String())
+
+	for _, query := range []string{"limit=0", "cursor=abc", "from=yesterday", "to=2025-13-01", "summary=maybe"} {
+		rr = get("/api/user/withdrawals?" + query)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		assert.JSONEq(t, `{"error":"failed to validate request params"}`, rr.Body.String())
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"TimBerk/gophermart/internal/app/models/balance"
+	"TimBerk/gophermart/internal/app/models/order"
+	"TimBerk/gophermart/pkg/responses"
+	"TimBerk/gophermart/pkg/validators"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/mailru/easyjson"
+	"github.com/mailru/easyjson/jwriter"
+	"github.com/sirupsen/logrus"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+
+	// exportFlushRows is how many rows are buffered before they are flushed to the client.
+	exportFlushRows = 100
+)
+
+var errUnsupportedExportFormat = errors.New("unsupported export format")
+
+var exportContentTypes = map[string]string{
+	exportFormatCSV:    "text/csv; charset=utf-8",
+	exportFormatNDJSON: "application/x-ndjson",
+}
+
+var exportMediaTypes = map[string]string{
+	"text/csv":             exportFormatCSV,
+	"application/x-ndjson": exportFormatNDJSON,
+	"application/ndjson":   exportFormatNDJSON,
+	"application/jsonl":    exportFormatNDJSON,
+}
+
+var (
+	orderExportColumns      = []string{"number", "status", "accrual", "uploaded_at"}
+	withdrawalExportColumns = []string{"order", "sum", "processed_at"}
+)
+
+// exportFormat picks the format by the format parameter, otherwise by the Accept header. CSV is the default.
+func exportFormat(r *http.Request) (string, error) {
+	if value := r.URL.Query().Get("format"); value != "" {
+		format := strings.ToLower(value)
+		if _, ok := exportContentTypes[format]; !ok {
+			return "", fmt.Errorf("%w: %s", errUnsupportedExportFormat, value)
+		}
+		return format, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return exportFormatCSV, nil
+	}
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		if format, ok := exportMediaTypes[mediaType]; ok {
+			return format, nil
+		}
+		if mediaType == "*/*" || mediaType == "text/*" {
+			return exportFormatCSV, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", errUnsupportedExportFormat, accept)
+}
+
+// exportWriter writes rows as soon as the store reads them. The status and the headers are sent
+// with the first row, so an error before it can still be reported as JSON.
+type exportWriter struct {
+	w        http.ResponseWriter
+	format   string
+	filename string
+	columns  []string
+	csv      *csv.Writer
+	rows     int
+}
+
+func newExportWriter(w http.ResponseWriter, format string, filename string, columns []string) *exportWriter {
+	return &exportWriter{w: w, format: format, filename: filename, columns: columns}
+}
+
+func (e *exportWriter) started() bool {
+	return e.rows > 0 || e.csv != nil
+}
+
+func (e *exportWriter) start() error {
+	e.w.Header().Set("Content-Type", exportContentTypes[e.format])
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, e.filename, e.format))
+	e.w.WriteHeader(http.StatusOK)
+
+	if e.format == exportFormatCSV {
+		e.csv = csv.NewWriter(e.w)
+		return e.csv.Write(e.columns)
+	}
+	return nil
+}
+
+// write adds a row, values are used by CSV and record by NDJSON.
+func (e *exportWriter) write(record easyjson.Marshaler, values []string) error {
+	if !e.started() {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if e.format == exportFormatCSV {
+		err = e.csv.Write(values)
+	} else {
+		jw := jwriter.Writer{}
+		record.MarshalEasyJSON(&jw)
+		jw.RawByte('\n')
+		_, err = jw.DumpTo(e.w)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.rows++
+	if e.rows%exportFlushRows == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+// finish sends the CSV header of an empty export and flushes the rest.
+func (e *exportWriter) finish() error {
+	if !e.started() {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+func (e *exportWriter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if flusher, ok := e.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// exportRequest reads the format and the date range shared by export endpoints.
+func (h *Handler) exportRequest(w http.ResponseWriter, r *http.Request, logFields *logrus.Entry) (string, time.Time, time.Time, bool) {
+	var errMessage string
+
+	format, err := exportFormat(r)
+	if err != nil {
+		errMessage = "failed to choose export format"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusNotAcceptable)
+		return "", time.Time{}, time.Time{}, false
+	}
+
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		errMessage = "failed to validate request params"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return "", time.Time{}, time.Time{}, false
+	}
+	return format, from, to, true
+}
+
+// finishExport reports a failed export. Once rows are sent the status can't change,
+// the connection is aborted so the client doesn't take a truncated file for a complete one.
+func finishExport(w http.ResponseWriter, out *exportWriter, err error, logFields *logrus.Entry) {
+	if err == nil {
+		err = out.finish()
+	}
+	if err == nil {
+		logFields.WithField("rows", out.rows).Info("export finished")
+		return
+	}
+
+	errMessage := "failed to export records"
+	logFields.WithFields(logrus.Fields{"error": err, "rows": out.rows}).Error(errMessage)
+	if out.started() {
+		panic(http.ErrAbortHandler)
+	}
+	responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+}
+
+// ExportOrders streams the orders of the user as CSV or NDJSON, oldest first.
+func (h *Handler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	action := "ExportOrders"
+	userID, ok := validators.ValidateAuthorization(w, r, action)
+	if !ok {
+		return
+	}
+
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
+
+	format, from, to, ok := h.exportRequest(w, r, logFields)
+	if !ok {
+		return
+	}
+	logFields = logFields.WithField("format", format)
+
+	out := newExportWriter(w, format, "orders", orderExportColumns)
+	err := h.store.ExportOrders(r.Context(), userID, from, to, func(record order.OrderResponse) error {
+		var accrual string
+		if record.Accrual != nil {
+			accrual = record.Accrual.String()
+		}
+		return out.write(record, []string{record.Number, record.Status, accrual, record.CreatedAt.Format(time.RFC3339)})
+	})
+	finishExport(w, out, err, logFields)
+}
+
+// ExportWithdrawals streams the withdrawals of the user as CSV or NDJSON, oldest first.
+func (h *Handler) ExportWithdrawals(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	action := "ExportWithdrawals"
+	userID, ok := validators.ValidateAuthorization(w, r, action)
+	if !ok {
+		return
+	}
+
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
+
+	format, from, to, ok := h.exportRequest(w, r, logFields)
+	if !ok {
+		return
+	}
+	logFields = logFields.WithField("format", format)
+
+	out := newExportWriter(w, format, "withdrawals", withdrawalExportColumns)
+	err := h.store.ExportWithdrawals(r.Context(), userID, from, to, func(record balance.WithdrawnResponse) error {
+		return out.write(record, []string{record.Number, record.Sum.String(), record.CreatedAt.Format(time.RFC3339)})
+	})
+	finishExport(w, out, err, logFields)
+}
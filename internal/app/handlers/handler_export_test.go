@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"TimBerk/gophermart/internal/app/middlewares/auth"
+	model "TimBerk/gophermart/internal/app/models/balance"
+	"TimBerk/gophermart/internal/app/models/order"
+	storeModel "TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/money"
+	"context"
+	"encoding/csv"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		accept   string
+		expected string
+		wantErr  bool
+	}{
+		{name: "default", expected: exportFormatCSV},
+		{name: "any type", accept: "*/*", expected: exportFormatCSV},
+		{name: "csv by header", accept: "text/csv", expected: exportFormatCSV},
+		{name: "ndjson by header", accept: "application/json;q=0.9, application/x-ndjson", expected: exportFormatNDJSON},
+		{name: "parameter wins over header", query: "format=NDJSON", accept: "text/csv", expected: exportFormatNDJSON},
+		{name: "unknown parameter", query: "format=xml", wantErr: true},
+		{name: "unsupported header", accept: "application/xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/user/orders/export?"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			format, err := exportFormat(req)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errUnsupportedExportFormat)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, format)
+		})
+	}
+}
+
+func TestExportOrders(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	ctx := context.Background()
+	dataStore := storeModel.NewMemoryStore()
+	userID, err := dataStore.AddUser(ctx, "user", "hash")
+	require.NoError(t, err)
+	require.NoError(t, dataStore.AddOrder(ctx, userID, "12345678903"))
+	require.NoError(t, dataStore.AddOrder(ctx, userID, "49927398716"))
+	require.NoError(t, dataStore.UpdateOrderStatus(ctx, userID, "12345678903", storeModel.Processed, money.FromUnits(5)))
+	h := &Handler{store: dataStore}
+
+	get := func(target string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, userID))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		h.ExportOrders(rr, req)
+		return rr
+	}
+
+	rr := get("/api/user/orders/export", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="orders.csv"`, rr.Header().Get("Content-Disposition"))
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, orderExportColumns, rows[0])
+	assert.Equal(t, []string{"12345678903", "PROCESSED", "5.00"}, rows[1][:3])
+	assert.Equal(t, []string{"49927398716", "NEW", ""}, rows[2][:3])
+
+	rr = get("/api/user/orders/export", "application/x-ndjson")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSuffix(rr.Body.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	var record order.OrderResponse
+	require.NoError(t, record.UnmarshalJSON([]byte(lines[0])))
+	assert.Equal(t, "12345678903", record.Number)
+	assert.Equal(t, money.FromUnits(5), *record.Accrual)
+
+	rr = get("/api/user/orders/export?from=2000-01-01&to=2000-02-01", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "number,status,accrual,uploaded_at\n", rr.Body.String(), "empty export keeps the header")
+
+	rr = get("/api/user/orders/export?format=xml", "")
+	assert.Equal(t, http.StatusNotAcceptable, rr.Code)
+
+	rr = get("/api/user/orders/export?from=yesterday", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"error":"failed to validate request params"}`, rr.Body.String())
+}
+
+func TestExportWithdrawalsFailure(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	userID := int64(1)
+	dbErr := errors.New("connection reset")
+
+	request := func() *http.Request {
+		req := httptest.NewRequest("GET", "/api/user/withdrawals/export?format=ndjson", nil)
+		return req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, userID))
+	}
+
+	t.Run("before the first row", func(t *testing.T) {
+		mockStore := new(MockStore)
+		mockStore.On("ExportWithdrawals", mock.Anything, userID, time.Time{}, time.Time{}, mock.Anything).Return(dbErr)
+		h := &Handler{store: mockStore}
+
+		rr := httptest.NewRecorder()
+		h.ExportWithdrawals(rr, request())
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.JSONEq(t, `{"error":"failed to export records"}`, rr.Body.String())
+	})
+
+	t.Run("after the first row", func(t *testing.T) {
+		mockStore := new(MockStore)
+		mockStore.On("ExportWithdrawals", mock.Anything, userID, time.Time{}, time.Time{}, mock.Anything).
+			Run(func(args mock.Arguments) {
+				fn := args.Get(4).(func(model.WithdrawnResponse) error)
+				require.NoError(t, fn(model.WithdrawnResponse{Number: "12345678903", Sum: money.FromUnits(1)}))
+			}).
+			Return(dbErr)
+		h := &Handler{store: mockStore}
+
+		rr := httptest.NewRecorder()
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() { h.ExportWithdrawals(rr, request()) })
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
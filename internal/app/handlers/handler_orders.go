@@ -2,17 +2,70 @@ package handlers
 
 import (
 	"TimBerk/gophermart/internal/app/converter"
+	"TimBerk/gophermart/internal/app/models/order"
+	"TimBerk/gophermart/internal/app/store"
 	"TimBerk/gophermart/pkg/responses"
 	"TimBerk/gophermart/pkg/validators"
+	"bytes"
 	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
 	"github.com/mailru/easyjson"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"strings"
 )
 
+const (
+	ordersDefaultLimit = 50
+	ordersMaxLimit     = 500
+
+	ordersBatchMaxItems = 1000
+	ordersBatchMaxBytes = 64 << 10
+)
+
+var errBatchTooLarge = fmt.Errorf("batch must contain at most %d orders", ordersBatchMaxItems)
+
+// orderPageParams switch GET /api/user/orders to pages, without them all orders are returned as the specification says.
+var orderPageParams = []string{"limit", "cursor", "status", "from", "to"}
+
+var orderFilterStatuses = []store.Status{store.New, store.Processing, store.Invalid, store.Processed}
+
+func isOrderPageRequest(r *http.Request) bool {
+	query := r.URL.Query()
+	for _, param := range orderPageParams {
+		if query.Has(param) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOrderFilter reads the page and comma separated statuses.
+func parseOrderFilter(r *http.Request) (store.OrderFilter, error) {
+	page, err := parsePageFilter(r, ordersDefaultLimit, ordersMaxLimit)
+	filter := store.OrderFilter{PageFilter: page}
+	if err != nil {
+		return filter, err
+	}
+
+	if value := r.URL.Query().Get("status"); value != "" {
+		for _, item := range strings.Split(value, ",") {
+			status := store.Status(strings.ToUpper(strings.TrimSpace(item)))
+			if !slices.Contains(orderFilterStatuses, status) {
+				return filter, fmt.Errorf("unknown status %q", item)
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	return filter, nil
+}
+
 func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	var errMessage string
 
@@ -29,7 +82,7 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	orderNumber := string(body)
-	logFields := initLogFields(logrus.Fields{"action": action, "user": userID, "order": orderNumber})
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID, "order": orderNumber})
 
 	err = validators.ValidateOrderNumber(orderNumber)
 	if err != nil {
@@ -39,36 +92,138 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := h.store.GetOrder(h.ctx, orderNumber)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		errMessage = "failed to find order"
-		logFields.WithField("error", err).Warning(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+	// The number is searchable in traces, it links the upload with the checks of the accrual worker.
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("order.number", orderNumber))
+
+	// The store inserts and reports the owner at once, a lookup before the insert would race with parallel uploads.
+	result, err := h.store.UploadOrder(r.Context(), userID, orderNumber)
+	if err != nil {
+		writeStoreError(w, err, "failed to create order", logFields)
 		return
+	}
 
-	} else if errors.Is(err, pgx.ErrNoRows) {
-		err := h.store.AddOrder(h.ctx, userID, orderNumber)
-		if err != nil {
-			errMessage = "failed to create order"
-			logFields.WithField("error", err).Warning(errMessage)
-			responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
-			return
+	switch result {
+	case store.UploadAccepted:
+		w.WriteHeader(http.StatusAccepted)
+	case store.UploadExisting:
+		logFields.Info("order was uploaded")
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeStoreError(w, result.Err(), "failed to create order", logFields)
+	}
+}
+
+// parseOrderBatch reads a JSON array of numbers or one number per line of plain text.
+func parseOrderBatch(r *http.Request, body []byte) ([]string, error) {
+	var numbers []string
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" || bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var request order.OrderUploadRequest
+		if err := easyjson.Unmarshal(body, &request); err != nil {
+			return nil, err
+		}
+		numbers = request
+	} else {
+		numbers = strings.Split(string(body), "\n")
+	}
+
+	batch := make([]string, 0, len(numbers))
+	for _, number := range numbers {
+		if number = strings.TrimSpace(number); number != "" {
+			batch = append(batch, number)
 		}
+	}
+	if len(batch) == 0 {
+		return nil, fmt.Errorf("empty batch")
+	}
+	if len(batch) > ordersBatchMaxItems {
+		return nil, errBatchTooLarge
+	}
+	return batch, nil
+}
 
-		w.WriteHeader(http.StatusAccepted)
+// CreateOrders uploads a batch of orders and returns the result of every number in the order of the request.
+// Valid numbers are inserted in one transaction, invalid ones are reported and skipped.
+func (h *Handler) CreateOrders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var errMessage string
+	action := "CreateOrders"
+
+	userID, ok := validators.ValidateAuthorization(w, r, action)
+	if !ok {
+		return
+	}
+
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, ordersBatchMaxBytes))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		errMessage = fmt.Sprintf("request body must be at most %d bytes", ordersBatchMaxBytes)
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		errMessage = "failed to read request body"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
 		return
 	}
 
-	if order.UserID != userID {
-		errMessage = "failed to check order: it was uploaded another user"
+	numbers, err := parseOrderBatch(r, body)
+	if errors.Is(err, errBatchTooLarge) {
+		errMessage = err.Error()
+		logFields.Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		errMessage = "failed to parse request data"
 		logFields.WithField("error", err).Warning(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusConflict)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
 		return
 	}
 
-	logFields.Info("order was uploaded")
-	w.WriteHeader(http.StatusOK)
+	response := make(order.OrderUploadResponse, len(numbers))
+	valid := make([]string, 0, len(numbers))
+	for i, number := range numbers {
+		response[i].Number = number
+		if errValidate := validators.ValidateOrderNumber(number); errValidate != nil {
+			response[i].Result = string(store.UploadInvalid)
+			response[i].Error = errValidate.Error()
+			continue
+		}
+		if !slices.Contains(valid, number) {
+			valid = append(valid, number)
+		}
+	}
+
+	if len(valid) > 0 {
+		results, errAdd := h.store.AddOrders(r.Context(), userID, valid)
+		if errAdd != nil {
+			writeStoreError(w, errAdd, "failed to create orders", logFields)
+			return
+		}
+		for i := range response {
+			if result, found := results[response[i].Number]; found {
+				response[i].Result = string(result)
+			}
+		}
+	}
+
+	jsonRecords, err := easyjson.Marshal(response)
+	if err != nil {
+		errMessage = "failed to parse orders"
+		logFields.WithField("error", err).Error(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+		return
+	}
 
+	logFields.WithFields(logrus.Fields{"count": len(numbers), "valid": len(valid)}).Info("orders batch was uploaded")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonRecords)
 }
 
 func (h *Handler) GetOrders(w http.ResponseWriter, r *http.Request) {
@@ -80,14 +235,17 @@ func (h *Handler) GetOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logFields := initLogFields(logrus.Fields{"action": action, "user": userID})
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID})
+
+	if isOrderPageRequest(r) {
+		h.getOrderPage(w, r, userID, logFields)
+		return
+	}
 
 	var errMessage string
-	records, err := h.store.GetOrderList(h.ctx, userID)
+	records, err := h.store.GetOrderList(r.Context(), userID)
 	if err != nil {
-		errMessage = "failed to find orders"
-		logFields.WithField("error", err).Error(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+		writeStoreError(w, err, "failed to find orders", logFields)
 		return
 	}
 
@@ -110,6 +268,49 @@ func (h *Handler) GetOrders(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonRecords)
 }
 
+// getOrderPage returns a page of orders, newest first. The next page is announced in headers,
+// so the body keeps the format of the full list.
+func (h *Handler) getOrderPage(w http.ResponseWriter, r *http.Request, userID int64, logFields *logrus.Entry) {
+	var errMessage string
+
+	filter, err := parseOrderFilter(r)
+	if err != nil {
+		errMessage = "failed to validate request params"
+		logFields.WithField("error", err).Warning(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+		return
+	}
+
+	records, next, err := h.store.GetOrderPage(r.Context(), userID, filter)
+	if err != nil {
+		writeStoreError(w, err, "failed to find orders", logFields)
+		return
+	}
+	if len(records) == 0 && filter.After == nil {
+		logFields.Info("Not found user orders")
+		responses.WriteJSONEmpty(w, http.StatusNoContent)
+		return
+	}
+	if records == nil {
+		records = order.OrderListResponse{}
+	}
+
+	jsonRecords, err := easyjson.Marshal(records)
+	if err != nil {
+		errMessage = "failed to parse orders"
+		logFields.WithField("error", err).Error(errMessage)
+		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+		return
+	}
+
+	if next != nil {
+		writeNextPage(w, r, next.String())
+	}
+	logFields.WithField("count", len(records)).Info("Return page of orders")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonRecords)
+}
+
 func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -120,7 +321,7 @@ func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	orderNumber := chi.URLParam(r, "number")
-	logFields := initLogFields(logrus.Fields{"action": action, "user": userID, "order": orderNumber})
+	logFields := initLogFields(r.Context(), logrus.Fields{"action": action, "user": userID, "order": orderNumber})
 
 	var errMessage string
 	err := validators.ValidateOrderNumber(orderNumber)
@@ -131,18 +332,16 @@ func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := h.store.GetOrder(h.ctx, orderNumber)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		errMessage = "failed to find order"
-		logFields.WithField("error", err).Warning(errMessage)
-		responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
-		return
-
-	} else if errors.Is(err, pgx.ErrNoRows) {
+	order, err := h.store.GetOrder(r.Context(), orderNumber)
+	if errors.Is(err, store.ErrNotFound) {
 		logFields.Info("Not found user order")
 		responses.WriteJSONEmpty(w, http.StatusNoContent)
 		return
 	}
+	if err != nil {
+		writeStoreError(w, err, "failed to find order", logFields)
+		return
+	}
 
 	apiItem := converter.OrderDBToOrderAPI(order)
 	jsonRecord, err := easyjson.Marshal(apiItem)
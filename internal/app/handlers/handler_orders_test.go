@@ -2,23 +2,29 @@ package handlers
 
 import (
 	"TimBerk/gophermart/internal/app/middlewares/auth"
+	"TimBerk/gophermart/internal/app/middlewares/timeout"
 	"TimBerk/gophermart/internal/app/models/order"
 	storeModel "TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/money"
 	"bytes"
 	"context"
-	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
-var accrual = 100.0
+var accrual = money.FromUnits(100)
 
 func TestCreateOrder(t *testing.T) {
 	logrus.SetLevel(logrus.PanicLevel)
@@ -35,9 +41,7 @@ func TestCreateOrder(t *testing.T) {
 			name:        "successful order creation",
 			requestBody: "50405077004", // Валидный номер заказа
 			setupMocks: func(store *MockStore) {
-				store.On("GetOrder", mock.Anything, "50405077004").Return(
-					storeModel.OrderRecord{}, pgx.ErrNoRows)
-				store.On("AddOrder", mock.Anything, mockUserID, "50405077004").Return(nil)
+				store.On("UploadOrder", mock.Anything, mockUserID, "50405077004").Return(storeModel.UploadAccepted, nil)
 			},
 			expectedStatus: http.StatusAccepted,
 		},
@@ -52,8 +56,7 @@ func TestCreateOrder(t *testing.T) {
 			name:        "order already exists for this user",
 			requestBody: "50405077004",
 			setupMocks: func(store *MockStore) {
-				store.On("GetOrder", mock.Anything, "50405077004").Return(
-					storeModel.OrderRecord{UserID: mockUserID}, nil)
+				store.On("UploadOrder", mock.Anything, mockUserID, "50405077004").Return(storeModel.UploadExisting, nil)
 			},
 			expectedStatus: http.StatusOK,
 		},
@@ -61,30 +64,17 @@ func TestCreateOrder(t *testing.T) {
 			name:        "order exists for another user",
 			requestBody: "50405077004",
 			setupMocks: func(store *MockStore) {
-				store.On("GetOrder", mock.Anything, "50405077004").Return(
-					storeModel.OrderRecord{UserID: int64(888)}, nil)
+				store.On("UploadOrder", mock.Anything, mockUserID, "50405077004").Return(storeModel.UploadConflict, nil)
 			},
 			expectedStatus: http.StatusConflict,
 			expectedBody:   `{"error":"failed to check order: it was uploaded another user"}`,
 		},
-		{
-			name:        "database error when getting order",
-			requestBody: "50405077004",
-			setupMocks: func(store *MockStore) {
-				store.On("GetOrder", mock.Anything, "50405077004").Return(
-					storeModel.OrderRecord{}, errors.New("db error"))
-			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   `{"error":"failed to find order"}`,
-		},
 		{
 			name:        "database error when adding order",
 			requestBody: "50405077004",
 			setupMocks: func(store *MockStore) {
-				store.On("GetOrder", mock.Anything, "50405077004").Return(
-					storeModel.OrderRecord{}, pgx.ErrNoRows)
-				store.On("AddOrder", mock.Anything, mockUserID, "50405077004").Return(
-					errors.New("db error"))
+				store.On("UploadOrder", mock.Anything, mockUserID, "50405077004").Return(
+					storeModel.UploadResult(""), errors.New("db error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"error":"failed to create order"}`,
@@ -95,7 +85,7 @@ func TestCreateOrder(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStore := new(MockStore)
 			tt.setupMocks(mockStore)
-			h := &Handler{store: mockStore, ctx: context.Background()}
+			h := &Handler{store: mockStore}
 
 			req := httptest.NewRequest("POST", "/orders", bytes.NewBufferString(tt.requestBody))
 			ctx := context.WithValue(req.Context(), auth.UserIDKey, mockUserID)
@@ -158,7 +148,7 @@ func TestGetOrders(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStore := new(MockStore)
 			tt.setupMocks(mockStore)
-			h := &Handler{store: mockStore, ctx: context.Background()}
+			h := &Handler{store: mockStore}
 
 			req := httptest.NewRequest("GET", "/orders", nil)
 			ctx := context.WithValue(req.Context(), auth.UserIDKey, mockUserID)
@@ -178,13 +168,67 @@ func TestGetOrders(t *testing.T) {
 	}
 }
 
+func TestGetOrdersCancelled(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	tests := []struct {
+		name         string
+		cancel       bool
+		timeout      time.Duration
+		expectedErr  error
+		expectedBody string
+	}{
+		{name: "request timeout", timeout: 10 * time.Millisecond, expectedErr: context.DeadlineExceeded, expectedBody: `{"error":"request timed out"}`},
+		{name: "client gone", cancel: true, expectedErr: context.Canceled, expectedBody: `{"error":"request was cancelled"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The query blocks until the context of the request is done, like pgx does with a slow query.
+			var queryErr error
+			mockStore := new(MockStore)
+			mockStore.On("GetOrderList", mock.Anything, mockUserID).
+				Run(func(args mock.Arguments) {
+					ctx := args.Get(0).(context.Context)
+					<-ctx.Done()
+					queryErr = ctx.Err()
+				}).
+				Return(order.OrderListResponse{}, tt.expectedErr)
+			h := &Handler{store: mockStore}
+
+			ctx, cancel := context.WithCancel(context.WithValue(context.Background(), auth.UserIDKey, mockUserID))
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+			req := httptest.NewRequest("GET", "/orders", nil).WithContext(ctx)
+			rr := httptest.NewRecorder()
+
+			done := make(chan struct{})
+			go func() {
+				timeout.Timeout(tt.timeout)(http.HandlerFunc(h.GetOrders)).ServeHTTP(rr, req)
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("query was not aborted")
+			}
+			assert.ErrorIs(t, queryErr, tt.expectedErr)
+			assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
 func TestGetOrder(t *testing.T) {
 	logrus.SetLevel(logrus.PanicLevel)
 	validOrder := storeModel.OrderRecord{
 		Order:   mockOrderID,
 		UserID:  mockUserID,
 		Status:  "PROCESSED",
-		Accrual: sql.NullFloat64{Float64: accrual, Valid: true},
+		Accrual: money.NewNullAmount(accrual),
 	}
 
 	tests := []struct {
@@ -214,7 +258,7 @@ func TestGetOrder(t *testing.T) {
 			name:        "order not found",
 			orderNumber: mockOrderID,
 			setupMocks: func(store *MockStore) {
-				store.On("GetOrder", mock.Anything, mockOrderID).Return(storeModel.OrderRecord{}, pgx.ErrNoRows)
+				store.On("GetOrder", mock.Anything, mockOrderID).Return(storeModel.OrderRecord{}, storeModel.ErrNotFound)
 			},
 			expectedStatus: http.StatusNoContent,
 		},
@@ -233,7 +277,7 @@ func TestGetOrder(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStore := new(MockStore)
 			tt.setupMocks(mockStore)
-			h := &Handler{store: mockStore, ctx: context.Background()}
+			h := &Handler{store: mockStore}
 
 			req := httptest.NewRequest("GET", "/orders/"+tt.orderNumber, nil)
 			ctx := context.WithValue(req.Context(), auth.UserIDKey, mockUserID)
@@ -257,3 +301,252 @@ func TestGetOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestGetOrdersPage(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	ctx := context.Background()
+	dataStore := storeModel.NewMemoryStore()
+	userID, err := dataStore.AddUser(ctx, "user", "hash")
+	require.NoError(t, err)
+	numbers := []string{"12345678903", "49927398716", "79927398713"}
+	for _, number := range numbers {
+		require.NoError(t, dataStore.AddOrder(ctx, userID, number))
+	}
+	require.NoError(t, dataStore.UpdateOrderStatus(ctx, userID, numbers[0], storeModel.Processed, money.FromUnits(100)))
+	h := &Handler{store: dataStore}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, userID))
+		rr := httptest.NewRecorder()
+		h.GetOrders(rr, req)
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) []string {
+		var records order.OrderListResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &records))
+		var result []string
+		for _, record := range records {
+			result = append(result, record.Number)
+		}
+		return result
+	}
+
+	rr := get("/api/user/orders?limit=2")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []string{numbers[2], numbers[1]}, decode(rr))
+	cursor := rr.Header().Get("X-Next-Cursor")
+	require.NotEmpty(t, cursor)
+	assert.Equal(t, `</api/user/orders?cursor=`+cursor+`&limit=2>; rel="next"`, rr.Header().Get("Link"))
+
+	rr = get("/api/user/orders?limit=2&cursor=" + cursor)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []string{numbers[0]}, decode(rr))
+	assert.Empty(t, rr.Header().Get("X-Next-Cursor"))
+	assert.Empty(t, rr.Header().Get("Link"))
+
+	rr = get("/api/user/orders?status=processed")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []string{numbers[0]}, decode(rr))
+
+	rr = get("/api/user/orders?from=2000-01-01&to=2000-01-02")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	for _, query := range []string{"limit=0", "limit=501", "cursor=abc", "status=REGISTERED", "from=yesterday"} {
+		rr = get("/api/user/orders?" + query)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		assert.JSONEq(t, `{"error":"failed to validate request params"}`, rr.Body.String())
+	}
+}
+
+func TestCreateOrders(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	ctx := context.Background()
+	dataStore := storeModel.NewMemoryStore()
+	userID, err := dataStore.AddUser(ctx, "user", "hash")
+	require.NoError(t, err)
+	otherID, err := dataStore.AddUser(ctx, "other", "hash")
+	require.NoError(t, err)
+	require.NoError(t, dataStore.AddOrder(ctx, userID, "12345678903"))
+	require.NoError(t, dataStore.AddOrder(ctx, otherID, "49927398716"))
+	h := &Handler{store: dataStore}
+
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "json array",
+			contentType:  "application/json",
+			body:         `["79927398713", "12345678903", "49927398716", "12345678900", "abc"]`,
+			expectedCode: http.StatusOK,
+			expectedBody: `[
+				{"number":"79927398713","result":"accepted"},
+				{"number":"12345678903","result":"already_uploaded"},
+				{"number":"49927398716","result":"conflict"},
+				{"number":"12345678900","result":"invalid","error":"invalid number"},
+				{"number":"abc","result":"invalid","error":"incorrect number"}
+			]`,
+		},
+		{
+			name:         "plain text with repeated number",
+			contentType:  "text/plain",
+			body:         "2377225624\r\n\n 2377225624 \n79927398713\n",
+			expectedCode: http.StatusOK,
+			expectedBody: `[
+				{"number":"2377225624","result":"accepted"},
+				{"number":"2377225624","result":"accepted"},
+				{"number":"79927398713","result":"already_uploaded"}
+			]`,
+		},
+		{
+			name:         "only invalid numbers",
+			body:         "1\n2",
+			expectedCode: http.StatusOK,
+			expectedBody: `[
+				{"number":"1","result":"invalid","error":"invalid number"},
+				{"number":"2","result":"invalid","error":"invalid number"}
+			]`,
+		},
+		{
+			name:         "empty batch",
+			body:         "\n \n",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"failed to parse request data"}`,
+		},
+		{
+			name:         "broken json",
+			contentType:  "application/json",
+			body:         `["79927398713"`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"failed to parse request data"}`,
+		},
+		{
+			name:         "too many orders",
+			body:         strings.Repeat("79927398713\n", ordersBatchMaxItems+1),
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: `{"error":"batch must contain at most 1000 orders"}`,
+		},
+		{
+			name:         "too large body",
+			body:         strings.Repeat(" ", ordersBatchMaxBytes+1),
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: `{"error":"request body must be at most 65536 bytes"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/user/orders/batch", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, userID))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+
+			h.CreateOrders(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	owner, err := dataStore.GetOrder(ctx, "49927398716")
+	require.NoError(t, err)
+	assert.Equal(t, otherID, owner.UserID, "conflicting order keeps its owner")
+}
+
+func TestCreateOrdersStoreError(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	mockStore := new(MockStore)
+	mockStore.On("AddOrders", mock.Anything, int64(1), []string{"79927398713"}).Return(map[string]storeModel.UploadResult(nil), errors.New("db error"))
+	h := &Handler{store: mockStore}
+
+	req := httptest.NewRequest("POST", "/api/user/orders/batch", strings.NewReader("79927398713\n1"))
+	req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, int64(1)))
+	rr := httptest.NewRecorder()
+
+	h.CreateOrders(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.JSONEq(t, `{"error":"failed to create orders"}`, rr.Body.String())
+	mockStore.AssertExpectations(t)
+}
+
+func TestCreateOrderParallel(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	ctx := context.Background()
+	dataStore := storeModel.NewMemoryStore()
+	h := &Handler{store: dataStore}
+
+	const users = 10
+	const uploadsPerUser = 5
+	userIDs := make([]int64, users)
+	for i := range userIDs {
+		userID, err := dataStore.AddUser(ctx, fmt.Sprintf("user-%d", i), "hash")
+		require.NoError(t, err)
+		userIDs[i] = userID
+	}
+
+	type upload struct {
+		userID int64
+		status int
+	}
+	uploads := make(chan upload, users*uploadsPerUser)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, userID := range userIDs {
+		for i := 0; i < uploadsPerUser; i++ {
+			wg.Add(1)
+			go func(userID int64) {
+				defer wg.Done()
+				req := httptest.NewRequest("POST", "/api/user/orders", strings.NewReader("50405077004"))
+				req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, userID))
+				rr := httptest.NewRecorder()
+				<-start
+				h.CreateOrder(rr, req)
+				uploads <- upload{userID: userID, status: rr.Code}
+			}(userID)
+		}
+	}
+	close(start)
+	wg.Wait()
+	close(uploads)
+
+	var owner int64
+	counts := make(map[int]int)
+	statusesOf := make(map[int64][]int)
+	for item := range uploads {
+		counts[item.status]++
+		statusesOf[item.userID] = append(statusesOf[item.userID], item.status)
+		if item.status == http.StatusAccepted {
+			owner = item.userID
+		}
+	}
+
+	assert.Equal(t, map[int]int{
+		http.StatusAccepted: 1,
+		http.StatusOK:       uploadsPerUser - 1,
+		http.StatusConflict: (users - 1) * uploadsPerUser,
+	}, counts)
+	for userID, statuses := range statusesOf {
+		for _, status := range statuses {
+			if userID == owner {
+				assert.Contains(t, []int{http.StatusAccepted, http.StatusOK}, status)
+			} else {
+				assert.Equal(t, http.StatusConflict, status)
+			}
+		}
+	}
+
+	record, err := dataStore.GetOrder(ctx, "50405077004")
+	require.NoError(t, err)
+	assert.Equal(t, owner, record.UserID)
+}
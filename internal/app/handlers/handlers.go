@@ -1,45 +1,161 @@
 package handlers
 
 import (
+	"TimBerk/gophermart/internal/app/keys"
 	"TimBerk/gophermart/internal/app/models/balance"
 	"TimBerk/gophermart/internal/app/models/order"
 	"TimBerk/gophermart/internal/app/settings/config"
 	"TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/money"
 	"context"
-	"github.com/jackc/pgx/v5"
+	"fmt"
 	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
 )
 
 type Handler struct {
 	store Store
 	cfg   *config.Config
-	ctx   context.Context
+	keys  *keys.KeySet
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
 type Store interface {
-	BeginTx(ctx context.Context) (pgx.Tx, error)
-
 	AddUser(ctx context.Context, username string, password string) (int64, error)
 	CheckUser(ctx context.Context, username string) (int64, error)
 	GetUser(ctx context.Context, username string) (store.UserRecord, error)
+	UpdatePasswordHash(ctx context.Context, userID int64, passwordHash string) error
+
+	AddRefreshToken(ctx context.Context, userID int64, familyID string, tokenHash string, ttl time.Duration) error
+	RotateRefreshToken(ctx context.Context, tokenHash string, newHash string, ttl time.Duration) (store.RefreshTokenRecord, error)
+	RevokeRefreshFamily(ctx context.Context, tokenHash string) error
+	RevokeAccessToken(ctx context.Context, jti string, ttl time.Duration) error
+	IsAccessTokenRevoked(ctx context.Context, userID int64, jti string, generation int64) (bool, error)
+	ChangePassword(ctx context.Context, userID int64, passwordHash string) (int64, error)
+	DeleteUser(ctx context.Context, userID int64, force bool) error
 
-	AddOrder(ctx context.Context, userID int64, order string) error
+	LoginLockout(ctx context.Context, username string, ip string) (time.Duration, error)
+	RecordLoginFailure(ctx context.Context, attempt store.LoginAttempt, policies map[store.LoginScope]store.LoginPolicy) (time.Duration, error)
+	ResetLoginFailures(ctx context.Context, username string) error
+
+	UploadOrder(ctx context.Context, userID int64, order string) (store.UploadResult, error)
+	AddOrders(ctx context.Context, userID int64, orders []string) (map[string]store.UploadResult, error)
 	GetOrder(ctx context.Context, order string) (store.OrderRecord, error)
 	GetOrderList(ctx context.Context, userID int64) (order.OrderListResponse, error)
-	GetOrdersForAccrual(ctx context.Context) ([]order.UserOrder, error)
-	AddWithdrawal(ctx context.Context, userID int64, order string, sum float64) error
-	UpdateOrderStatus(ctx context.Context, userID int64, order string, status store.Status, accrual float64) error
+	GetOrderPage(ctx context.Context, userID int64, filter store.OrderFilter) (order.OrderListResponse, *store.Cursor, error)
+	ClaimOrdersForAccrual(ctx context.Context, owner string, limit int, lease time.Duration) ([]order.UserOrder, error)
+	ReleaseOrders(ctx context.Context, owner string) error
+	AddWithdrawal(ctx context.Context, userID int64, order string, sum money.Amount) error
+	UpdateOrderStatus(ctx context.Context, userID int64, order string, status store.Status, accrual money.Amount) error
 
 	GetBalance(ctx context.Context, userID int64) (balance.Balance, error)
-	AddBalance(ctx context.Context, tx pgx.Tx, userID int64, sum float64) error
-	WithdrawBalance(ctx context.Context, tx pgx.Tx, userID int64, sum float64) error
 	GetOrderWithdrawals(ctx context.Context, userID int64) (balance.WithdrawnList, error)
+	GetWithdrawalPage(ctx context.Context, userID int64, filter store.PageFilter) (balance.WithdrawnList, *store.Cursor, error)
+	GetWithdrawalSummary(ctx context.Context, userID int64, filter store.PageFilter) (balance.WithdrawnSummary, error)
+	ExportOrders(ctx context.Context, userID int64, from time.Time, to time.Time, fn func(order.OrderResponse) error) error
+	ExportWithdrawals(ctx context.Context, userID int64, from time.Time, to time.Time, fn func(balance.WithdrawnResponse) error) error
+
+	GetLedgerEntries(ctx context.Context, userID int64, beforeID int64, limit int) ([]store.LedgerEntry, error)
+
+	ReserveIdempotencyKey(ctx context.Context, userID int64, key string, fingerprint string, ttl time.Duration) (store.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, userID int64, key string, statusCode int, contentType string, body []byte) error
+	DeleteIdempotencyKey(ctx context.Context, userID int64, key string) error
 }
 
-func NewHandler(dataStore Store, cfg *config.Config, ctx context.Context) *Handler {
-	return &Handler{dataStore, cfg, ctx}
+func NewHandler(dataStore Store, cfg *config.Config, keySet *keys.KeySet) *Handler {
+	return &Handler{store: dataStore, cfg: cfg, keys: keySet}
+}
+
+// initLogFields starts log entries of a request, the context adds the IDs of its trace.
+func initLogFields(ctx context.Context, fields logrus.Fields) *logrus.Entry {
+	return logrus.WithContext(ctx).WithFields(fields)
+}
+
+// parsePage reads the limit and numeric cursor query parameters of a paged endpoint.
+func parsePage(r *http.Request, defaultLimit int, maxLimit int) (int, int64, error) {
+	limit := defaultLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		limit = parsed
+	}
+
+	var cursor int64
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			return 0, 0, fmt.Errorf("incorrect cursor")
+		}
+		cursor = parsed
+	}
+	return limit, cursor, nil
 }
 
-func initLogFields(fields logrus.Fields) *logrus.Entry {
-	return logrus.WithFields(fields)
+// parsePageFilter reads limit, opaque cursor and the from/to creation time range of a keyset paged endpoint.
+func parsePageFilter(r *http.Request, defaultLimit int, maxLimit int) (store.PageFilter, error) {
+	query := r.URL.Query()
+	filter := store.PageFilter{Limit: defaultLimit}
+
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		filter.Limit = parsed
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := store.ParseCursor(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = &cursor
+	}
+
+	var err error
+	filter.From, filter.To, err = parseDateRange(r)
+	return filter, err
+}
+
+// parseDateRange reads the optional from/to query parameters, from is inclusive and to is exclusive.
+func parseDateRange(r *http.Request) (from time.Time, to time.Time, err error) {
+	query := r.URL.Query()
+	if value := query.Get("from"); value != "" {
+		if from, err = parseDate(value); err != nil {
+			return from, to, fmt.Errorf("incorrect from: %w", err)
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = parseDate(value); err != nil {
+			return from, to, fmt.Errorf("incorrect to: %w", err)
+		}
+	}
+	return from, to, nil
+}
+
+// parseDate reads a filter bound in RFC3339 or as a plain date, which means its midnight in UTC.
+func parseDate(value string) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// writeNextPage announces the next page in the X-Next-Cursor header and as a Link relation
+// with the same query and the new cursor.
+func writeNextPage(w http.ResponseWriter, r *http.Request, cursor string) {
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+
+	w.Header().Set("X-Next-Cursor", cursor)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
 }
@@ -4,8 +4,8 @@ import (
 	"TimBerk/gophermart/internal/app/models/balance"
 	"TimBerk/gophermart/internal/app/models/order"
 	"TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/money"
 	"context"
-	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/mock"
 	"time"
 )
@@ -20,11 +20,6 @@ type MockStore struct {
 	mock.Mock
 }
 
-func (m *MockStore) BeginTx(ctx context.Context) (pgx.Tx, error) {
-	args := m.Called(ctx)
-	return args.Get(0).(pgx.Tx), args.Error(1)
-}
-
 func (m *MockStore) AddUser(ctx context.Context, username string, password string) (int64, error) {
 	args := m.Called(ctx, username, password)
 	return args.Get(0).(int64), args.Error(1)
@@ -40,8 +35,63 @@ func (m *MockStore) GetUser(ctx context.Context, username string) (store.UserRec
 	return args.Get(0).(store.UserRecord), args.Error(1)
 }
 
-func (m *MockStore) AddOrder(ctx context.Context, userID int64, order string) error {
+func (m *MockStore) UploadOrder(ctx context.Context, userID int64, order string) (store.UploadResult, error) {
 	args := m.Called(ctx, userID, order)
+	return args.Get(0).(store.UploadResult), args.Error(1)
+}
+
+func (m *MockStore) AddRefreshToken(ctx context.Context, userID int64, familyID string, tokenHash string, ttl time.Duration) error {
+	args := m.Called(ctx, userID, familyID, tokenHash, ttl)
+	return args.Error(0)
+}
+
+func (m *MockStore) RotateRefreshToken(ctx context.Context, tokenHash string, newHash string, ttl time.Duration) (store.RefreshTokenRecord, error) {
+	args := m.Called(ctx, tokenHash, newHash, ttl)
+	return args.Get(0).(store.RefreshTokenRecord), args.Error(1)
+}
+
+func (m *MockStore) RevokeRefreshFamily(ctx context.Context, tokenHash string) error {
+	args := m.Called(ctx, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockStore) RevokeAccessToken(ctx context.Context, jti string, ttl time.Duration) error {
+	args := m.Called(ctx, jti, ttl)
+	return args.Error(0)
+}
+
+func (m *MockStore) IsAccessTokenRevoked(ctx context.Context, userID int64, jti string, generation int64) (bool, error) {
+	args := m.Called(ctx, userID, jti, generation)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) ChangePassword(ctx context.Context, userID int64, passwordHash string) (int64, error) {
+	args := m.Called(ctx, userID, passwordHash)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockStore) DeleteUser(ctx context.Context, userID int64, force bool) error {
+	args := m.Called(ctx, userID, force)
+	return args.Error(0)
+}
+
+func (m *MockStore) UpdatePasswordHash(ctx context.Context, userID int64, passwordHash string) error {
+	args := m.Called(ctx, userID, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockStore) LoginLockout(ctx context.Context, username string, ip string) (time.Duration, error) {
+	args := m.Called(ctx, username, ip)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func (m *MockStore) RecordLoginFailure(ctx context.Context, attempt store.LoginAttempt, policies map[store.LoginScope]store.LoginPolicy) (time.Duration, error) {
+	args := m.Called(ctx, attempt, policies)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func (m *MockStore) ResetLoginFailures(ctx context.Context, username string) error {
+	args := m.Called(ctx, username)
 	return args.Error(0)
 }
 
@@ -55,17 +105,32 @@ func (m *MockStore) GetOrderList(ctx context.Context, userID int64) (order.Order
 	return args.Get(0).(order.OrderListResponse), args.Error(1)
 }
 
-func (m *MockStore) GetOrdersForAccrual(ctx context.Context) ([]order.UserOrder, error) {
-	args := m.Called(ctx)
+func (m *MockStore) AddOrders(ctx context.Context, userID int64, orders []string) (map[string]store.UploadResult, error) {
+	args := m.Called(ctx, userID, orders)
+	return args.Get(0).(map[string]store.UploadResult), args.Error(1)
+}
+
+func (m *MockStore) GetOrderPage(ctx context.Context, userID int64, filter store.OrderFilter) (order.OrderListResponse, *store.Cursor, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).(order.OrderListResponse), args.Get(1).(*store.Cursor), args.Error(2)
+}
+
+func (m *MockStore) ClaimOrdersForAccrual(ctx context.Context, owner string, limit int, lease time.Duration) ([]order.UserOrder, error) {
+	args := m.Called(ctx, owner, limit, lease)
 	return args.Get(0).([]order.UserOrder), args.Error(1)
 }
 
-func (m *MockStore) AddWithdrawal(ctx context.Context, userID int64, order string, sum float64) error {
+func (m *MockStore) ReleaseOrders(ctx context.Context, owner string) error {
+	args := m.Called(ctx, owner)
+	return args.Error(0)
+}
+
+func (m *MockStore) AddWithdrawal(ctx context.Context, userID int64, order string, sum money.Amount) error {
 	args := m.Called(ctx, userID, order, sum)
 	return args.Error(0)
 }
 
-func (m *MockStore) UpdateOrderStatus(ctx context.Context, userID int64, order string, status store.Status, accrual float64) error {
+func (m *MockStore) UpdateOrderStatus(ctx context.Context, userID int64, order string, status store.Status, accrual money.Amount) error {
 	args := m.Called(ctx, userID, order, status, accrual)
 	return args.Error(0)
 }
@@ -75,17 +140,47 @@ func (m *MockStore) GetBalance(ctx context.Context, userID int64) (balance.Balan
 	return args.Get(0).(balance.Balance), args.Error(1)
 }
 
-func (m *MockStore) AddBalance(ctx context.Context, tx pgx.Tx, userID int64, sum float64) error {
-	args := m.Called(ctx, tx, userID, sum)
+func (m *MockStore) GetOrderWithdrawals(ctx context.Context, userID int64) (balance.WithdrawnList, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(balance.WithdrawnList), args.Error(1)
+}
+
+func (m *MockStore) GetWithdrawalPage(ctx context.Context, userID int64, filter store.PageFilter) (balance.WithdrawnList, *store.Cursor, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).(balance.WithdrawnList), args.Get(1).(*store.Cursor), args.Error(2)
+}
+
+func (m *MockStore) ExportOrders(ctx context.Context, userID int64, from time.Time, to time.Time, fn func(order.OrderResponse) error) error {
+	args := m.Called(ctx, userID, from, to, fn)
 	return args.Error(0)
 }
 
-func (m *MockStore) WithdrawBalance(ctx context.Context, tx pgx.Tx, userID int64, sum float64) error {
-	args := m.Called(ctx, tx, userID, sum)
+func (m *MockStore) ExportWithdrawals(ctx context.Context, userID int64, from time.Time, to time.Time, fn func(balance.WithdrawnResponse) error) error {
+	args := m.Called(ctx, userID, from, to, fn)
 	return args.Error(0)
 }
 
-func (m *MockStore) GetOrderWithdrawals(ctx context.Context, userID int64) (balance.WithdrawnList, error) {
-	args := m.Called(ctx, userID)
-	return args.Get(0).(balance.WithdrawnList), args.Error(1)
+func (m *MockStore) GetWithdrawalSummary(ctx context.Context, userID int64, filter store.PageFilter) (balance.WithdrawnSummary, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).(balance.WithdrawnSummary), args.Error(1)
+}
+
+func (m *MockStore) GetLedgerEntries(ctx context.Context, userID int64, beforeID int64, limit int) ([]store.LedgerEntry, error) {
+	args := m.Called(ctx, userID, beforeID, limit)
+	return args.Get(0).([]store.LedgerEntry), args.Error(1)
+}
+
+func (m *MockStore) ReserveIdempotencyKey(ctx context.Context, userID int64, key string, fingerprint string, ttl time.Duration) (store.IdempotencyRecord, bool, error) {
+	args := m.Called(ctx, userID, key, fingerprint, ttl)
+	return args.Get(0).(store.IdempotencyRecord), args.Bool(1), args.Error(2)
+}
+
+func (m *MockStore) CompleteIdempotencyKey(ctx context.Context, userID int64, key string, statusCode int, contentType string, body []byte) error {
+	args := m.Called(ctx, userID, key, statusCode, contentType, body)
+	return args.Error(0)
+}
+
+func (m *MockStore) DeleteIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	args := m.Called(ctx, userID, key)
+	return args.Error(0)
 }
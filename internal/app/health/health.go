@@ -0,0 +1,114 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+
+	// checkTimeout bounds each dependency check, probes of orchestrators usually time out in a few seconds.
+	checkTimeout = 2 * time.Second
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Check reports a problem of a dependency as an error.
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Response struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker serves the liveness and readiness probes.
+type Checker struct {
+	checks       []namedCheck
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{timeout: checkTimeout}
+}
+
+// Add registers a dependency checked by the readiness probe.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Shutdown makes the readiness probe fail, so the server stops getting new traffic before it stops.
+func (c *Checker) Shutdown() {
+	c.shuttingDown.Store(true)
+}
+
+// Live answers while the process can serve HTTP, dependencies are not checked
+// so a database outage doesn't make the orchestrator restart healthy replicas.
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, http.StatusOK, Response{Status: StatusOK})
+}
+
+// Ready runs all checks in parallel and answers 503 if any of them fails.
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	response := Response{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks)+1)}
+	if c.shuttingDown.Load() {
+		response.Checks["shutdown"] = CheckResult{Status: StatusFail, Error: ErrShuttingDown.Error()}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, item := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+			defer cancel()
+
+			result := CheckResult{Status: StatusOK}
+			if err := item.check(ctx); err != nil {
+				result = CheckResult{Status: StatusFail, Error: err.Error()}
+				logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": "H.Ready", "check": item.name, "error": err}).Warning("readiness check failed")
+			}
+
+			mu.Lock()
+			response.Checks[item.name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	statusCode := http.StatusOK
+	for _, result := range response.Checks {
+		if result.Status != StatusOK {
+			response.Status = StatusFail
+			statusCode = http.StatusServiceUnavailable
+		}
+	}
+	writeResponse(w, statusCode, response)
+}
+
+func writeResponse(w http.ResponseWriter, statusCode int, response Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
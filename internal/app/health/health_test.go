@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func okCheck(context.Context) error {
+	return nil
+}
+
+func TestLive(t *testing.T) {
+	checker := NewChecker()
+	checker.Add("database", func(context.Context) error { return errors.New("connection refused") })
+	checker.Shutdown()
+
+	rr := httptest.NewRecorder()
+	checker.Live(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code, "liveness must not depend on dependencies")
+	assert.JSONEq(t, `{"status":"ok"}`, rr.Body.String())
+}
+
+func TestReady(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	tests := []struct {
+		name           string
+		checks         map[string]Check
+		shutdown       bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "all checks pass",
+			checks:         map[string]Check{"database": okCheck, "migrations": okCheck},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"ok","checks":{"database":{"status":"ok"},"migrations":{"status":"ok"}}}`,
+		},
+		{
+			name: "failed check",
+			checks: map[string]Check{
+				"database":       okCheck,
+				"accrual_worker": func(context.Context) error { return errors.New("accrual worker is stalled") },
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"status":"fail","checks":{"database":{"status":"ok"},"accrual_worker":{"status":"fail","error":"accrual worker is stalled"}}}`,
+		},
+		{
+			name:           "shutting down",
+			checks:         map[string]Check{"database": okCheck},
+			shutdown:       true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"status":"fail","checks":{"database":{"status":"ok"},"shutdown":{"status":"fail","error":"server is shutting down"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker()
+			for name, check := range tt.checks {
+				checker.Add(name, check)
+			}
+			if tt.shutdown {
+				checker.Shutdown()
+			}
+
+			rr := httptest.NewRecorder()
+			checker.Ready(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestReadyTimeout(t *testing.T) {
+	checker := NewChecker()
+	checker.timeout = 0
+	checker.Add("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	rr := httptest.NewRecorder()
+	checker.Ready(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var response Response
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, CheckResult{Status: StatusFail, Error: context.DeadlineExceeded.Error()}, response.Checks["database"])
+}
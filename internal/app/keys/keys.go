@@ -0,0 +1,159 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey     = errors.New("unknown key id")
+	ErrNoSigningKey   = errors.New("no active signing key")
+	ErrUnsupportedKey = errors.New("unsupported key type")
+)
+
+// Key is a signing key identified by kid. It signs tokens from ActiveFrom until the next key becomes active.
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	ActiveFrom time.Time
+
+	private interface{}
+	public  interface{}
+}
+
+// NewKey picks the signing algorithm by the private key type: RS256 for RSA, ES256 for P-256 and EdDSA for Ed25519.
+func NewKey(id string, private interface{}, activeFrom time.Time) (*Key, error) {
+	key := &Key{ID: id, ActiveFrom: activeFrom, private: private}
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.public = jwt.SigningMethodRS256, &private.PublicKey
+	case *ecdsa.PrivateKey:
+		if private.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: key %s: only P-256 curve is supported", ErrUnsupportedKey, id)
+		}
+		key.Method, key.public = jwt.SigningMethodES256, &private.PublicKey
+	case ed25519.PrivateKey:
+		key.Method, key.public = jwt.SigningMethodEdDSA, private.Public()
+	default:
+		return nil, fmt.Errorf("%w: key %s: %T", ErrUnsupportedKey, id, private)
+	}
+	return key, nil
+}
+
+// NewHMACKey wraps a shared secret, such keys are never published in JWKS.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, private: secret, public: secret}
+}
+
+// KeySet signs tokens with the newest active key and verifies them with every key that is not retired.
+// A key is retired when overlap has passed since the next key became active, so tokens signed
+// just before a rotation stay valid until they expire. Keys scheduled for the future are already
+// accepted and published, so verifiers can pick them up before the switch.
+type KeySet struct {
+	keys    []*Key
+	overlap time.Duration
+	now     func() time.Time
+}
+
+func NewKeySet(overlap time.Duration, keys ...*Key) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("key id is required")
+		}
+		if _, exists := seen[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %s", key.ID)
+		}
+		seen[key.ID] = struct{}{}
+	}
+
+	sorted := append([]*Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ActiveFrom.Before(sorted[j].ActiveFrom) })
+	return &KeySet{keys: sorted, overlap: overlap, now: time.Now}, nil
+}
+
+// activeIndex returns the index of the key signing at the moment, or -1 if no key is active yet.
+func (s *KeySet) activeIndex(moment time.Time) int {
+	index := -1
+	for i, key := range s.keys {
+		if !key.ActiveFrom.After(moment) {
+			index = i
+		}
+	}
+	return index
+}
+
+// validKeys returns keys accepted for verification at the moment. Keys activated at the same
+// time as the oldest valid one are kept as well, they were never replaced by each other.
+func (s *KeySet) validKeys(moment time.Time) []*Key {
+	first := s.activeIndex(moment.Add(-s.overlap))
+	if first < 0 {
+		return s.keys
+	}
+	for first > 0 && s.keys[first-1].ActiveFrom.Equal(s.keys[first].ActiveFrom) {
+		first--
+	}
+	return s.keys[first:]
+}
+
+func (s *KeySet) SigningKey() (*Key, error) {
+	index := s.activeIndex(s.now())
+	if index < 0 {
+		return nil, ErrNoSigningKey
+	}
+	return s.keys[index], nil
+}
+
+// Sign returns the signed token with the kid header of the signing key.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, err := s.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// Keyfunc finds the verification key by kid for jwt.Parse. The token algorithm must match
+// the key, so a public key can't be used as an HMAC secret.
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range s.validKeys(s.now()) {
+		if key.ID != kid {
+			continue
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("key %s: unexpected signing method %s", kid, token.Method.Alg())
+		}
+		return key.public, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+// Methods lists algorithms of all keys, it is passed to jwt.WithValidMethods.
+func (s *KeySet) Methods() []string {
+	var methods []string
+	seen := make(map[string]struct{})
+	for _, key := range s.keys {
+		alg := key.Method.Alg()
+		if _, exists := seen[alg]; !exists {
+			seen[alg] = struct{}{}
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
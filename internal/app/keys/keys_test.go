@@ -0,0 +1,205 @@
+package keys
+
+import (
+	"TimBerk/gophermart/internal/app/settings/config"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, name string, blockType string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0o600))
+	return path
+}
+
+func verify(keySet *KeySet, tokenString string) error {
+	_, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, keySet.Keyfunc, jwt.WithValidMethods(keySet.Methods()))
+	return err
+}
+
+func TestLoad(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPath := writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecData, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	ecPath := writePEM(t, "ec.pem", "EC PRIVATE KEY", ecData)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edData, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	edPath := writePEM(t, "ed.pem", "PRIVATE KEY", edData)
+
+	certPath := writePEM(t, "cert.pem", "CERTIFICATE", []byte("cert"))
+
+	tests := []struct {
+		name            string
+		keys            string
+		expectedMethods []string
+		expectedSigner  string
+		wantErr         bool
+	}{
+		{
+			name:            "shared secret without keys",
+			expectedMethods: []string{"HS256"},
+			expectedSigner:  defaultHMACKeyID,
+		},
+		{
+			name:            "rotation to a scheduled key",
+			keys:            "rsa-1=" + rsaPath + ",ec-1=" + ecPath + "@2020-01-01T00:00:00Z,ed-1=" + edPath + "@2999-01-01T00:00:00Z",
+			expectedMethods: []string{"RS256", "ES256", "EdDSA"},
+			expectedSigner:  "ec-1",
+		},
+		{name: "entry without path", keys: "rsa-1", wantErr: true},
+		{name: "invalid activation time", keys: "rsa-1=" + rsaPath + "@tomorrow", wantErr: true},
+		{name: "missing file", keys: "rsa-1=" + filepath.Join(t.TempDir(), "missing.pem"), wantErr: true},
+		{name: "not a private key", keys: "cert=" + certPath, wantErr: true},
+		{name: "duplicate key id", keys: "rsa-1=" + rsaPath + ",rsa-1=" + edPath, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keySet, err := Load(&config.Config{KeyJWT: []byte("secret"), ExpireJWT: 60, JWTKeys: tt.keys})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedMethods, keySet.Methods())
+
+			signer, err := keySet.SigningKey()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSigner, signer.ID)
+
+			tokenString, err := keySet.Sign(jwt.RegisteredClaims{Subject: "user"})
+			require.NoError(t, err)
+			assert.NoError(t, verify(keySet, tokenString))
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	old, err := NewKey("old", oldKey, start)
+	require.NoError(t, err)
+	next, err := NewKey("new", newKey, start.Add(24*time.Hour))
+	require.NoError(t, err)
+
+	keySet, err := NewKeySet(time.Hour, next, old)
+	require.NoError(t, err)
+
+	keySet.now = func() time.Time { return start.Add(time.Hour) }
+	oldToken, err := keySet.Sign(jwt.RegisteredClaims{})
+	require.NoError(t, err)
+	assert.Len(t, keySet.JWKS().Keys, 2, "scheduled key is published in advance")
+
+	keySet.now = func() time.Time { return start.Add(24*time.Hour + 30*time.Minute) }
+	signer, err := keySet.SigningKey()
+	require.NoError(t, err)
+	assert.Equal(t, "new", signer.ID)
+	assert.NoError(t, verify(keySet, oldToken), "old key is accepted during the overlap")
+
+	keySet.now = func() time.Time { return start.Add(26 * time.Hour) }
+	assert.ErrorIs(t, verify(keySet, oldToken), ErrUnknownKey)
+	assert.Len(t, keySet.JWKS().Keys, 1, "retired key is not published")
+
+	keySet.now = func() time.Time { return start.Add(-time.Hour) }
+	_, err = keySet.Sign(jwt.RegisteredClaims{})
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+}
+
+func TestKeyfuncRejectsAlgorithmConfusion(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := NewKey("rsa-1", private, time.Time{})
+	require.NoError(t, err)
+	keySet, err := NewKeySet(time.Hour, key)
+	require.NoError(t, err)
+
+	// The attacker signs with HS256 using the published RSA key as the secret.
+	publicData := x509.MarshalPKCS1PublicKey(&private.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{})
+	forged.Header["kid"] = "rsa-1"
+	forgedString, err := forged.SignedString(publicData)
+	require.NoError(t, err)
+	assert.Error(t, verify(keySet, forgedString))
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{})
+	unknown.Header["kid"] = "rsa-2"
+	unknownString, err := unknown.SignedString(private)
+	require.NoError(t, err)
+	assert.ErrorIs(t, verify(keySet, unknownString), ErrUnknownKey)
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaEntry, err := NewKey("rsa-1", rsaKey, time.Time{})
+	require.NoError(t, err)
+	ecEntry, err := NewKey("ec-1", ecKey, time.Time{})
+	require.NoError(t, err)
+	edEntry, err := NewKey("ed-1", edKey, time.Time{})
+	require.NoError(t, err)
+
+	keySet, err := NewKeySet(time.Hour, NewHMACKey("hmac", []byte("secret")), rsaEntry, ecEntry, edEntry)
+	require.NoError(t, err)
+
+	jwks := keySet.JWKS()
+	require.Len(t, jwks.Keys, 3, "HMAC keys are never published")
+
+	byID := make(map[string]JWK)
+	for _, jwk := range jwks.Keys {
+		assert.Equal(t, "sig", jwk.Use)
+		byID[jwk.KeyID] = jwk
+	}
+
+	assert.Equal(t, JWK{KeyType: "RSA", KeyID: "rsa-1", Use: "sig", Algorithm: "RS256",
+		N: encode(rsaKey.N.Bytes()), E: "AQAB"}, byID["rsa-1"])
+	assert.Equal(t, "EC", byID["ec-1"].KeyType)
+	assert.Equal(t, "P-256", byID["ec-1"].Curve)
+	assert.Len(t, byID["ec-1"].X, 43, "coordinates are padded to 32 bytes")
+	assert.Len(t, byID["ec-1"].Y, 43)
+	assert.Equal(t, JWK{KeyType: "OKP", KeyID: "ed-1", Use: "sig", Algorithm: "EdDSA",
+		Curve: "Ed25519", X: encode(edPublic)}, byID["ed-1"])
+}
+
+func TestNewKeyUnsupported(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewKey("ec-384", ecKey, time.Time{})
+	assert.ErrorIs(t, err, ErrUnsupportedKey)
+
+	_, err = NewKey("secret", []byte("secret"), time.Time{})
+	assert.ErrorIs(t, err, ErrUnsupportedKey)
+}
@@ -0,0 +1,87 @@
+package keys
+
+import (
+	"TimBerk/gophermart/internal/app/settings/config"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultHMACKeyID = "default"
+
+// ParsePrivateKey reads the first private key of a PEM file: PKCS#8, PKCS#1 RSA or SEC 1 EC.
+func ParsePrivateKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("%w: PEM block %s", ErrUnsupportedKey, block.Type)
+}
+
+// parseSpec reads a key entry in the "kid=path" or "kid=path@2025-05-01T00:00:00Z" form.
+func parseSpec(spec string) (string, string, time.Time, error) {
+	kid, location, found := strings.Cut(strings.TrimSpace(spec), "=")
+	if !found || kid == "" || location == "" {
+		return "", "", time.Time{}, fmt.Errorf("invalid key entry %q, expected kid=path[@time]", spec)
+	}
+
+	var activeFrom time.Time
+	if path, moment, scheduled := strings.Cut(location, "@"); scheduled {
+		parsed, err := time.Parse(time.RFC3339, moment)
+		if err != nil {
+			return "", "", time.Time{}, fmt.Errorf("invalid activation time of key %s: %w", kid, err)
+		}
+		location, activeFrom = path, parsed
+	}
+	return kid, location, activeFrom, nil
+}
+
+// Load builds the key set from the comma separated cfg.JWTKeys entries. Keys without activation time
+// are active immediately. Without entries the HS256 secret cfg.KeyJWT is used.
+// Tokens stay valid for cfg.ExpireJWT minutes, that is the overlap of a rotated key.
+func Load(cfg *config.Config) (*KeySet, error) {
+	overlap := time.Duration(cfg.ExpireJWT) * time.Minute
+
+	if strings.TrimSpace(cfg.JWTKeys) == "" {
+		logrus.WithField("action", "K.Load").Warning("JWT_KEYS is not set, tokens are signed with the shared HS256 secret")
+		return NewKeySet(overlap, NewHMACKey(defaultHMACKeyID, cfg.KeyJWT))
+	}
+
+	var loaded []*Key
+	for _, spec := range strings.Split(cfg.JWTKeys, ",") {
+		kid, path, activeFrom, err := parseSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read key %s: %w", kid, err)
+		}
+		private, err := ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse key %s: %w", kid, err)
+		}
+		key, err := NewKey(kid, private, activeFrom)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, key)
+	}
+	return NewKeySet(overlap, loaded...)
+}
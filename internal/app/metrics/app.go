@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels requests that didn't match any route, so unknown paths don't grow the label set.
+const unmatchedRoute = "unmatched"
+
+// Accrual outcomes, other status codes are reported as other and transport failures as error.
+const (
+	AccrualOK              = "200"
+	AccrualNoContent       = "204"
+	AccrualTooManyRequests = "429"
+	AccrualServerError     = "5xx"
+	AccrualOther           = "other"
+	AccrualError           = "error"
+)
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gophermart_http_requests_total",
+		Help: "Number of HTTP requests by route pattern and status.",
+	}, []string{"method", "route", "status"})
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gophermart_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by route pattern and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	AccrualRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gophermart_accrual_requests_total",
+		Help: "Number of requests to the accrual system by outcome.",
+	}, []string{"outcome"})
+	AccrualDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gophermart_accrual_request_duration_seconds",
+		Help:    "Latency of requests to the accrual system by outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	BalanceCredited = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gophermart_balance_credited_total",
+		Help: "Sum of accruals credited to users.",
+	})
+	BalanceWithdrawn = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gophermart_balance_withdrawn_total",
+		Help: "Sum withdrawn by users.",
+	})
+)
+
+// Registry is served by the /metrics endpoint.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, AccrualRequests, AccrualDuration, BalanceCredited, BalanceWithdrawn,
+	)
+}
+
+// Handler serves the metrics of Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts requests by chi route pattern. The pattern is known only after routing,
+// so it is read once the request is served. A handler that panics, like an export aborting
+// a started response, is counted as a server error before the panic goes on.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				observeRequest(r, http.StatusInternalServerError, start)
+				panic(rec)
+			}
+		}()
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		observeRequest(r, status, start)
+	})
+}
+
+func observeRequest(r *http.Request, status int, start time.Time) {
+	route := unmatchedRoute
+	if routeCtx := chi.RouteContext(r.Context()); routeCtx != nil {
+		if pattern := routeCtx.RoutePattern(); pattern != "" {
+			route = pattern
+		}
+	}
+
+	labels := []string{r.Method, route, strconv.Itoa(status)}
+	HTTPRequests.WithLabelValues(labels...).Inc()
+	HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+}
+
+// AccrualOutcome maps a status code of the accrual system to an outcome label.
+func AccrualOutcome(statusCode int) string {
+	switch {
+	case statusCode == http.StatusOK:
+		return AccrualOK
+	case statusCode == http.StatusNoContent:
+		return AccrualNoContent
+	case statusCode == http.StatusTooManyRequests:
+		return AccrualTooManyRequests
+	case statusCode >= http.StatusInternalServerError:
+		return AccrualServerError
+	default:
+		return AccrualOther
+	}
+}
+
+// ObserveAccrual records a finished request to the accrual system.
+func ObserveAccrual(start time.Time, outcome string) {
+	AccrualRequests.WithLabelValues(outcome).Inc()
+	AccrualDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/test/metrics/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	router.Get("/test/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		labels []string
+	}{
+		{name: "route pattern instead of path", path: "/test/metrics/42", labels: []string{http.MethodGet, "/test/metrics/{id}", "202"}},
+		{name: "implicit status", path: "/test/metrics", labels: []string{http.MethodGet, "/test/metrics", "200"}},
+		{name: "unknown path", path: "/test/unknown", labels: []string{http.MethodGet, unmatchedRoute, "404"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(HTTPRequests.WithLabelValues(tt.labels...))
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, before+1, testutil.ToFloat64(HTTPRequests.WithLabelValues(tt.labels...)))
+		})
+	}
+}
+
+func TestMiddlewareAbortedHandler(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/test/metrics/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic(http.ErrAbortHandler)
+	})
+
+	labels := []string{http.MethodGet, "/test/metrics/export", "500"}
+	before := testutil.ToFloat64(HTTPRequests.WithLabelValues(labels...))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/metrics/export", nil))
+	}, "the panic goes on to abort the connection")
+
+	assert.Equal(t, before+1, testutil.ToFloat64(HTTPRequests.WithLabelValues(labels...)))
+}
+
+func TestAccrualOutcome(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		expected   string
+	}{
+		{statusCode: http.StatusOK, expected: AccrualOK},
+		{statusCode: http.StatusNoContent, expected: AccrualNoContent},
+		{statusCode: http.StatusTooManyRequests, expected: AccrualTooManyRequests},
+		{statusCode: http.StatusBadGateway, expected: AccrualServerError},
+		{statusCode: http.StatusNotFound, expected: AccrualOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, AccrualOutcome(tt.statusCode))
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ObserveAccrual(time.Now(), AccrualTooManyRequests)
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `gophermart_accrual_requests_total{outcome="429"} 1`)
+	assert.Contains(t, rr.Body.String(), "# TYPE gophermart_accrual_request_duration_seconds histogram")
+	assert.Contains(t, rr.Body.String(), "gophermart_balance_credited_total 0")
+}
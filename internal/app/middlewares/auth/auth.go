@@ -1,7 +1,7 @@
 package auth
 
 import (
-	"TimBerk/gophermart/internal/app/settings/config"
+	"TimBerk/gophermart/internal/app/keys"
 	"TimBerk/gophermart/pkg/responses"
 	"context"
 	"github.com/golang-jwt/jwt/v5"
@@ -13,13 +13,22 @@ import (
 type contextKey string
 
 const (
-	UsernameKey contextKey = "username"
-	UserIDKey   contextKey = "userID"
+	UsernameKey     contextKey = "username"
+	UserIDKey       contextKey = "userID"
+	TokenIDKey      contextKey = "tokenID"
+	TokenExpiresKey contextKey = "tokenExpires"
 )
 
+// RevocationStore reports access tokens revoked before their expiration, e.g. on logout,
+// password change or account deletion.
+type RevocationStore interface {
+	IsAccessTokenRevoked(ctx context.Context, userID int64, jti string, generation int64) (bool, error)
+}
+
 type JWTRecord struct {
-	Username string `json:"username"`
-	UserID   int64  `json:"id"`
+	Username          string `json:"username"`
+	UserID            int64  `json:"id"`
+	SessionGeneration int64  `json:"gen,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -38,7 +47,7 @@ func getToken(r *http.Request) (string, bool) {
 	return "", false
 }
 
-func Authentication(cfg *config.Config) func(next http.Handler) http.Handler {
+func Authentication(keySet *keys.KeySet, revocations RevocationStore) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var errMessage string
@@ -46,32 +55,48 @@ func Authentication(cfg *config.Config) func(next http.Handler) http.Handler {
 			tokenString, ok := getToken(r)
 			if !ok || tokenString == "" {
 				errMessage = "User not authorized"
-				logrus.WithFields(logrus.Fields{"action": "M.Authentication"}).Error(errMessage)
+				logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": "M.Authentication"}).Error(errMessage)
 				responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
 				return
 			}
 
 			claims := &JWTRecord{}
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				return cfg.KeyJWT, nil
-			})
+			token, err := jwt.ParseWithClaims(tokenString, claims, keySet.Keyfunc, jwt.WithValidMethods(keySet.Methods()))
 
 			if err != nil {
 				errMessage = "Failed parse token"
-				logrus.WithFields(logrus.Fields{"action": "M.Authentication", "error": err}).Error(errMessage)
+				logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": "M.Authentication", "error": err}).Error(errMessage)
 				responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
 				return
 			}
 
-			if !token.Valid {
+			// Tokens without id can't be revoked one by one, so they are not accepted. All sessions
+			// of the user are revoked by the session generation, not by the issue time.
+			if !token.Valid || claims.ID == "" || claims.ExpiresAt == nil {
 				errMessage = "Invalid token"
-				logrus.WithFields(logrus.Fields{"action": "M.Authentication", "error": err}).Error(errMessage)
+				logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": "M.Authentication", "error": err}).Error(errMessage)
+				responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
+				return
+			}
+
+			revoked, err := revocations.IsAccessTokenRevoked(r.Context(), claims.UserID, claims.ID, claims.SessionGeneration)
+			if err != nil {
+				errMessage = "Failed check token"
+				logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": "M.Authentication", "error": err}).Error(errMessage)
+				responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				errMessage = "Token was revoked"
+				logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": "M.Authentication", "user": claims.UserID}).Warning(errMessage)
 				responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
 				return
 			}
 
 			ctx := context.WithValue(r.Context(), UsernameKey, claims.Username)
 			ctx = context.WithValue(ctx, UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, TokenIDKey, claims.ID)
+			ctx = context.WithValue(ctx, TokenExpiresKey, claims.ExpiresAt.Time)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
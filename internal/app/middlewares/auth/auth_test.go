@@ -1,7 +1,9 @@
 package auth
 
 import (
-	"TimBerk/gophermart/internal/app/settings/config"
+	"TimBerk/gophermart/internal/app/keys"
+	"context"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"net/http"
 	"net/http/httptest"
@@ -11,10 +13,9 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func mockConfig(jwtKey string) *config.Config {
-	return &config.Config{
-		KeyJWT: []byte(jwtKey),
-	}
+func mockKeys(jwtKey string) *keys.KeySet {
+	keySet, _ := keys.NewKeySet(time.Hour, keys.NewHMACKey("test", []byte(jwtKey)))
+	return keySet
 }
 
 func TestGetToken(t *testing.T) {
@@ -64,21 +65,37 @@ func TestGetToken(t *testing.T) {
 	}
 }
 
-func TestAuthenticationMiddleware(t *testing.T) {
-	validKey := "secret-key"
-	invalidKey := "wrong-key"
+type revocationStub map[string]bool
+
+func (s revocationStub) IsAccessTokenRevoked(ctx context.Context, userID int64, jti string, generation int64) (bool, error) {
+	if jti == "broken" {
+		return false, errors.New("db error")
+	}
+	return s[jti], nil
+}
 
-	validToken := jwt.NewWithClaims(jwt.SigningMethodHS256, &JWTRecord{
+func signToken(key string, jti string) string {
+	tokenString, _ := mockKeys(key).Sign(&JWTRecord{
+		UserID: 777,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
 		},
 	})
-	validTokenString, _ := validToken.SignedString([]byte(validKey))
+	return tokenString
+}
+
+func TestAuthenticationMiddleware(t *testing.T) {
+	validKey := "secret-key"
+	invalidKey := "wrong-key"
+
+	validTokenString := signToken(validKey, "valid")
+	revocations := revocationStub{"revoked": true}
 
 	tests := []struct {
 		name            string
 		token           string
-		config          *config.Config
+		keySet          *keys.KeySet
 		expectedStatus  int
 		shouldCallNext  bool
 		expectedMessage string
@@ -86,14 +103,14 @@ func TestAuthenticationMiddleware(t *testing.T) {
 		{
 			name:           "Valid token",
 			token:          validTokenString,
-			config:         mockConfig(validKey),
+			keySet:         mockKeys(validKey),
 			expectedStatus: http.StatusOK,
 			shouldCallNext: true,
 		},
 		{
 			name:            "Empty token",
 			token:           "",
-			config:          mockConfig(validKey),
+			keySet:          mockKeys(validKey),
 			expectedStatus:  http.StatusUnauthorized,
 			shouldCallNext:  false,
 			expectedMessage: "User not authorized",
@@ -101,15 +118,39 @@ func TestAuthenticationMiddleware(t *testing.T) {
 		{
 			name:            "Wrong signing key",
 			token:           validTokenString,
-			config:          mockConfig(invalidKey),
+			keySet:          mockKeys(invalidKey),
 			expectedStatus:  http.StatusUnauthorized,
 			shouldCallNext:  false,
 			expectedMessage: "Failed parse token",
 		},
+		{
+			name:            "Token without id",
+			token:           signToken(validKey, ""),
+			keySet:          mockKeys(validKey),
+			expectedStatus:  http.StatusUnauthorized,
+			shouldCallNext:  false,
+			expectedMessage: "Invalid token",
+		},
+		{
+			name:            "Revoked token",
+			token:           signToken(validKey, "revoked"),
+			keySet:          mockKeys(validKey),
+			expectedStatus:  http.StatusUnauthorized,
+			shouldCallNext:  false,
+			expectedMessage: "Token was revoked",
+		},
+		{
+			name:            "Revocation check failed",
+			token:           signToken(validKey, "broken"),
+			keySet:          mockKeys(validKey),
+			expectedStatus:  http.StatusInternalServerError,
+			shouldCallNext:  false,
+			expectedMessage: "Failed check token",
+		},
 		{
 			name:            "No Authorization header",
 			token:           "",
-			config:          mockConfig(validKey),
+			keySet:          mockKeys(validKey),
 			expectedStatus:  http.StatusUnauthorized,
 			shouldCallNext:  false,
 			expectedMessage: "User not authorized",
@@ -127,10 +168,12 @@ func TestAuthenticationMiddleware(t *testing.T) {
 			nextCalled := false
 			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				nextCalled = true
+				assert.Equal(t, int64(777), r.Context().Value(UserIDKey))
+				assert.Equal(t, "valid", r.Context().Value(TokenIDKey))
 				w.WriteHeader(http.StatusOK)
 			})
 
-			middleware := Authentication(tt.config)
+			middleware := Authentication(tt.keySet, revocations)
 			handler := middleware(nextHandler)
 			handler.ServeHTTP(rr, req)
 
@@ -0,0 +1,160 @@
+package idempotency
+
+import (
+	"TimBerk/gophermart/internal/app/middlewares/auth"
+	"TimBerk/gophermart/internal/app/settings/config"
+	"TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/responses"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+type Store interface {
+	ReserveIdempotencyKey(ctx context.Context, userID int64, key string, fingerprint string, ttl time.Duration) (store.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, userID int64, key string, statusCode int, contentType string, body []byte) error
+	DeleteIdempotencyKey(ctx context.Context, userID int64, key string) error
+}
+
+type recorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *recorder) Write(data []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *recorder) status() int {
+	if r.statusCode == 0 {
+		return http.StatusOK
+	}
+	return r.statusCode
+}
+
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.URL.Path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Idempotency replays the stored response for a repeated Idempotency-Key of the same user.
+// Requests without the header are passed through unchanged. A key reused with another body
+// is rejected with 422, a key whose first request is still running with 409.
+// Server errors are not stored, so the client may retry them with the same key.
+func Idempotency(cfg *config.Config, dataStore Store) func(next http.Handler) http.Handler {
+	ttl := time.Duration(cfg.IdempotencyTTL) * time.Minute
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var errMessage string
+			action := "M.Idempotency"
+
+			key := r.Header.Get(HeaderKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logFields := logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": action, "key": key})
+			if len(key) > maxKeyLength {
+				errMessage = "idempotency key is too long"
+				logFields.Warning(errMessage)
+				responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+				return
+			}
+
+			userID, ok := r.Context().Value(auth.UserIDKey).(int64)
+			if !ok {
+				errMessage = "User is not authorized"
+				logFields.Error(errMessage)
+				responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
+				return
+			}
+			logFields = logFields.WithField("user", userID)
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				errMessage = "failed to read request body"
+				logFields.WithField("error", err).Warning(errMessage)
+				responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			requestFingerprint := fingerprint(r, body)
+			record, created, err := dataStore.ReserveIdempotencyKey(r.Context(), userID, key, requestFingerprint, ttl)
+			if err != nil {
+				errMessage = "failed to check idempotency key"
+				logFields.WithField("error", err).Error(errMessage)
+				responses.WriteJSONError(w, errMessage, http.StatusInternalServerError)
+				return
+			}
+
+			if !created {
+				switch {
+				case record.Fingerprint != requestFingerprint:
+					errMessage = "idempotency key was used with another request"
+					logFields.Warning(errMessage)
+					responses.WriteJSONError(w, errMessage, http.StatusUnprocessableEntity)
+				case !record.Completed():
+					errMessage = "request with this idempotency key is in progress"
+					logFields.Warning(errMessage)
+					responses.WriteJSONError(w, errMessage, http.StatusConflict)
+				default:
+					logFields.Info("replay stored response")
+					if record.ContentType != "" {
+						w.Header().Set("Content-Type", record.ContentType)
+					}
+					w.Header().Set(HeaderReplayed, "true")
+					w.WriteHeader(record.StatusCode)
+					w.Write(record.Body)
+				}
+				return
+			}
+
+			rec := &recorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			// The response is already sent, so the key is finalized even if the client has gone away.
+			ctx := context.WithoutCancel(r.Context())
+			if rec.status() >= http.StatusInternalServerError {
+				err = dataStore.DeleteIdempotencyKey(ctx, userID, key)
+			} else {
+				err = dataStore.CompleteIdempotencyKey(ctx, userID, key, rec.status(), w.Header().Get("Content-Type"), rec.body.Bytes())
+			}
+			if err != nil {
+				logFields.WithField("error", err).Error("failed to save idempotency key")
+			}
+		})
+	}
+}
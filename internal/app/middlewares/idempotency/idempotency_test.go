@@ -0,0 +1,175 @@
+package idempotency
+
+import (
+	"TimBerk/gophermart/internal/app/middlewares/auth"
+	"TimBerk/gophermart/internal/app/settings/config"
+	"TimBerk/gophermart/internal/app/store"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockUserID = int64(777)
+
+func newRequest(key string, body string, userID int64) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+	if userID != 0 {
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, userID))
+	}
+	return req
+}
+
+type countingHandler struct {
+	calls      int
+	statusCode int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(h.statusCode)
+	w.Write([]byte(`{"echo":"` + string(body) + `"}`))
+}
+
+func TestIdempotency(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+	cfg := &config.Config{IdempotencyTTL: 60}
+
+	tests := []struct {
+		name           string
+		statusCode     int
+		first          *http.Request
+		second         *http.Request
+		prepare        func(*store.MemoryStore)
+		expectedCalls  int
+		expectedStatus int
+		expectedBody   string
+		expectedReplay bool
+	}{
+		{
+			name:           "requests without key are not deduplicated",
+			statusCode:     http.StatusOK,
+			first:          newRequest("", "a", mockUserID),
+			second:         newRequest("", "a", mockUserID),
+			expectedCalls:  2,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"echo":"a"}`,
+		},
+		{
+			name:           "retry replays stored response",
+			statusCode:     http.StatusAccepted,
+			first:          newRequest("key-1", "a", mockUserID),
+			second:         newRequest("key-1", "a", mockUserID),
+			expectedCalls:  1,
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   `{"echo":"a"}`,
+			expectedReplay: true,
+		},
+		{
+			name:           "same key of another user is independent",
+			statusCode:     http.StatusOK,
+			first:          newRequest("key-1", "a", mockUserID),
+			second:         newRequest("key-1", "a", 888),
+			expectedCalls:  2,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"echo":"a"}`,
+		},
+		{
+			name:           "key reused with another body",
+			statusCode:     http.StatusOK,
+			first:          newRequest("key-1", "a", mockUserID),
+			second:         newRequest("key-1", "b", mockUserID),
+			expectedCalls:  1,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error":"idempotency key was used with another request"}`,
+		},
+		{
+			name:       "first request still in progress",
+			statusCode: http.StatusOK,
+			prepare: func(s *store.MemoryStore) {
+				req := newRequest("key-1", "a", mockUserID)
+				s.ReserveIdempotencyKey(context.Background(), mockUserID, "key-1", fingerprint(req, []byte("a")), time.Hour)
+			},
+			second:         newRequest("key-1", "a", mockUserID),
+			expectedCalls:  0,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"request with this idempotency key is in progress"}`,
+		},
+		{
+			name:           "server errors are not stored",
+			statusCode:     http.StatusInternalServerError,
+			first:          newRequest("key-1", "a", mockUserID),
+			second:         newRequest("key-1", "a", mockUserID),
+			expectedCalls:  2,
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"echo":"a"}`,
+		},
+		{
+			name:           "too long key",
+			statusCode:     http.StatusOK,
+			second:         newRequest(strings.Repeat("k", maxKeyLength+1), "a", mockUserID),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"idempotency key is too long"}`,
+		},
+		{
+			name:           "unauthorized",
+			statusCode:     http.StatusOK,
+			second:         newRequest("key-1", "a", 0),
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"User is not authorized"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataStore := store.NewMemoryStore()
+			if tt.prepare != nil {
+				tt.prepare(dataStore)
+			}
+			next := &countingHandler{statusCode: tt.statusCode}
+			handler := Idempotency(cfg, dataStore)(next)
+
+			if tt.first != nil {
+				handler.ServeHTTP(httptest.NewRecorder(), tt.first)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, tt.second)
+
+			assert.Equal(t, tt.expectedCalls, next.calls)
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			require.NotEmpty(t, rr.Body.String())
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			if tt.expectedReplay {
+				assert.Equal(t, "true", rr.Header().Get(HeaderReplayed))
+				assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			} else {
+				assert.Empty(t, rr.Header().Get(HeaderReplayed))
+			}
+		})
+	}
+}
+
+func TestIdempotencyExpiredKey(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+	dataStore := store.NewMemoryStore()
+	next := &countingHandler{statusCode: http.StatusOK}
+	handler := Idempotency(&config.Config{IdempotencyTTL: 0}, dataStore)(next)
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("key-1", "a", mockUserID))
+	time.Sleep(time.Millisecond)
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("key-1", "b", mockUserID))
+
+	assert.Equal(t, 2, next.calls)
+}
@@ -0,0 +1,105 @@
+package requestlog
+
+import (
+	"TimBerk/gophermart/internal/app/settings/logger"
+	"TimBerk/gophermart/pkg/secure"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+const (
+	HeaderRequestID = "X-Request-ID"
+
+	// maxRequestIDLength keeps IDs of clients and proxies while not letting them flood the log.
+	maxRequestIDLength = 128
+	requestIDSize      = 16
+)
+
+// RequestLog tags the request with an ID taken from X-Request-ID or generated, echoes it in the
+// response and puts it in the context, so every entry logged with that context carries it.
+// Once the request is served it is logged as one structured entry, a handler that panics,
+// like an export aborting a started response, is logged as an error before the panic goes on.
+func RequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(HeaderRequestID, requestID)
+		r = r.WithContext(logger.WithRequestID(r.Context(), requestID))
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			if rec := recover(); rec != nil {
+				logRequest(r, ww, start, true)
+				panic(rec)
+			}
+		}()
+		next.ServeHTTP(ww, r)
+		logRequest(r, ww, start, false)
+	})
+}
+
+func logRequest(r *http.Request, ww middleware.WrapResponseWriter, start time.Time, aborted bool) {
+	status := ww.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	logFields := logrus.WithContext(r.Context()).WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"route":       routePattern(r),
+		"status":      status,
+		"bytes":       ww.BytesWritten(),
+		"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+		"remote_addr": r.RemoteAddr,
+		"user_agent":  r.UserAgent(),
+	})
+	if aborted {
+		logFields.WithField("aborted", true).Error("request aborted")
+		return
+	}
+	if status >= http.StatusInternalServerError {
+		logFields.Error("request served")
+		return
+	}
+	logFields.Info("request served")
+}
+
+func routePattern(r *http.Request) string {
+	if routeCtx := chi.RouteContext(r.Context()); routeCtx != nil {
+		return routeCtx.RoutePattern()
+	}
+	return ""
+}
+
+// validRequestID accepts IDs made of URL-safe characters only, anything else is replaced
+// so it can't break the log format or the response header.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	requestID, err := secure.GenerateToken(requestIDSize)
+	if err != nil {
+		// The ID only correlates log entries, a predictable one is better than none.
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return requestID
+}
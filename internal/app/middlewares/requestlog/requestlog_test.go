@@ -0,0 +1,103 @@
+package requestlog
+
+import (
+	"TimBerk/gophermart/internal/app/settings/logger"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLog(t *testing.T) {
+	logrus.SetOutput(io.Discard)
+	logrus.AddHook(logger.RequestIDHook{})
+	hook := test.NewGlobal()
+
+	var handlerRequestID string
+	router := chi.NewRouter()
+	router.Use(RequestLog)
+	router.Get("/test/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+		handlerRequestID = logger.RequestID(r.Context())
+		w.WriteHeader(http.StatusAccepted)
+	})
+	router.Get("/test/fail", func(w http.ResponseWriter, r *http.Request) {
+		handlerRequestID = logger.RequestID(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	tests := []struct {
+		name          string
+		path          string
+		requestID     string
+		keepRequestID bool
+		status        int
+		route         string
+		level         logrus.Level
+	}{
+		{name: "generated id", path: "/test/orders/42", status: http.StatusAccepted, route: "/test/orders/{number}", level: logrus.InfoLevel},
+		{name: "id of client", path: "/test/orders/42", requestID: "3f2a-b7c1:edge.1", keepRequestID: true, status: http.StatusAccepted, route: "/test/orders/{number}", level: logrus.InfoLevel},
+		{name: "invalid id", path: "/test/orders/42", requestID: "bad id\r\n", status: http.StatusAccepted, route: "/test/orders/{number}", level: logrus.InfoLevel},
+		{name: "too long id", path: "/test/orders/42", requestID: strings.Repeat("a", maxRequestIDLength+1), status: http.StatusAccepted, route: "/test/orders/{number}", level: logrus.InfoLevel},
+		{name: "server error", path: "/test/fail", status: http.StatusInternalServerError, route: "/test/fail", level: logrus.ErrorLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook.Reset()
+			request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			request.Header.Set("Authorization", "Bearer secret")
+			if tt.requestID != "" {
+				request.Header.Set(HeaderRequestID, tt.requestID)
+			}
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, request)
+
+			requestID := rr.Header().Get(HeaderRequestID)
+			require.NotEmpty(t, requestID)
+			assert.Equal(t, tt.keepRequestID, requestID == tt.requestID)
+			assert.Equal(t, requestID, handlerRequestID)
+
+			entry := hook.LastEntry()
+			require.NotNil(t, entry)
+			assert.Equal(t, tt.level, entry.Level)
+			assert.Equal(t, requestID, entry.Data["request_id"])
+			assert.Equal(t, tt.status, entry.Data["status"])
+			assert.Equal(t, tt.route, entry.Data["route"])
+			assert.Equal(t, tt.path, entry.Data["path"])
+			assert.NotContains(t, entry.Data, "Authorization")
+		})
+	}
+}
+
+func TestRequestLogAbortedHandler(t *testing.T) {
+	logrus.SetOutput(io.Discard)
+	hook := test.NewGlobal()
+
+	router := chi.NewRouter()
+	router.Use(RequestLog)
+	router.Get("/test/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic(http.ErrAbortHandler)
+	})
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/export", nil))
+	}, "the panic goes on to abort the connection")
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, logrus.ErrorLevel, entry.Level)
+	assert.Equal(t, "request aborted", entry.Message)
+	assert.Equal(t, true, entry.Data["aborted"])
+	assert.Equal(t, "/test/export", entry.Data["route"])
+	assert.Equal(t, len("partial"), entry.Data["bytes"])
+	assert.Contains(t, entry.Data, "duration_ms")
+}
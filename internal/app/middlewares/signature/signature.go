@@ -0,0 +1,105 @@
+package signature
+
+import (
+	"TimBerk/gophermart/pkg/responses"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	HeaderSignature = "X-Accrual-Signature"
+	HeaderTimestamp = "X-Accrual-Timestamp"
+
+	// MaxBodyBytes limits callbacks, the endpoint is reachable before the signature is checked.
+	MaxBodyBytes = 16 << 10
+	// MaxClockSkew is how far the signed timestamp may be from the server clock,
+	// a captured callback can't be replayed once it is outside of the window.
+	MaxClockSkew = 5 * time.Minute
+
+	signaturePrefix = "sha256="
+)
+
+// Sign returns the value of the signature header for body sent at timestamp, a Unix time in seconds.
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(mac(secret, strconv.FormatInt(timestamp, 10), body))
+}
+
+func mac(secret []byte, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+func valid(secret []byte, timestamp string, body []byte, header string) bool {
+	received, err := hex.DecodeString(strings.TrimPrefix(header, signaturePrefix))
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(received, mac(secret, timestamp, body))
+}
+
+func fresh(timestamp string, now time.Time) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	skew := now.Sub(time.Unix(seconds, 0))
+	return skew <= MaxClockSkew && skew >= -MaxClockSkew
+}
+
+// Verify passes only requests signed with HMAC-SHA256 by secret. The signature covers the
+// X-Accrual-Timestamp header and the body joined by a dot, it is expected in the X-Accrual-Signature
+// header as "sha256=<hex>". Requests with a timestamp off by more than MaxClockSkew are rejected.
+func Verify(secret []byte) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var errMessage string
+			logFields := logrus.WithContext(r.Context()).WithFields(logrus.Fields{"action": "M.Signature"})
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				errMessage = fmt.Sprintf("request body must be at most %d bytes", MaxBodyBytes)
+				logFields.WithField("error", err).Warning(errMessage)
+				responses.WriteJSONError(w, errMessage, http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				errMessage = "failed to read request body"
+				logFields.WithField("error", err).Warning(errMessage)
+				responses.WriteJSONError(w, errMessage, http.StatusBadRequest)
+				return
+			}
+
+			timestamp := r.Header.Get(HeaderTimestamp)
+			if !valid(secret, timestamp, body, r.Header.Get(HeaderSignature)) {
+				errMessage = "invalid request signature"
+				logFields.Warning(errMessage)
+				responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
+				return
+			}
+			if !fresh(timestamp, time.Now()) {
+				errMessage = "request timestamp is outside of the allowed window"
+				logFields.WithField("timestamp", timestamp).Warning(errMessage)
+				responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package signature
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+	secret := []byte("secret")
+	body := `{"order":"50405077004","status":"PROCESSED","accrual":100}`
+	now := time.Now().Unix()
+	stale := now - int64(MaxClockSkew/time.Second) - 60
+	ahead := now + int64(MaxClockSkew/time.Second) + 60
+
+	tests := []struct {
+		name           string
+		body           string
+		timestamp      int64
+		signature      string
+		expectedStatus int
+	}{
+		{name: "valid signature", timestamp: now, signature: Sign(secret, now, []byte(body)), expectedStatus: http.StatusOK},
+		{name: "signature without prefix", timestamp: now, signature: strings.TrimPrefix(Sign(secret, now, []byte(body)), "sha256="), expectedStatus: http.StatusOK},
+		{name: "missing signature", timestamp: now, expectedStatus: http.StatusUnauthorized},
+		{name: "missing timestamp", signature: Sign(secret, now, []byte(body)), expectedStatus: http.StatusUnauthorized},
+		{name: "another secret", timestamp: now, signature: Sign([]byte("other"), now, []byte(body)), expectedStatus: http.StatusUnauthorized},
+		{name: "another body", timestamp: now, signature: Sign(secret, now, []byte("{}")), expectedStatus: http.StatusUnauthorized},
+		{name: "another timestamp", timestamp: now, signature: Sign(secret, now-1, []byte(body)), expectedStatus: http.StatusUnauthorized},
+		{name: "replayed callback", timestamp: stale, signature: Sign(secret, stale, []byte(body)), expectedStatus: http.StatusUnauthorized},
+		{name: "timestamp ahead", timestamp: ahead, signature: Sign(secret, ahead, []byte(body)), expectedStatus: http.StatusUnauthorized},
+		{name: "not hex", timestamp: now, signature: "sha256=zz", expectedStatus: http.StatusUnauthorized},
+		{
+			name:           "body too large",
+			body:           strings.Repeat(" ", MaxBodyBytes+1),
+			timestamp:      now,
+			signature:      Sign(secret, now, []byte(strings.Repeat(" ", MaxBodyBytes+1))),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				received = string(data)
+			})
+
+			requestBody := body
+			if tt.body != "" {
+				requestBody = tt.body
+			}
+			req := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(requestBody))
+			if tt.timestamp != 0 {
+				req.Header.Set(HeaderTimestamp, strconv.FormatInt(tt.timestamp, 10))
+			}
+			if tt.signature != "" {
+				req.Header.Set(HeaderSignature, tt.signature)
+			}
+			rr := httptest.NewRecorder()
+			Verify(secret)(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, body, received, "body must be readable by the next handler")
+			} else {
+				assert.Empty(t, received)
+			}
+		})
+	}
+}
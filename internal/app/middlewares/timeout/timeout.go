@@ -0,0 +1,24 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Timeout cancels the context of the request after duration, store queries made with it are aborted
+// and handlers answer 503 for them. Nothing is written here: a handler that returns without an answer
+// has succeeded, so an implicit 200 is right even after the deadline. A zero duration disables the limit.
+func Timeout(duration time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if duration <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), duration)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
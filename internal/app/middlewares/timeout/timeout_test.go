@@ -0,0 +1,70 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		duration    time.Duration
+		parent      time.Duration
+		hasDeadline bool
+		maxDeadline time.Duration
+	}{
+		{name: "deadline is set", duration: time.Minute, hasDeadline: true, maxDeadline: time.Minute},
+		{name: "shorter parent deadline wins", duration: time.Hour, parent: time.Minute, hasDeadline: true, maxDeadline: time.Minute},
+		{name: "disabled", duration: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.parent > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.parent)
+				defer cancel()
+			}
+
+			var deadline time.Time
+			var hasDeadline bool
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				deadline, hasDeadline = r.Context().Deadline()
+			})
+			start := time.Now()
+			Timeout(tt.duration)(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+			assert.Equal(t, tt.hasDeadline, hasDeadline)
+			if tt.hasDeadline {
+				assert.WithinDuration(t, start.Add(tt.maxDeadline), deadline, time.Second)
+			}
+		})
+	}
+}
+
+func TestTimeoutCancelsRequest(t *testing.T) {
+	var err error
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		err = r.Context().Err()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		Timeout(10*time.Millisecond)(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("request was not cancelled")
+	}
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -9,6 +9,22 @@ type RequestData struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// DeleteRequest confirms account deletion with the password, Force deletes the account
+// even with a positive balance or orders in processing.
+type DeleteRequest struct {
+	Password string `json:"password"`
+	Force    bool   `json:"force"`
+}
+
 func (rd *RequestData) Validate() error {
 	if rd.Username == "" {
 		return fmt.Errorf("username is required and cannot be empty")
@@ -18,3 +34,13 @@ func (rd *RequestData) Validate() error {
 	}
 	return nil
 }
+
+func (r *ChangePasswordRequest) Validate() error {
+	if r.CurrentPassword == "" {
+		return fmt.Errorf("current password is required and cannot be empty")
+	}
+	if r.NewPassword == "" {
+		return fmt.Errorf("new password is required and cannot be empty")
+	}
+	return nil
+}
@@ -90,3 +90,215 @@ func (v *RequestData) UnmarshalJSON(data []byte) error {
 func (v *RequestData) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	DecodeModelsAuth(l, v)
 }
+func DecodeModelsAuth1(in *jlexer.Lexer, out *RefreshRequest) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "refresh_token":
+			out.RefreshToken = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeModelsAuth1(out *jwriter.Writer, in RefreshRequest) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"refresh_token\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.RefreshToken))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v RefreshRequest) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeModelsAuth1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v RefreshRequest) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeModelsAuth1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *RefreshRequest) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeModelsAuth1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *RefreshRequest) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeModelsAuth1(l, v)
+}
+func DecodeModelsAuth2(in *jlexer.Lexer, out *DeleteRequest) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "password":
+			out.Password = string(in.String())
+		case "force":
+			out.Force = bool(in.Bool())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeModelsAuth2(out *jwriter.Writer, in DeleteRequest) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"password\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Password))
+	}
+	{
+		const prefix string = ",\"force\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Force))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v DeleteRequest) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeModelsAuth2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v DeleteRequest) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeModelsAuth2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *DeleteRequest) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeModelsAuth2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *DeleteRequest) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeModelsAuth2(l, v)
+}
+func DecodeModelsAuth3(in *jlexer.Lexer, out *ChangePasswordRequest) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "current_password":
+			out.CurrentPassword = string(in.String())
+		case "new_password":
+			out.NewPassword = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeModelsAuth3(out *jwriter.Writer, in ChangePasswordRequest) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"current_password\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.CurrentPassword))
+	}
+	{
+		const prefix string = ",\"new_password\":"
+		out.RawString(prefix)
+		out.String(string(in.NewPassword))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ChangePasswordRequest) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeModelsAuth3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ChangePasswordRequest) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeModelsAuth3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ChangePasswordRequest) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeModelsAuth3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ChangePasswordRequest) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeModelsAuth3(l, v)
+}
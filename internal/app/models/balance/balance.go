@@ -1,6 +1,7 @@
 package balance
 
 import (
+	"TimBerk/gophermart/pkg/money"
 	"TimBerk/gophermart/pkg/validators"
 	"fmt"
 	"time"
@@ -9,34 +10,69 @@ import (
 //go:generate easyjson -all -snake_case balance.go
 
 type Balance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   money.Amount `json:"current"`
+	Withdrawn money.Amount `json:"withdrawn"`
 }
 
 //easyjson:json
 type WithdrawnRequest struct {
-	Number string  `json:"order"`
-	Sum    float64 `json:"sum"`
+	Number string       `json:"order"`
+	Sum    money.Amount `json:"sum"`
 }
 
 //easyjson:json
 type WithdrawnResponse struct {
-	Number    string    `json:"order"`
-	Sum       float64   `json:"sum"`
-	CreatedAt time.Time `json:"processed_at"`
+	Number    string       `json:"order"`
+	Sum       money.Amount `json:"sum"`
+	CreatedAt time.Time    `json:"processed_at"`
 }
 
 //easyjson:json
 type WithdrawnList []WithdrawnResponse
 
+//easyjson:json
+type WithdrawnSummary struct {
+	Count int64        `json:"count"`
+	Sum   money.Amount `json:"sum"`
+}
+
+// WithdrawnPage is returned instead of the plain list when the summary of the period is requested.
+//
+//easyjson:json
+type WithdrawnPage struct {
+	Withdrawals WithdrawnList     `json:"withdrawals"`
+	Summary     *WithdrawnSummary `json:"summary"`
+}
+
+//easyjson:json
+type HistoryEntry struct {
+	ID        int64        `json:"id"`
+	Type      string       `json:"type"`
+	Debit     string       `json:"debit"`
+	Credit    string       `json:"credit"`
+	Amount    money.Amount `json:"amount"`
+	Order     string       `json:"order,omitempty"`
+	Comment   string       `json:"comment,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+//easyjson:json
+type HistoryList []HistoryEntry
+
+//easyjson:json
+type HistoryResponse struct {
+	Entries    HistoryList `json:"entries"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
 func (w *WithdrawnRequest) Validate() error {
 	err := validators.ValidateOrderNumber(w.Number)
 	if err != nil {
 		return err
 	}
 
-	if w.Sum < 0 {
-		return fmt.Errorf("sum must be grater or equal 0")
+	if !w.Sum.IsPositive() {
+		return fmt.Errorf("sum must be greater than 0")
 	}
 	return nil
 }
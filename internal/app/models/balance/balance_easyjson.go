@@ -17,7 +17,80 @@ var (
 	_ easyjson.Marshaler
 )
 
-func DecodeModelsBalance(in *jlexer.Lexer, out *WithdrawnResponse) {
+func DecodeModelsBalance(in *jlexer.Lexer, out *WithdrawnSummary) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "count":
+			out.Count = int64(in.Int64())
+		case "sum":
+			(out.Sum).UnmarshalEasyJSON(in)
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeModelsBalance(out *jwriter.Writer, in WithdrawnSummary) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"count\":"
+		out.RawString(prefix[1:])
+		out.Int64(int64(in.Count))
+	}
+	{
+		const prefix string = ",\"sum\":"
+		out.RawString(prefix)
+		(in.Sum).MarshalEasyJSON(out)
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v WithdrawnSummary) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeModelsBalance(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v WithdrawnSummary) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeModelsBalance(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *WithdrawnSummary) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeModelsBalance(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *WithdrawnSummary) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeModelsBalance(l, v)
+}
+func DecodeModelsBalance1(in *jlexer.Lexer, out *WithdrawnResponse) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -39,7 +112,7 @@ func DecodeModelsBalance(in *jlexer.Lexer, out *WithdrawnResponse) {
 		case "order":
 			out.Number = string(in.String())
 		case "sum":
-			out.Sum = float64(in.Float64())
+			(out.Sum).UnmarshalEasyJSON(in)
 		case "processed_at":
 			if data := in.Raw(); in.Ok() {
 				in.AddError((out.CreatedAt).UnmarshalJSON(data))
@@ -54,7 +127,7 @@ func DecodeModelsBalance(in *jlexer.Lexer, out *WithdrawnResponse) {
 		in.Consumed()
 	}
 }
-func EncodeModelsBalance(out *jwriter.Writer, in WithdrawnResponse) {
+func EncodeModelsBalance1(out *jwriter.Writer, in WithdrawnResponse) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -66,7 +139,7 @@ func EncodeModelsBalance(out *jwriter.Writer, in WithdrawnResponse) {
 	{
 		const prefix string = ",\"sum\":"
 		out.RawString(prefix)
-		out.Float64(float64(in.Sum))
+		(in.Sum).MarshalEasyJSON(out)
 	}
 	{
 		const prefix string = ",\"processed_at\":"
@@ -79,27 +152,27 @@ func EncodeModelsBalance(out *jwriter.Writer, in WithdrawnResponse) {
 // MarshalJSON supports json.Marshaler interface
 func (v WithdrawnResponse) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	EncodeModelsBalance(&w, v)
+	EncodeModelsBalance1(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v WithdrawnResponse) MarshalEasyJSON(w *jwriter.Writer) {
-	EncodeModelsBalance(w, v)
+	EncodeModelsBalance1(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *WithdrawnResponse) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	DecodeModelsBalance(&r, v)
+	DecodeModelsBalance1(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *WithdrawnResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	DecodeModelsBalance(l, v)
+	DecodeModelsBalance1(l, v)
 }
-func DecodeModelsBalance1(in *jlexer.Lexer, out *WithdrawnRequest) {
+func DecodeModelsBalance2(in *jlexer.Lexer, out *WithdrawnRequest) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -121,7 +194,7 @@ func DecodeModelsBalance1(in *jlexer.Lexer, out *WithdrawnRequest) {
 		case "order":
 			out.Number = string(in.String())
 		case "sum":
-			out.Sum = float64(in.Float64())
+			(out.Sum).UnmarshalEasyJSON(in)
 		default:
 			in.SkipRecursive()
 		}
@@ -132,7 +205,7 @@ func DecodeModelsBalance1(in *jlexer.Lexer, out *WithdrawnRequest) {
 		in.Consumed()
 	}
 }
-func EncodeModelsBalance1(out *jwriter.Writer, in WithdrawnRequest) {
+func EncodeModelsBalance2(out *jwriter.Writer, in WithdrawnRequest) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -144,7 +217,7 @@ func EncodeModelsBalance1(out *jwriter.Writer, in WithdrawnRequest) {
 	{
 		const prefix string = ",\"sum\":"
 		out.RawString(prefix)
-		out.Float64(float64(in.Sum))
+		(in.Sum).MarshalEasyJSON(out)
 	}
 	out.RawByte('}')
 }
@@ -152,27 +225,112 @@ func EncodeModelsBalance1(out *jwriter.Writer, in WithdrawnRequest) {
 // MarshalJSON supports json.Marshaler interface
 func (v WithdrawnRequest) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	EncodeModelsBalance1(&w, v)
+	EncodeModelsBalance2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v WithdrawnRequest) MarshalEasyJSON(w *jwriter.Writer) {
-	EncodeModelsBalance1(w, v)
+	EncodeModelsBalance2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *WithdrawnRequest) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	DecodeModelsBalance1(&r, v)
+	DecodeModelsBalance2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *WithdrawnRequest) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	DecodeModelsBalance1(l, v)
+	DecodeModelsBalance2(l, v)
+}
+func DecodeModelsBalance3(in *jlexer.Lexer, out *WithdrawnPage) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "withdrawals":
+			(out.Withdrawals).UnmarshalEasyJSON(in)
+		case "summary":
+			if in.IsNull() {
+				in.Skip()
+				out.Summary = nil
+			} else {
+				if out.Summary == nil {
+					out.Summary = new(WithdrawnSummary)
+				}
+				(*out.Summary).UnmarshalEasyJSON(in)
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeModelsBalance3(out *jwriter.Writer, in WithdrawnPage) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"withdrawals\":"
+		out.RawString(prefix[1:])
+		(in.Withdrawals).MarshalEasyJSON(out)
+	}
+	{
+		const prefix string = ",\"summary\":"
+		out.RawString(prefix)
+		if in.Summary == nil {
+			out.RawString("null")
+		} else {
+			(*in.Summary).MarshalEasyJSON(out)
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v WithdrawnPage) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeModelsBalance3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
 }
-func DecodeModelsBalance2(in *jlexer.Lexer, out *WithdrawnList) {
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v WithdrawnPage) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeModelsBalance3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *WithdrawnPage) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeModelsBalance3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *WithdrawnPage) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeModelsBalance3(l, v)
+}
+func DecodeModelsBalance4(in *jlexer.Lexer, out *WithdrawnList) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		in.Skip()
@@ -200,7 +358,7 @@ func DecodeModelsBalance2(in *jlexer.Lexer, out *WithdrawnList) {
 		in.Consumed()
 	}
 }
-func EncodeModelsBalance2(out *jwriter.Writer, in WithdrawnList) {
+func EncodeModelsBalance4(out *jwriter.Writer, in WithdrawnList) {
 	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
 		out.RawString("null")
 	} else {
@@ -218,27 +376,283 @@ func EncodeModelsBalance2(out *jwriter.Writer, in WithdrawnList) {
 // MarshalJSON supports json.Marshaler interface
 func (v WithdrawnList) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	EncodeModelsBalance2(&w, v)
+	EncodeModelsBalance4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v WithdrawnList) MarshalEasyJSON(w *jwriter.Writer) {
-	EncodeModelsBalance2(w, v)
+	EncodeModelsBalance4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *WithdrawnList) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	DecodeModelsBalance2(&r, v)
+	DecodeModelsBalance4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *WithdrawnList) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	DecodeModelsBalance2(l, v)
+	DecodeModelsBalance4(l, v)
 }
-func DecodeModelsBalance3(in *jlexer.Lexer, out *Balance) {
+func DecodeModelsBalance5(in *jlexer.Lexer, out *HistoryResponse) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "entries":
+			(out.Entries).UnmarshalEasyJSON(in)
+		case "next_cursor":
+			out.NextCursor = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeModelsBalance5(out *jwriter.Writer, in HistoryResponse) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"entries\":"
+		out.RawString(prefix[1:])
+		(in.Entries).MarshalEasyJSON(out)
+	}
+	if in.NextCursor != "" {
+		const prefix string = ",\"next_cursor\":"
+		out.RawString(prefix)
+		out.String(string(in.NextCursor))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v HistoryResponse) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeModelsBalance5(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v HistoryResponse) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeModelsBalance5(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *HistoryResponse) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeModelsBalance5(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *HistoryResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeModelsBalance5(l, v)
+}
+func DecodeModelsBalance6(in *jlexer.Lexer, out *HistoryList) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(HistoryList, 0, 0)
+			} else {
+				*out = HistoryList{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v4 HistoryEntry
+			(v4).UnmarshalEasyJSON(in)
+			*out = append(*out, v4)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeModelsBalance6(out *jwriter.Writer, in HistoryList) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v5, v6 := range in {
+			if v5 > 0 {
+				out.RawByte(',')
+			}
+			(v6).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v HistoryList) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeModelsBalance6(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v HistoryList) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeModelsBalance6(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *HistoryList) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeModelsBalance6(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *HistoryList) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeModelsBalance6(l, v)
+}
+func DecodeModelsBalance7(in *jlexer.Lexer, out *HistoryEntry) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "id":
+			out.ID = int64(in.Int64())
+		case "type":
+			out.Type = string(in.String())
+		case "debit":
+			out.Debit = string(in.String())
+		case "credit":
+			out.Credit = string(in.String())
+		case "amount":
+			(out.Amount).UnmarshalEasyJSON(in)
+		case "order":
+			out.Order = string(in.String())
+		case "comment":
+			out.Comment = string(in.String())
+		case "created_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.CreatedAt).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeModelsBalance7(out *jwriter.Writer, in HistoryEntry) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"id\":"
+		out.RawString(prefix[1:])
+		out.Int64(int64(in.ID))
+	}
+	{
+		const prefix string = ",\"type\":"
+		out.RawString(prefix)
+		out.String(string(in.Type))
+	}
+	{
+		const prefix string = ",\"debit\":"
+		out.RawString(prefix)
+		out.String(string(in.Debit))
+	}
+	{
+		const prefix string = ",\"credit\":"
+		out.RawString(prefix)
+		out.String(string(in.Credit))
+	}
+	{
+		const prefix string = ",\"amount\":"
+		out.RawString(prefix)
+		(in.Amount).MarshalEasyJSON(out)
+	}
+	if in.Order != "" {
+		const prefix string = ",\"order\":"
+		out.RawString(prefix)
+		out.String(string(in.Order))
+	}
+	if in.Comment != "" {
+		const prefix string = ",\"comment\":"
+		out.RawString(prefix)
+		out.String(string(in.Comment))
+	}
+	{
+		const prefix string = ",\"created_at\":"
+		out.RawString(prefix)
+		out.Raw((in.CreatedAt).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v HistoryEntry) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeModelsBalance7(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v HistoryEntry) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeModelsBalance7(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *HistoryEntry) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeModelsBalance7(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *HistoryEntry) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeModelsBalance7(l, v)
+}
+func DecodeModelsBalance8(in *jlexer.Lexer, out *Balance) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -258,9 +672,9 @@ func DecodeModelsBalance3(in *jlexer.Lexer, out *Balance) {
 		}
 		switch key {
 		case "current":
-			out.Current = float64(in.Float64())
+			(out.Current).UnmarshalEasyJSON(in)
 		case "withdrawn":
-			out.Withdrawn = float64(in.Float64())
+			(out.Withdrawn).UnmarshalEasyJSON(in)
 		default:
 			in.SkipRecursive()
 		}
@@ -271,19 +685,19 @@ func DecodeModelsBalance3(in *jlexer.Lexer, out *Balance) {
 		in.Consumed()
 	}
 }
-func EncodeModelsBalance3(out *jwriter.Writer, in Balance) {
+func EncodeModelsBalance8(out *jwriter.Writer, in Balance) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
 		const prefix string = ",\"current\":"
 		out.RawString(prefix[1:])
-		out.Float64(float64(in.Current))
+		(in.Current).MarshalEasyJSON(out)
 	}
 	{
 		const prefix string = ",\"withdrawn\":"
 		out.RawString(prefix)
-		out.Float64(float64(in.Withdrawn))
+		(in.Withdrawn).MarshalEasyJSON(out)
 	}
 	out.RawByte('}')
 }
@@ -291,23 +705,23 @@ func EncodeModelsBalance3(out *jwriter.Writer, in Balance) {
 // MarshalJSON supports json.Marshaler interface
 func (v Balance) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	EncodeModelsBalance3(&w, v)
+	EncodeModelsBalance8(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Balance) MarshalEasyJSON(w *jwriter.Writer) {
-	EncodeModelsBalance3(w, v)
+	EncodeModelsBalance8(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Balance) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	DecodeModelsBalance3(&r, v)
+	DecodeModelsBalance8(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Balance) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	DecodeModelsBalance3(l, v)
+	DecodeModelsBalance8(l, v)
 }
@@ -1,28 +1,42 @@
 package order
 
 import (
+	"TimBerk/gophermart/pkg/money"
 	"time"
 )
 
 //go:generate easyjson -all -snake_case order.go
 
 type OrderDetailResponse struct {
-	Number  string   `json:"order"`
-	Status  string   `json:"status"`
-	Accrual *float64 `json:"accrual,omitempty"`
+	Number  string        `json:"order"`
+	Status  string        `json:"status"`
+	Accrual *money.Amount `json:"accrual,omitempty"`
 }
 
 //easyjson:json
 type OrderResponse struct {
-	Number    string    `json:"number"`
-	Status    string    `json:"status"`
-	Accrual   *float64  `json:"accrual,omitempty"`
-	CreatedAt time.Time `json:"uploaded_at"`
+	Number    string        `json:"number"`
+	Status    string        `json:"status"`
+	Accrual   *money.Amount `json:"accrual,omitempty"`
+	CreatedAt time.Time     `json:"uploaded_at"`
 }
 
 //easyjson:json
 type OrderListResponse []OrderResponse
 
+//easyjson:json
+type OrderUploadRequest []string
+
+//easyjson:json
+type OrderUploadResult struct {
+	Number string `json:"number"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+//easyjson:json
+type OrderUploadResponse []OrderUploadResult
+
 //easyjson:json
 type OrderAccrualRegister struct {
 	Number string `json:"order"`
@@ -30,9 +44,9 @@ type OrderAccrualRegister struct {
 
 //easyjson:json
 type OrderAccrual struct {
-	Number  string   `json:"order"`
-	Status  string   `json:"status"`
-	Accrual *float64 `json:"accrual,omitempty"`
+	Number  string        `json:"order"`
+	Status  string        `json:"status"`
+	Accrual *money.Amount `json:"accrual,omitempty"`
 }
 
 //easyjson:json
@@ -3,6 +3,7 @@
 package order
 
 import (
+	money "TimBerk/gophermart/pkg/money"
 	json "encoding/json"
 	easyjson "github.com/mailru/easyjson"
 	jlexer "github.com/mailru/easyjson/jlexer"
@@ -97,7 +98,219 @@ func (v *UserOrder) UnmarshalJSON(data []byte) error {
 func (v *UserOrder) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	DecodeAppModelsOrder(l, v)
 }
-func DecodeAppModelsOrder1(in *jlexer.Lexer, out *OrderResponse) {
+func DecodeAppModelsOrder1(in *jlexer.Lexer, out *OrderUploadResult) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "number":
+			out.Number = string(in.String())
+		case "result":
+			out.Result = string(in.String())
+		case "error":
+			out.Error = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeAppModelsOrder1(out *jwriter.Writer, in OrderUploadResult) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"number\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Number))
+	}
+	{
+		const prefix string = ",\"result\":"
+		out.RawString(prefix)
+		out.String(string(in.Result))
+	}
+	if in.Error != "" {
+		const prefix string = ",\"error\":"
+		out.RawString(prefix)
+		out.String(string(in.Error))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v OrderUploadResult) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeAppModelsOrder1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v OrderUploadResult) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeAppModelsOrder1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *OrderUploadResult) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeAppModelsOrder1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *OrderUploadResult) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeAppModelsOrder1(l, v)
+}
+func DecodeAppModelsOrder2(in *jlexer.Lexer, out *OrderUploadResponse) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(OrderUploadResponse, 0, 1)
+			} else {
+				*out = OrderUploadResponse{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v1 OrderUploadResult
+			(v1).UnmarshalEasyJSON(in)
+			*out = append(*out, v1)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeAppModelsOrder2(out *jwriter.Writer, in OrderUploadResponse) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v2, v3 := range in {
+			if v2 > 0 {
+				out.RawByte(',')
+			}
+			(v3).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v OrderUploadResponse) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeAppModelsOrder2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v OrderUploadResponse) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeAppModelsOrder2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *OrderUploadResponse) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeAppModelsOrder2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *OrderUploadResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeAppModelsOrder2(l, v)
+}
+func DecodeAppModelsOrder3(in *jlexer.Lexer, out *OrderUploadRequest) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(OrderUploadRequest, 0, 4)
+			} else {
+				*out = OrderUploadRequest{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v4 string
+			v4 = string(in.String())
+			*out = append(*out, v4)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func EncodeAppModelsOrder3(out *jwriter.Writer, in OrderUploadRequest) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v5, v6 := range in {
+			if v5 > 0 {
+				out.RawByte(',')
+			}
+			out.String(string(v6))
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v OrderUploadRequest) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	EncodeAppModelsOrder3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v OrderUploadRequest) MarshalEasyJSON(w *jwriter.Writer) {
+	EncodeAppModelsOrder3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *OrderUploadRequest) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	DecodeAppModelsOrder3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *OrderUploadRequest) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	DecodeAppModelsOrder3(l, v)
+}
+func DecodeAppModelsOrder4(in *jlexer.Lexer, out *OrderResponse) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -126,9 +339,9 @@ func DecodeAppModelsOrder1(in *jlexer.Lexer, out *OrderResponse) {
 				out.Accrual = nil
 			} else {
 				if out.Accrual == nil {
-					out.Accrual = new(float64)
+					out.Accrual = new(money.Amount)
 				}
-				*out.Accrual = float64(in.Float64())
+				(*out.Accrual).UnmarshalEasyJSON(in)
 			}
 		case "uploaded_at":
 			if data := in.Raw(); in.Ok() {
@@ -144,7 +357,7 @@ func DecodeAppModelsOrder1(in *jlexer.Lexer, out *OrderResponse) {
 		in.Consumed()
 	}
 }
-func EncodeAppModelsOrder1(out *jwriter.Writer, in OrderResponse) {
+func EncodeAppModelsOrder4(out *jwriter.Writer, in OrderResponse) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -161,7 +374,7 @@ func EncodeAppModelsOrder1(out *jwriter.Writer, in OrderResponse) {
 	if in.Accrual != nil {
 		const prefix string = ",\"accrual\":"
 		out.RawString(prefix)
-		out.Float64(float64(*in.Accrual))
+		(*in.Accrual).MarshalEasyJSON(out)
 	}
 	{
 		const prefix string = ",\"uploaded_at\":"
@@ -174,27 +387,27 @@ func EncodeAppModelsOrder1(out *jwriter.Writer, in OrderResponse) {
 // MarshalJSON supports json.Marshaler interface
 func (v OrderResponse) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	EncodeAppModelsOrder1(&w, v)
+	EncodeAppModelsOrder4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v OrderResponse) MarshalEasyJSON(w *jwriter.Writer) {
-	EncodeAppModelsOrder1(w, v)
+	EncodeAppModelsOrder4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *OrderResponse) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	DecodeAppModelsOrder1(&r, v)
+	DecodeAppModelsOrder4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *OrderResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	DecodeAppModelsOrder1(l, v)
+	DecodeAppModelsOrder4(l, v)
 }
-func DecodeAppModelsOrder2(in *jlexer.Lexer, out *OrderListResponse) {
+func DecodeAppModelsOrder5(in *jlexer.Lexer, out *OrderListResponse) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		in.Skip()
@@ -211,9 +424,9 @@ func DecodeAppModelsOrder2(in *jlexer.Lexer, out *OrderListResponse) {
 			*out = (*out)[:0]
 		}
 		for !in.IsDelim(']') {
-			var v1 OrderResponse
-			(v1).UnmarshalEasyJSON(in)
-			*out = append(*out, v1)
+			var v7 OrderResponse
+			(v7).UnmarshalEasyJSON(in)
+			*out = append(*out, v7)
 			in.WantComma()
 		}
 		in.Delim(']')
@@ -222,16 +435,16 @@ func DecodeAppModelsOrder2(in *jlexer.Lexer, out *OrderListResponse) {
 		in.Consumed()
 	}
 }
-func EncodeAppModelsOrder2(out *jwriter.Writer, in OrderListResponse) {
+func EncodeAppModelsOrder5(out *jwriter.Writer, in OrderListResponse) {
 	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
 		out.RawString("null")
 	} else {
 		out.RawByte('[')
-		for v2, v3 := range in {
-			if v2 > 0 {
+		for v8, v9 := range in {
+			if v8 > 0 {
 				out.RawByte(',')
 			}
-			(v3).MarshalEasyJSON(out)
+			(v9).MarshalEasyJSON(out)
 		}
 		out.RawByte(']')
 	}
@@ -240,27 +453,27 @@ func EncodeAppModelsOrder2(out *jwriter.Writer, in OrderListResponse) {
 // MarshalJSON supports json.Marshaler interface
 func (v OrderListResponse) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	EncodeAppModelsOrder2(&w, v)
+	EncodeAppModelsOrder5(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v OrderListResponse) MarshalEasyJSON(w *jwriter.Writer) {
-	EncodeAppModelsOrder2(w, v)
+	EncodeAppModelsOrder5(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *OrderListResponse) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	DecodeAppModelsOrder2(&r, v)
+	DecodeAppModelsOrder5(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *OrderListResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	DecodeAppModelsOrder2(l, v)
+	DecodeAppModelsOrder5(l, v)
 }
-func DecodeAppModelsOrder3(in *jlexer.Lexer, out *OrderDetailResponse) {
+func DecodeAppModelsOrder6(in *jlexer.Lexer, out *OrderDetailResponse) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -289,9 +502,9 @@ func DecodeAppModelsOrder3(in *jlexer.Lexer, out *OrderDetailResponse) {
 				out.Accrual = nil
 			} else {
 				if out.Accrual == nil {
-					out.Accrual = new(float64)
+					out.Accrual = new(money.Amount)
 				}
-				*out.Accrual = float64(in.Float64())
+				(*out.Accrual).UnmarshalEasyJSON(in)
 			}
 		default:
 			in.SkipRecursive()
@@ -303,7 +516,7 @@ func DecodeAppModelsOrder3(in *jlexer.Lexer, out *OrderDetailResponse) {
 		in.Consumed()
 	}
 }
-func EncodeAppModelsOrder3(out *jwriter.Writer, in OrderDetailResponse) {
+func EncodeAppModelsOrder6(out *jwriter.Writer, in OrderDetailResponse) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -320,7 +533,7 @@ func EncodeAppModelsOrder3(out *jwriter.Writer, in OrderDetailResponse) {
 	if in.Accrual != nil {
 		const prefix string = ",\"accrual\":"
 		out.RawString(prefix)
-		out.Float64(float64(*in.Accrual))
+		(*in.Accrual).MarshalEasyJSON(out)
 	}
 	out.RawByte('}')
 }
@@ -328,27 +541,27 @@ func EncodeAppModelsOrder3(out *jwriter.Writer, in OrderDetailResponse) {
 // MarshalJSON supports json.Marshaler interface
 func (v OrderDetailResponse) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	EncodeAppModelsOrder3(&w, v)
+	EncodeAppModelsOrder6(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v OrderDetailResponse) MarshalEasyJSON(w *jwriter.Writer) {
-	EncodeAppModelsOrder3(w, v)
+	EncodeAppModelsOrder6(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *OrderDetailResponse) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	DecodeAppModelsOrder3(&r, v)
+	DecodeAppModelsOrder6(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *OrderDetailResponse) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	DecodeAppModelsOrder3(l, v)
+	DecodeAppModelsOrder6(l, v)
 }
-func DecodeAppModelsOrder4(in *jlexer.Lexer, out *OrderAccrualRegister) {
+func DecodeAppModelsOrder7(in *jlexer.Lexer, out *OrderAccrualRegister) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -379,7 +592,7 @@ func DecodeAppModelsOrder4(in *jlexer.Lexer, out *OrderAccrualRegister) {
 		in.Consumed()
 	}
 }
-func EncodeAppModelsOrder4(out *jwriter.Writer, in OrderAccrualRegister) {
+func EncodeAppModelsOrder7(out *jwriter.Writer, in OrderAccrualRegister) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -394,27 +607,27 @@ func EncodeAppModelsOrder4(out *jwriter.Writer, in OrderAccrualRegister) {
 // MarshalJSON supports json.Marshaler interface
 func (v OrderAccrualRegister) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	EncodeAppModelsOrder4(&w, v)
+	EncodeAppModelsOrder7(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v OrderAccrualRegister) MarshalEasyJSON(w *jwriter.Writer) {
-	EncodeAppModelsOrder4(w, v)
+	EncodeAppModelsOrder7(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *OrderAccrualRegister) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	DecodeAppModelsOrder4(&r, v)
+	DecodeAppModelsOrder7(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *OrderAccrualRegister) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	DecodeAppModelsOrder4(l, v)
+	DecodeAppModelsOrder7(l, v)
 }
-func DecodeAppModelsOrder5(in *jlexer.Lexer, out *OrderAccrual) {
+func DecodeAppModelsOrder8(in *jlexer.Lexer, out *OrderAccrual) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -443,9 +656,9 @@ func DecodeAppModelsOrder5(in *jlexer.Lexer, out *OrderAccrual) {
 				out.Accrual = nil
 			} else {
 				if out.Accrual == nil {
-					out.Accrual = new(float64)
+					out.Accrual = new(money.Amount)
 				}
-				*out.Accrual = float64(in.Float64())
+				(*out.Accrual).UnmarshalEasyJSON(in)
 			}
 		default:
 			in.SkipRecursive()
@@ -457,7 +670,7 @@ func DecodeAppModelsOrder5(in *jlexer.Lexer, out *OrderAccrual) {
 		in.Consumed()
 	}
 }
-func EncodeAppModelsOrder5(out *jwriter.Writer, in OrderAccrual) {
+func EncodeAppModelsOrder8(out *jwriter.Writer, in OrderAccrual) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -474,7 +687,7 @@ func EncodeAppModelsOrder5(out *jwriter.Writer, in OrderAccrual) {
 	if in.Accrual != nil {
 		const prefix string = ",\"accrual\":"
 		out.RawString(prefix)
-		out.Float64(float64(*in.Accrual))
+		(*in.Accrual).MarshalEasyJSON(out)
 	}
 	out.RawByte('}')
 }
@@ -482,23 +695,23 @@ func EncodeAppModelsOrder5(out *jwriter.Writer, in OrderAccrual) {
 // MarshalJSON supports json.Marshaler interface
 func (v OrderAccrual) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	EncodeAppModelsOrder5(&w, v)
+	EncodeAppModelsOrder8(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v OrderAccrual) MarshalEasyJSON(w *jwriter.Writer) {
-	EncodeAppModelsOrder5(w, v)
+	EncodeAppModelsOrder8(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *OrderAccrual) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	DecodeAppModelsOrder5(&r, v)
+	DecodeAppModelsOrder8(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *OrderAccrual) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	DecodeAppModelsOrder5(l, v)
+	DecodeAppModelsOrder8(l, v)
 }
@@ -1,20 +1,54 @@
 package config
 
 import (
+	"TimBerk/gophermart/pkg/secure"
 	"flag"
+	"fmt"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/sirupsen/logrus"
+	"math"
 	"os"
 	"strconv"
 )
 
+const (
+	StorePostgres = "postgres"
+	StoreMemory   = "memory"
+)
+
 type Config struct {
 	RunAddress           string
 	DatabaseURI          string
 	AccrualSystemAddress string
+	StoreType            string
 	LogLevel             string `env:"LOGGING_LEVEL" default:"info"`
+	LogFormat            string `env:"LOG_FORMAT" default:"text"`
 	KeyJWT               []byte `env:"KEY_JWT" default:"gophermart"`
+	JWTKeys              string `env:"JWT_KEYS"`
 	ExpireJWT            int    `env:"EXPIRE_JWT" default:"60"`
+	ExpireRefresh        int    `env:"EXPIRE_REFRESH" default:"43200"`
+	IdempotencyTTL       int    `env:"IDEMPOTENCY_TTL" default:"1440"`
+	AccrualWorkers       int    `env:"ACCRUAL_WORKERS" default:"4"`
+	AccrualRateLimit     int    `env:"ACCRUAL_RATE_LIMIT" default:"10"`
+	AccrualCallbackKey   []byte `env:"ACCRUAL_CALLBACK_KEY"`
+	LoginMaxAttempts     int    `env:"LOGIN_MAX_ATTEMPTS" default:"5"`
+	LoginIPMaxAttempts   int    `env:"LOGIN_IP_MAX_ATTEMPTS" default:"20"`
+	LoginLockout         int    `env:"LOGIN_LOCKOUT" default:"30"`
+	LoginMaxLockout      int    `env:"LOGIN_MAX_LOCKOUT" default:"900"`
+	LoginWindow          int    `env:"LOGIN_WINDOW" default:"15"`
+	PasswordMinLength    int    `env:"PASSWORD_MIN_LENGTH" default:"8"`
+	PasswordMinClasses   int    `env:"PASSWORD_MIN_CLASSES" default:"2"`
+	PasswordHash         string `env:"PASSWORD_HASH" default:"argon2id"`
+	BcryptCost           int    `env:"BCRYPT_COST" default:"14"`
+	Argon2Time           int    `env:"ARGON2_TIME" default:"3"`
+	Argon2Memory         int    `env:"ARGON2_MEMORY" default:"65536"`
+	Argon2Threads        int    `env:"ARGON2_THREADS" default:"2"`
+	TraceExporter        string `env:"TRACE_EXPORTER" default:"none"`
+	HeartbeatTimeout     int    `env:"HEARTBEAT_TIMEOUT" default:"300"`
+	ReadyCheckAccrual    bool   `env:"READY_CHECK_ACCRUAL" default:"false"`
+	ShutdownDelay        int    `env:"SHUTDOWN_DELAY" default:"0"`
+	RequestTimeout       int    `env:"REQUEST_TIMEOUT" default:"15"`
+	ExportTimeout        int    `env:"EXPORT_TIMEOUT" default:"300"`
 }
 
 func NewConfig() *Config {
@@ -28,13 +62,41 @@ func NewConfig() *Config {
 	envDatabaseURI := os.Getenv("DATABASE_URI")
 	envAccrualSystemAddress := os.Getenv("ACCRUAL_SYSTEM_ADDRESS")
 	envLogLevel := os.Getenv("LOGGING_LEVEL")
+	envLogFormat := os.Getenv("LOG_FORMAT")
 	envKeyJWT := os.Getenv("KEY_JWT")
+	envJWTKeys := os.Getenv("JWT_KEYS")
 	envExpireJWT := os.Getenv("EXPIRE_JWT")
+	envExpireRefresh := os.Getenv("EXPIRE_REFRESH")
+	envStoreType := os.Getenv("STORE_TYPE")
+	envIdempotencyTTL := os.Getenv("IDEMPOTENCY_TTL")
+	envAccrualWorkers := os.Getenv("ACCRUAL_WORKERS")
+	envAccrualRateLimit := os.Getenv("ACCRUAL_RATE_LIMIT")
+	envAccrualCallbackKey := os.Getenv("ACCRUAL_CALLBACK_KEY")
+	envLoginMaxAttempts := os.Getenv("LOGIN_MAX_ATTEMPTS")
+	envLoginIPMaxAttempts := os.Getenv("LOGIN_IP_MAX_ATTEMPTS")
+	envLoginLockout := os.Getenv("LOGIN_LOCKOUT")
+	envLoginMaxLockout := os.Getenv("LOGIN_MAX_LOCKOUT")
+	envLoginWindow := os.Getenv("LOGIN_WINDOW")
+	envPasswordMinLength := os.Getenv("PASSWORD_MIN_LENGTH")
+	envPasswordMinClasses := os.Getenv("PASSWORD_MIN_CLASSES")
+	envPasswordHash := os.Getenv("PASSWORD_HASH")
+	envBcryptCost := os.Getenv("BCRYPT_COST")
+	envArgon2Time := os.Getenv("ARGON2_TIME")
+	envArgon2Memory := os.Getenv("ARGON2_MEMORY")
+	envArgon2Threads := os.Getenv("ARGON2_THREADS")
+	envTraceExporter := os.Getenv("TRACE_EXPORTER")
+	envHeartbeatTimeout := os.Getenv("HEARTBEAT_TIMEOUT")
+	envReadyCheckAccrual := os.Getenv("READY_CHECK_ACCRUAL")
+	envShutdownDelay := os.Getenv("SHUTDOWN_DELAY")
+	envRequestTimeout := os.Getenv("REQUEST_TIMEOUT")
+	envExportTimeout := os.Getenv("EXPORT_TIMEOUT")
 
 	flag.StringVar(&cfg.RunAddress, "a", "localhost:8080", "HTTP server address")
 	flag.StringVar(&cfg.DatabaseURI, "d", "", "Database URI for PostgreSQL")
 	flag.StringVar(&cfg.AccrualSystemAddress, "r", "127.0.0.1:8081", "Base URL for accrual")
 	flag.StringVar(&cfg.LogLevel, "l", "info", "Logging level")
+	flag.StringVar(&cfg.LogFormat, "f", "text", "Logging format: text or json")
+	flag.StringVar(&cfg.StoreType, "s", StorePostgres, "Store type: postgres or memory")
 	flag.Parse()
 
 	if envServerAddress != "" {
@@ -49,11 +111,120 @@ func NewConfig() *Config {
 	if envLogLevel != "" {
 		cfg.LogLevel = envLogLevel
 	}
+	if envLogFormat != "" {
+		cfg.LogFormat = envLogFormat
+	}
 	if envKeyJWT != "" {
 		cfg.KeyJWT = []byte(envKeyJWT)
 	}
+	if envJWTKeys != "" {
+		cfg.JWTKeys = envJWTKeys
+	}
 	if envExpireJWT != "" {
 		cfg.ExpireJWT, _ = strconv.Atoi(envExpireJWT)
 	}
+	if envExpireRefresh != "" {
+		cfg.ExpireRefresh, _ = strconv.Atoi(envExpireRefresh)
+	}
+	if envStoreType != "" {
+		cfg.StoreType = envStoreType
+	}
+	if envIdempotencyTTL != "" {
+		cfg.IdempotencyTTL, _ = strconv.Atoi(envIdempotencyTTL)
+	}
+	if envAccrualWorkers != "" {
+		cfg.AccrualWorkers, _ = strconv.Atoi(envAccrualWorkers)
+	}
+	if envAccrualRateLimit != "" {
+		cfg.AccrualRateLimit, _ = strconv.Atoi(envAccrualRateLimit)
+	}
+	if envAccrualCallbackKey != "" {
+		cfg.AccrualCallbackKey = []byte(envAccrualCallbackKey)
+	}
+	if envLoginMaxAttempts != "" {
+		cfg.LoginMaxAttempts, _ = strconv.Atoi(envLoginMaxAttempts)
+	}
+	if envLoginIPMaxAttempts != "" {
+		cfg.LoginIPMaxAttempts, _ = strconv.Atoi(envLoginIPMaxAttempts)
+	}
+	if envLoginLockout != "" {
+		cfg.LoginLockout, _ = strconv.Atoi(envLoginLockout)
+	}
+	if envLoginMaxLockout != "" {
+		cfg.LoginMaxLockout, _ = strconv.Atoi(envLoginMaxLockout)
+	}
+	if envLoginWindow != "" {
+		cfg.LoginWindow, _ = strconv.Atoi(envLoginWindow)
+	}
+	if envPasswordMinLength != "" {
+		cfg.PasswordMinLength, _ = strconv.Atoi(envPasswordMinLength)
+	}
+	if envPasswordMinClasses != "" {
+		cfg.PasswordMinClasses, _ = strconv.Atoi(envPasswordMinClasses)
+	}
+	if envPasswordHash != "" {
+		cfg.PasswordHash = envPasswordHash
+	}
+	if envBcryptCost != "" {
+		cfg.BcryptCost, _ = strconv.Atoi(envBcryptCost)
+	}
+	if envArgon2Time != "" {
+		cfg.Argon2Time, _ = strconv.Atoi(envArgon2Time)
+	}
+	if envArgon2Memory != "" {
+		cfg.Argon2Memory, _ = strconv.Atoi(envArgon2Memory)
+	}
+	if envArgon2Threads != "" {
+		cfg.Argon2Threads, _ = strconv.Atoi(envArgon2Threads)
+	}
+	if envTraceExporter != "" {
+		cfg.TraceExporter = envTraceExporter
+	}
+	if envHeartbeatTimeout != "" {
+		cfg.HeartbeatTimeout, _ = strconv.Atoi(envHeartbeatTimeout)
+	}
+	if envReadyCheckAccrual != "" {
+		cfg.ReadyCheckAccrual, _ = strconv.ParseBool(envReadyCheckAccrual)
+	}
+	if envShutdownDelay != "" {
+		cfg.ShutdownDelay, _ = strconv.Atoi(envShutdownDelay)
+	}
+	if envRequestTimeout != "" {
+		cfg.RequestTimeout, _ = strconv.Atoi(envRequestTimeout)
+	}
+	if envExportTimeout != "" {
+		cfg.ExportTimeout, _ = strconv.Atoi(envExportTimeout)
+	}
 	return cfg
 }
+
+// Validate rejects settings the server can't run with. Unparsable numbers are read as zero,
+// so a typo in a cost is reported here instead of failing every login.
+func (c *Config) Validate() error {
+	if c.Argon2Time < 0 || c.Argon2Time > math.MaxUint32 {
+		return fmt.Errorf("ARGON2_TIME is out of range: %d", c.Argon2Time)
+	}
+	if c.Argon2Memory < 0 || c.Argon2Memory > math.MaxUint32 {
+		return fmt.Errorf("ARGON2_MEMORY is out of range: %d", c.Argon2Memory)
+	}
+	if c.Argon2Threads < 0 || c.Argon2Threads > math.MaxUint8 {
+		return fmt.Errorf("ARGON2_THREADS is out of range: %d", c.Argon2Threads)
+	}
+	if err := c.PasswordHasher().Validate(); err != nil {
+		return fmt.Errorf("invalid password hash settings: %w", err)
+	}
+	return nil
+}
+
+// PasswordHasher hashes new passwords with the configured algorithm and costs, they are checked by Validate.
+func (c *Config) PasswordHasher() secure.PasswordHasher {
+	return secure.PasswordHasher{
+		Algorithm:  c.PasswordHash,
+		BcryptCost: c.BcryptCost,
+		Argon2: secure.Argon2Params{
+			Time:    uint32(c.Argon2Time),
+			Memory:  uint32(c.Argon2Memory),
+			Threads: uint8(c.Argon2Threads),
+		},
+	}
+}
@@ -64,3 +64,37 @@ func TestInitConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{PasswordHash: "argon2id", BcryptCost: 10, Argon2Time: 3, Argon2Memory: 65536, Argon2Threads: 2}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr bool
+	}{
+		{name: "defaults", modify: func(*Config) {}},
+		{name: "unknown algorithm", modify: func(cfg *Config) { cfg.PasswordHash = "md5" }, wantErr: true},
+		{name: "bcrypt cost typo", modify: func(cfg *Config) { cfg.BcryptCost = 0 }, wantErr: true},
+		{name: "argon2 threads typo", modify: func(cfg *Config) { cfg.Argon2Threads = 0 }, wantErr: true},
+		{name: "negative argon2 threads", modify: func(cfg *Config) { cfg.Argon2Threads = -1 }, wantErr: true},
+		{name: "argon2 threads overflow", modify: func(cfg *Config) { cfg.Argon2Threads = 256 }, wantErr: true},
+		{name: "negative argon2 time", modify: func(cfg *Config) { cfg.Argon2Time = -1 }, wantErr: true},
+		{name: "negative argon2 memory", modify: func(cfg *Config) { cfg.Argon2Memory = -65536 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+// Redacted replaces values of sensitive fields and headers.
+const Redacted = "[REDACTED]"
+
+type contextKey string
+
+const requestIDKey contextKey = "requestID"
+
+// sensitiveHeaders carry credentials, they are redacted in any header set written to the log.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Accrual-Signature"}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request ID of ctx, or an empty string outside of a request.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// RequestIDHook adds the request ID to entries logged with the context of a request.
+type RequestIDHook struct{}
+
+func (RequestIDHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (RequestIDHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if requestID := RequestID(entry.Context); requestID != "" {
+		entry.Data["request_id"] = requestID
+	}
+	return nil
+}
+
+// RedactHook hides passwords, tokens, secrets and credential headers, whatever code logged them.
+type RedactHook struct{}
+
+func (RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (RedactHook) Fire(entry *logrus.Entry) error {
+	for key, value := range entry.Data {
+		if isSensitiveField(key) {
+			entry.Data[key] = Redacted
+			continue
+		}
+		if header, ok := value.(http.Header); ok {
+			entry.Data[key] = RedactHeader(header)
+		}
+	}
+	return nil
+}
+
+// RedactHeader returns a copy of header with credentials replaced, header itself is left as is
+// since it may still be in use by a request or response.
+func RedactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range sensitiveHeaders {
+		if _, ok := redacted[name]; ok {
+			redacted[name] = []string{Redacted}
+		}
+	}
+	return redacted
+}
+
+func isSensitiveField(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "authorization", "cookie", "set-cookie", "signature":
+		return true
+	}
+	return strings.Contains(key, "password") || strings.Contains(key, "token") || strings.Contains(key, "secret")
+}
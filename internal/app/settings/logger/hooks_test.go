@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLogger() (*logrus.Logger, *test.Hook) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	log.AddHook(RequestIDHook{})
+	log.AddHook(RedactHook{})
+	return log, test.NewLocal(log)
+}
+
+func TestRequestIDHook(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected any
+	}{
+		{name: "request context", ctx: WithRequestID(context.Background(), "req-42"), expected: "req-42"},
+		{name: "context without request", ctx: context.Background()},
+		{name: "no context"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := newTestLogger()
+			entry := logrus.NewEntry(log)
+			if tt.ctx != nil {
+				entry = entry.WithContext(tt.ctx)
+			}
+			entry.Info("test")
+
+			require.NotNil(t, hook.LastEntry())
+			assert.Equal(t, tt.expected, hook.LastEntry().Data["request_id"])
+		})
+	}
+}
+
+func TestRedactHook(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret.jwt")
+	header.Set("Cookie", "token=secret.jwt")
+	header.Set("Content-Type", "application/json")
+
+	tests := []struct {
+		name     string
+		fields   logrus.Fields
+		expected logrus.Fields
+	}{
+		{
+			name:     "sensitive keys",
+			fields:   logrus.Fields{"password": "Go-pher-2025", "new_password": "x", "refresh_token": "y", "Authorization": "Bearer z", "client_secret": "s"},
+			expected: logrus.Fields{"password": Redacted, "new_password": Redacted, "refresh_token": Redacted, "Authorization": Redacted, "client_secret": Redacted},
+		},
+		{
+			name:     "regular keys",
+			fields:   logrus.Fields{"action": "H.Login", "user": "alice", "status": 401},
+			expected: logrus.Fields{"action": "H.Login", "user": "alice", "status": 401},
+		},
+		{
+			name:   "headers",
+			fields: logrus.Fields{"header": header},
+			expected: logrus.Fields{"header": http.Header{
+				"Authorization": {Redacted},
+				"Cookie":        {Redacted},
+				"Content-Type":  {"application/json"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := newTestLogger()
+			log.WithFields(tt.fields).Info("test")
+
+			require.NotNil(t, hook.LastEntry())
+			assert.Equal(t, tt.expected, hook.LastEntry().Data)
+		})
+	}
+
+	// The logged header is a copy, the one of the request keeps the credentials.
+	assert.Equal(t, "Bearer secret.jwt", header.Get("Authorization"))
+}
+
+func TestInitialize(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		format  string
+		wantErr bool
+	}{
+		{name: "json", level: "debug", format: FormatJSON},
+		{name: "unknown format", level: "info", format: "xml", wantErr: true},
+		{name: "unknown level", level: "loud", format: FormatText, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Initialize(tt.level, tt.format)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, &logrus.JSONFormatter{}, Log.Formatter)
+			assert.IsType(t, &logrus.JSONFormatter{}, logrus.StandardLogger().Formatter)
+			assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+		})
+	}
+}
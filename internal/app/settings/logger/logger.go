@@ -1,20 +1,42 @@
 package logger
 
 import (
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"os"
 )
 
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
 var Log *logrus.Logger = logrus.New()
 
-func Initialize(level string) error {
+// Initialize sets up Log and the standard logrus logger used by the handlers, so both write
+// the same format, attach the request ID and redact sensitive fields.
+func Initialize(level string, format string) error {
 	logLevel, err := logrus.ParseLevel(level)
 	if err != nil {
 		return err
 	}
 
-	Log.SetFormatter(&logrus.JSONFormatter{})
-	Log.SetOutput(os.Stdout)
-	Log.SetLevel(logLevel)
+	var formatter logrus.Formatter
+	switch format {
+	case FormatText, "":
+		formatter = &logrus.TextFormatter{FullTimestamp: true}
+	case FormatJSON:
+		formatter = &logrus.JSONFormatter{}
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	for _, l := range []*logrus.Logger{Log, logrus.StandardLogger()} {
+		l.SetFormatter(formatter)
+		l.SetOutput(os.Stdout)
+		l.SetLevel(logLevel)
+		l.AddHook(RequestIDHook{})
+		l.AddHook(RedactHook{})
+	}
 	return nil
 }
@@ -2,34 +2,73 @@ package router
 
 import (
 	"TimBerk/gophermart/internal/app/handlers"
+	"TimBerk/gophermart/internal/app/health"
+	"TimBerk/gophermart/internal/app/keys"
+	"TimBerk/gophermart/internal/app/metrics"
 	"TimBerk/gophermart/internal/app/middlewares/auth"
+	"TimBerk/gophermart/internal/app/middlewares/idempotency"
+	"TimBerk/gophermart/internal/app/middlewares/requestlog"
+	"TimBerk/gophermart/internal/app/middlewares/signature"
+	"TimBerk/gophermart/internal/app/middlewares/timeout"
 	"TimBerk/gophermart/internal/app/settings/config"
-	"context"
+	"TimBerk/gophermart/internal/app/tracing"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"time"
 )
 
-func InitRouter(dataStore handlers.Store, cfg *config.Config, ctx context.Context) chi.Router {
-	handler := handlers.NewHandler(dataStore, cfg, ctx)
+func InitRouter(dataStore handlers.Store, cfg *config.Config, keySet *keys.KeySet, probes *health.Checker) chi.Router {
+	handler := handlers.NewHandler(dataStore, cfg, keySet)
+	requestTimeout := timeout.Timeout(time.Duration(cfg.RequestTimeout) * time.Second)
+	exportTimeout := timeout.Timeout(time.Duration(cfg.ExportTimeout) * time.Second)
 
 	router := chi.NewRouter()
-	router.Use(middleware.Logger)
+	// The span is started first, so the request entry is logged with its trace ID.
+	router.Use(tracing.Middleware)
+	router.Use(requestlog.RequestLog)
+	router.Use(metrics.Middleware)
+
+	router.Method(http.MethodGet, "/metrics", metrics.Handler())
+	router.Get("/healthz", probes.Live)
+	router.Get("/readyz", probes.Ready)
 
 	router.Group(func(r chi.Router) {
+		r.Use(requestTimeout)
 		r.Post("/api/user/register", handler.Register)
 		r.Post("/api/user/login", handler.Login)
+		r.Post("/api/user/token/refresh", handler.RefreshToken)
+		r.Get("/.well-known/jwks.json", handler.GetJWKS)
 	})
 
 	router.Group(func(r chi.Router) {
-		r.Use(auth.Authentication(cfg))
+		r.Use(requestTimeout)
+		r.Use(auth.Authentication(keySet, dataStore))
+		r.Post("/api/user/logout", handler.Logout)
+		r.Put("/api/user/password", handler.ChangePassword)
+		r.Delete("/api/user", handler.DeleteAccount)
 		r.Get("/api/user/orders/{number}", handler.GetOrder)
-		r.Post("/api/user/orders", handler.CreateOrder)
+		r.With(idempotency.Idempotency(cfg, dataStore)).Post("/api/user/orders", handler.CreateOrder)
+		r.With(idempotency.Idempotency(cfg, dataStore)).Post("/api/user/orders/batch", handler.CreateOrders)
 		r.Get("/api/user/orders", handler.GetOrders)
 
 		r.Get("/api/user/balance", handler.GetBalance)
-		r.Post("/api/user/balance/withdraw", handler.WithdrawBalance)
+		r.Get("/api/user/balance/history", handler.GetBalanceHistory)
+		r.With(idempotency.Idempotency(cfg, dataStore)).Post("/api/user/balance/withdraw", handler.WithdrawBalance)
 		r.Get("/api/user/withdrawals", handler.GetWithdraw)
 	})
 
+	// Exports stream the whole history, so they get a longer timeout than other requests.
+	router.Group(func(r chi.Router) {
+		r.Use(exportTimeout)
+		r.Use(auth.Authentication(keySet, dataStore))
+		r.Get("/api/user/orders/export", handler.ExportOrders)
+		r.Get("/api/user/withdrawals/export", handler.ExportWithdrawals)
+	})
+
+	// Push intake is enabled only with a shared secret, polling keeps working in any case.
+	if len(cfg.AccrualCallbackKey) > 0 {
+		router.With(requestTimeout, signature.Verify(cfg.AccrualCallbackKey)).Post("/api/internal/accrual/callback", handler.AccrualCallback)
+	}
+
 	return router
 }
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueViolationCode is the SQLSTATE of a unique constraint violation.
+const uniqueViolationCode = "23505"
+
+var ErrNotFound = errors.New("not found")
+
+var (
+	ErrDuplicateUser       = errors.New("username is already taken")
+	ErrDuplicateOrder      = errors.New("order was already uploaded by the user")
+	ErrOrderOwnedByOther   = errors.New("order was uploaded by another user")
+	ErrDuplicateWithdrawal = errors.New("withdrawal for the order already exists")
+)
+
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+var (
+	ErrUserHasBalance       = errors.New("user has positive balance")
+	ErrUserHasPendingOrders = errors.New("user has orders in processing")
+)
+
+var ErrInvalidStatusTransition = errors.New("invalid order status transition")
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+// StatusTransitionError is returned when an order can't move from its current status to the requested one.
+// It matches ErrInvalidStatusTransition with errors.Is.
+type StatusTransitionError struct {
+	Order string
+	From  Status
+	To    Status
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("order %s: status %s -> %s is not allowed", e.Order, e.From, e.To)
+}
+
+func (e *StatusTransitionError) Unwrap() error {
+	return ErrInvalidStatusTransition
+}
+
+// uniqueErrors maps unique constraints to the domain errors of their violation.
+var uniqueErrors = map[string]error{
+	"users_username_key":           ErrDuplicateUser,
+	"orders_order_number_key":      ErrDuplicateOrder,
+	"withdrawals_order_number_key": ErrDuplicateWithdrawal,
+}
+
+// dbError translates driver errors into domain errors, so callers don't depend on pgx.
+// The driver error stays in the chain for logs, unknown errors are returned as is.
+func dbError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		if domainErr, ok := uniqueErrors[pgErr.ConstraintName]; ok {
+			return fmt.Errorf("%w: %w", domainErr, err)
+		}
+	}
+	return err
+}
+
+// ErrMigrationsPending is returned by the readiness check when the schema is older than the binary expects.
+var ErrMigrationsPending = errors.New("database migrations are pending")
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBError(t *testing.T) {
+	other := errors.New("connection reset")
+	foreignKey := &pgconn.PgError{Code: "23503", ConstraintName: "orders_user_id_fkey"}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{name: "no error", err: nil, expected: nil},
+		{name: "no rows", err: fmt.Errorf("scan: %w", pgx.ErrNoRows), expected: ErrNotFound},
+		{name: "duplicate user", err: &pgconn.PgError{Code: uniqueViolationCode, ConstraintName: "users_username_key"}, expected: ErrDuplicateUser},
+		{name: "duplicate order", err: &pgconn.PgError{Code: uniqueViolationCode, ConstraintName: "orders_order_number_key"}, expected: ErrDuplicateOrder},
+		{name: "duplicate withdrawal", err: &pgconn.PgError{Code: uniqueViolationCode, ConstraintName: "withdrawals_order_number_key"}, expected: ErrDuplicateWithdrawal},
+		{name: "unknown constraint", err: &pgconn.PgError{Code: uniqueViolationCode, ConstraintName: "refresh_tokens_token_hash_key"}},
+		{name: "other driver error", err: foreignKey},
+		{name: "unknown error", err: other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dbError(tt.err)
+			assert.ErrorIs(t, err, tt.err, "driver error stays in the chain")
+			if tt.expected != nil {
+				assert.ErrorIs(t, err, tt.expected)
+				return
+			}
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
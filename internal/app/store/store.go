@@ -2,27 +2,61 @@ package store
 
 import (
 	"TimBerk/gophermart/internal/app/settings/config"
+	"TimBerk/gophermart/internal/app/tracing"
 	"context"
+	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
 	"github.com/sirupsen/logrus"
 )
 
+const migrationDir = "migrations"
+
+// pgxPool is the part of pgxpool.Pool used by queries, tests run them against a mock.
+type pgxPool interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Ping(ctx context.Context) error
+	Stat() *pgxpool.Stat
+	Close()
+}
+
 type PostgresStore struct {
-	db  *pgxpool.Pool
+	db  pgxPool
 	cfg *config.Config
+
+	// migrationVersion is the latest migration shipped with the binary.
+	migrationVersion int64
 }
 
 func InitPgPool(ctx context.Context, connString string) (*PostgresStore, error) {
-	db, err := pgxpool.New(ctx, connString)
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
+
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, err
 	}
 	return &PostgresStore{db: db}, nil
 }
 
+// pool returns the pgxpool.Pool behind the store, migrations need it for a database/sql handle.
+func (s *PostgresStore) pool() (*pgxpool.Pool, error) {
+	pool, ok := s.db.(*pgxpool.Pool)
+	if !ok {
+		return nil, fmt.Errorf("store is not backed by a connection pool")
+	}
+	return pool, nil
+}
+
 func NewPostgresStore(cfg *config.Config) (*PostgresStore, error) {
 	ctx := context.Background()
 
@@ -33,34 +67,54 @@ func NewPostgresStore(cfg *config.Config) (*PostgresStore, error) {
 
 	pgStore.cfg = cfg
 
-	pgStore.initDB()
+	// The store is useless on a schema it doesn't know, so failed migrations stop the start.
+	if err = pgStore.initDB(); err != nil {
+		pgStore.db.Close()
+		return nil, err
+	}
 
 	return pgStore, nil
 }
 
-func (s *PostgresStore) initDB() {
-	migrationDir := "migrations"
+func (s *PostgresStore) initDB() error {
+	pool, err := s.pool()
+	if err != nil {
+		return err
+	}
 
-	conn, err := s.db.Acquire(context.Background())
+	conn, err := pool.Acquire(context.Background())
 	if err != nil {
 		logrus.WithField("error", err).Error("failed to acquire connection")
-		return
+		return err
 	}
 	defer conn.Release()
 
 	if err = goose.SetDialect("postgres"); err != nil {
 		logrus.WithField("error", err).Error("failed to set dialect")
-		return
+		return err
+	}
+
+	migrations, err := goose.CollectMigrations(migrationDir, 0, goose.MaxVersion)
+	if err != nil {
+		logrus.WithField("error", err).Error("failed to read migrations")
+		return err
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		logrus.WithField("error", err).Error("failed to read migrations")
+		return err
 	}
+	s.migrationVersion = last.Version
 
-	db := stdlib.OpenDBFromPool(s.db)
+	db := stdlib.OpenDBFromPool(pool)
 
 	if err = goose.Up(db, migrationDir); err != nil {
 		logrus.WithField("error", err).Error("failed to run migrations")
-		return
+		return err
 	}
 
 	logrus.Info("Migrations applied successfully!")
+	return nil
 }
 
 func (s *PostgresStore) BeginTx(ctx context.Context) (pgx.Tx, error) {
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ChangePassword stores the new hash and revokes all sessions of the user: refresh tokens are revoked
+// and the session generation is bumped, so IsAccessTokenRevoked rejects access tokens of the previous one.
+// The new generation is returned for the tokens issued to the caller.
+func (s *PostgresStore) ChangePassword(ctx context.Context, userID int64, passwordHash string) (int64, error) {
+	tx, err := s.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transcation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var generation int64
+	query := `UPDATE users SET password_hash = $2, session_generation = session_generation + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING session_generation`
+	if err = tx.QueryRow(ctx, query, userID, passwordHash).Scan(&generation); err != nil {
+		return 0, fmt.Errorf("update password error: %w", dbError(err))
+	}
+
+	query = `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err = tx.Exec(ctx, query, userID); err != nil {
+		return 0, fmt.Errorf("revoke refresh tokens error: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.ChangePassword", "user": userID, "error": err}).Error("failed to commit transaction")
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return generation, nil
+}
+
+// DeleteUser anonymizes the user: the login and password are erased, sessions, idempotency keys and
+// login counters are removed. The login is set to NULL, which the unique index doesn't compare,
+// so it is freed at once and can't collide with any registered name. Orders, withdrawals and ledger entries are kept for accounting.
+// Without force a positive balance gives ErrUserHasBalance and orders in processing give
+// ErrUserHasPendingOrders, with force such orders are marked INVALID.
+func (s *PostgresStore) DeleteUser(ctx context.Context, userID int64, force bool) error {
+	tx, err := s.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transcation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var username string
+	query := `SELECT username FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	if err = tx.QueryRow(ctx, query, userID).Scan(&username); err != nil {
+		return dbError(err)
+	}
+
+	if !force {
+		var hasBalance, hasPending bool
+		query = `SELECT EXISTS (SELECT 1 FROM balance WHERE user_id = $1 AND current > 0),
+				EXISTS (SELECT 1 FROM orders WHERE user_id = $1 AND status IN ('NEW', 'PROCESSING'))`
+		if err = tx.QueryRow(ctx, query, userID).Scan(&hasBalance, &hasPending); err != nil {
+			return fmt.Errorf("check user data error: %w", err)
+		}
+		switch {
+		case hasBalance:
+			return ErrUserHasBalance
+		case hasPending:
+			return ErrUserHasPendingOrders
+		}
+	}
+
+	query = `UPDATE orders SET status = 'INVALID', locked_by = NULL, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND status IN ('NEW', 'PROCESSING')`
+	if _, err = tx.Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("cancel orders error: %w", err)
+	}
+
+	query = `UPDATE users SET username = NULL, password_hash = '', deleted_at = CURRENT_TIMESTAMP,
+			session_generation = session_generation + 1
+		WHERE id = $1`
+	if _, err = tx.Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("anonymize user error: %w", dbError(err))
+	}
+
+	for _, cleanup := range []struct {
+		query string
+		args  []any
+	}{
+		{`DELETE FROM refresh_tokens WHERE user_id = $1`, []any{userID}},
+		{`DELETE FROM idempotency_keys WHERE user_id = $1`, []any{userID}},
+		{`DELETE FROM login_throttles WHERE scope = $1 AND subject = $2`, []any{LoginScopeUser, username}},
+	} {
+		if _, err = tx.Exec(ctx, cleanup.query, cleanup.args...); err != nil {
+			return fmt.Errorf("remove user data error: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.DeleteUser", "user": userID, "error": err}).Error("failed to commit transaction")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -11,6 +11,8 @@ type UserRecord struct {
 	ID           int64
 	Username     string
 	PasswordHash string
+	// SessionGeneration goes into access tokens, it grows when all sessions of the user are revoked.
+	SessionGeneration int64
 }
 
 func (s *PostgresStore) AddUser(ctx context.Context, username string, password string) (int64, error) {
@@ -19,8 +21,8 @@ func (s *PostgresStore) AddUser(ctx context.Context, username string, password s
 	query := `INSERT INTO users (username, password_hash) VALUES ($1, $2) RETURNING id`
 	err := s.db.QueryRow(ctx, query, username, password).Scan(&userID)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{"action": "DB.AddUser", "user": username, "error": err}).Error("failed to create user")
-		return 0, err
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.AddUser", "user": username, "error": err}).Error("failed to create user")
+		return 0, dbError(err)
 	}
 	return userID, err
 }
@@ -30,7 +32,7 @@ func (s *PostgresStore) CheckUser(ctx context.Context, username string) (int64,
 	query := `SELECT id FROM users WHERE username = $1`
 	err := s.db.QueryRow(ctx, query, username).Scan(&exists)
 	if errors.Is(err, pgx.ErrNoRows) {
-		logrus.WithFields(logrus.Fields{"action": "DB.CheckUser", "user": username, "error": err}).Info("failed to find user")
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.CheckUser", "user": username, "error": err}).Info("failed to find user")
 		return 0, nil
 	}
 	return exists, err
@@ -38,7 +40,16 @@ func (s *PostgresStore) CheckUser(ctx context.Context, username string) (int64,
 
 func (s *PostgresStore) GetUser(ctx context.Context, username string) (UserRecord, error) {
 	var userRecord UserRecord
-	query := `SELECT id, username, password_hash FROM users WHERE username = $1`
-	err := s.db.QueryRow(ctx, query, username).Scan(&userRecord.ID, &userRecord.Username, &userRecord.PasswordHash)
-	return userRecord, err
+	query := `SELECT id, username, password_hash, session_generation FROM users WHERE username = $1 AND deleted_at IS NULL`
+	err := s.db.QueryRow(ctx, query, username).Scan(&userRecord.ID, &userRecord.Username, &userRecord.PasswordHash, &userRecord.SessionGeneration)
+	return userRecord, dbError(err)
+}
+
+// UpdatePasswordHash replaces the stored hash, it is used to upgrade hashes on login.
+func (s *PostgresStore) UpdatePasswordHash(ctx context.Context, userID int64, passwordHash string) error {
+	_, err := s.db.Exec(ctx, `UPDATE users SET password_hash = $2 WHERE id = $1`, userID, passwordHash)
+	if err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.UpdatePasswordHash", "user": userID, "error": err}).Error("failed to update password")
+	}
+	return err
 }
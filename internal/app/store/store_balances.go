@@ -2,6 +2,7 @@ package store
 
 import (
 	"TimBerk/gophermart/internal/app/models/balance"
+	"TimBerk/gophermart/pkg/money"
 	"context"
 	"github.com/jackc/pgx/v5"
 	"github.com/sirupsen/logrus"
@@ -13,26 +14,24 @@ func (s *PostgresStore) GetBalance(ctx context.Context, userID int64) (balance.B
 	query := `SELECT current, withdrawn FROM balance WHERE user_id = $1`
 	err := s.db.QueryRow(ctx, query, userID).Scan(&record.Current, &record.Withdrawn)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{"action": "DB.GetBalance", "user": userID, "error": err}).Error("failed to find")
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetBalance", "user": userID, "error": err}).Error("failed to find")
 	}
-	return record, err
+	return record, dbError(err)
 }
 
-func (s *PostgresStore) WithdrawBalance(ctx context.Context, tx pgx.Tx, userID int64, sum float64) error {
+// WithdrawBalance moves sum from current to withdrawn without a reference order.
+func (s *PostgresStore) WithdrawBalance(ctx context.Context, tx pgx.Tx, userID int64, sum money.Amount) error {
 	_, err := tx.Exec(ctx, `SELECT 1 FROM balance WHERE user_id = $1 FOR UPDATE`, userID)
 	if err != nil {
 		return err
 	}
 
-	query := `UPDATE balance SET current = current - $2, withdrawn = withdrawn + $2 WHERE user_id = $1`
-	_, err = tx.Exec(ctx, query, userID, sum)
-	return err
+	return s.AddLedgerEntry(ctx, tx, WithdrawalEntry(userID, "", sum))
 }
 
-func (s *PostgresStore) AddBalance(ctx context.Context, tx pgx.Tx, userID int64, sum float64) error {
-	query := `UPDATE balance SET current = current + $2 WHERE user_id = $1`
-	_, err := tx.Exec(ctx, query, userID, sum)
-	return err
+// AddBalance books a manual adjustment of the current balance.
+func (s *PostgresStore) AddBalance(ctx context.Context, tx pgx.Tx, userID int64, sum money.Amount) error {
+	return s.AddLedgerEntry(ctx, tx, AdjustmentEntry(userID, sum, "manual adjustment"))
 }
 
 func (s *PostgresStore) GetOrderWithdrawals(ctx context.Context, userID int64) (balance.WithdrawnList, error) {
@@ -47,16 +46,67 @@ func (s *PostgresStore) GetOrderWithdrawals(ctx context.Context, userID int64) (
 	for rows.Next() {
 		var record balance.WithdrawnResponse
 		if errRow := rows.Scan(&record.Number, &record.Sum, &record.CreatedAt); errRow != nil {
-			logrus.WithFields(logrus.Fields{"action": "DB.GetOrderWithdrawals", "user": userID, "error": errRow}).Error("failed to find order")
+			logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetOrderWithdrawals", "user": userID, "error": errRow}).Error("failed to find order")
 			return nil, errRow
 		}
 		records = append(records, record)
 	}
 
 	if errRow := rows.Err(); errRow != nil {
-		logrus.WithFields(logrus.Fields{"action": "DB.GetOrderWithdrawals", "user": userID, "error": errRow}).Error("failed to find orders")
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetOrderWithdrawals", "user": userID, "error": errRow}).Error("failed to find orders")
 		return nil, errRow
 	}
 
 	return records, err
 }
+
+// GetWithdrawalPage returns up to filter.Limit withdrawals of the user, newest first, and the cursor
+// of the next page, which is nil on the last page.
+func (s *PostgresStore) GetWithdrawalPage(ctx context.Context, userID int64, filter PageFilter) (balance.WithdrawnList, *Cursor, error) {
+	query, args := filter.conditions(`SELECT id, order_number, sum, created_at FROM withdrawals WHERE user_id = $1`, []any{userID})
+	query, args = filter.limit(query, args)
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var records balance.WithdrawnList
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var record balance.WithdrawnResponse
+		if errRow := rows.Scan(&id, &record.Number, &record.Sum, &record.CreatedAt); errRow != nil {
+			logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetWithdrawalPage", "user": userID, "error": errRow}).Error("failed to find withdrawal")
+			return nil, nil, errRow
+		}
+		records = append(records, record)
+		ids = append(ids, id)
+	}
+	if errRow := rows.Err(); errRow != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetWithdrawalPage", "user": userID, "error": errRow}).Error("failed to find withdrawals")
+		return nil, nil, errRow
+	}
+
+	if len(records) <= filter.Limit {
+		return records, nil, nil
+	}
+	records = records[:filter.Limit]
+	last := records[len(records)-1]
+	return records, &Cursor{CreatedAt: last.CreatedAt, ID: ids[len(records)-1]}, nil
+}
+
+// GetWithdrawalSummary counts and sums withdrawals of the user within the time range of the filter,
+// the cursor and the limit are not applied.
+func (s *PostgresStore) GetWithdrawalSummary(ctx context.Context, userID int64, filter PageFilter) (balance.WithdrawnSummary, error) {
+	var record balance.WithdrawnSummary
+
+	query, args := PageFilter{From: filter.From, To: filter.To}.conditions(
+		`SELECT COUNT(*), COALESCE(SUM(sum), 0) FROM withdrawals WHERE user_id = $1`, []any{userID})
+	err := s.db.QueryRow(ctx, query, args...).Scan(&record.Count, &record.Sum)
+	if err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetWithdrawalSummary", "user": userID, "error": err}).Error("failed to sum withdrawals")
+	}
+	return record, err
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UploadResult is the outcome of a single order of a batch upload.
+type UploadResult string
+
+const (
+	UploadAccepted UploadResult = "accepted"
+	UploadExisting UploadResult = "already_uploaded"
+	UploadConflict UploadResult = "conflict"
+	// UploadInvalid is set by callers for numbers which don't pass validation and never reach the store.
+	UploadInvalid UploadResult = "invalid"
+)
+
+// Err returns the domain error of an order which wasn't accepted, nil otherwise.
+func (r UploadResult) Err() error {
+	switch r {
+	case UploadExisting:
+		return ErrDuplicateOrder
+	case UploadConflict:
+		return ErrOrderOwnedByOther
+	}
+	return nil
+}
+
+// AddOrders uploads the orders of the user in one transaction with a multi-row insert and returns
+// the result of every number. Numbers owned by anybody already are left as they are.
+func (s *PostgresStore) AddOrders(ctx context.Context, userID int64, orders []string) (map[string]UploadResult, error) {
+	tx, err := s.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transcation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Rows are inserted in the order of numbers, so concurrent batches wait for each other without deadlocks.
+	query := `INSERT INTO orders (user_id, order_number)
+		SELECT $1, number::BIGINT FROM unnest($2::TEXT[]) AS number ORDER BY 2
+		ON CONFLICT (order_number) DO NOTHING
+		RETURNING id`
+	rows, err := tx.Query(ctx, query, userID, orders)
+	if err != nil {
+		return nil, fmt.Errorf("create orders error: %w", err)
+	}
+	inserted := make(map[int64]struct{}, len(orders))
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("create orders error: %w", err)
+		}
+		inserted[id] = struct{}{}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("create orders error: %w", err)
+	}
+
+	// The statement sees orders committed by concurrent uploads while the insert waited for them.
+	query = `SELECT number, o.id, o.user_id FROM unnest($1::TEXT[]) AS number
+		JOIN orders o ON o.order_number = number::BIGINT`
+	rows, err = tx.Query(ctx, query, orders)
+	if err != nil {
+		return nil, fmt.Errorf("find orders error: %w", err)
+	}
+	defer rows.Close()
+
+	results := make(map[string]UploadResult, len(orders))
+	for rows.Next() {
+		var number string
+		var id, ownerID int64
+		if err = rows.Scan(&number, &id, &ownerID); err != nil {
+			return nil, fmt.Errorf("find orders error: %w", err)
+		}
+		results[number] = uploadResult(userID, ownerID, id, inserted)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("find orders error: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.AddOrders", "user": userID, "error": err}).Error("failed to commit transaction")
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return results, nil
+}
+
+func uploadResult(userID int64, ownerID int64, id int64, inserted map[int64]struct{}) UploadResult {
+	if _, ok := inserted[id]; ok {
+		return UploadAccepted
+	}
+	if ownerID == userID {
+		return UploadExisting
+	}
+	return UploadConflict
+}
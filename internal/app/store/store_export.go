@@ -0,0 +1,59 @@
+package store
+
+import (
+	"TimBerk/gophermart/internal/app/models/balance"
+	model "TimBerk/gophermart/internal/app/models/order"
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ExportOrders streams orders of the user uploaded from inclusive to exclusive, oldest first. Zero bounds are not applied.
+// Rows are passed to fn while they are read, an error of fn stops the export and is returned as is.
+func (s *PostgresStore) ExportOrders(ctx context.Context, userID int64, from time.Time, to time.Time, fn func(model.OrderResponse) error) error {
+	period := PageFilter{From: from, To: to}
+	query, args := period.conditions(`SELECT order_number, status, accrual, created_at FROM orders WHERE user_id = $1`, []any{userID})
+
+	rows, err := s.db.Query(ctx, query+" ORDER BY created_at, id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record model.OrderResponse
+		if errRows := rows.Scan(&record.Number, &record.Status, &record.Accrual, &record.CreatedAt); errRows != nil {
+			logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.ExportOrders", "user": userID, "error": errRows}).Error("failed to find order")
+			return errRows
+		}
+		if err = fn(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ExportWithdrawals streams withdrawals of the user within the time range, oldest first.
+func (s *PostgresStore) ExportWithdrawals(ctx context.Context, userID int64, from time.Time, to time.Time, fn func(balance.WithdrawnResponse) error) error {
+	period := PageFilter{From: from, To: to}
+	query, args := period.conditions(`SELECT order_number, sum, created_at FROM withdrawals WHERE user_id = $1`, []any{userID})
+
+	rows, err := s.db.Query(ctx, query+" ORDER BY created_at, id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record balance.WithdrawnResponse
+		if errRows := rows.Scan(&record.Number, &record.Sum, &record.CreatedAt); errRows != nil {
+			logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.ExportWithdrawals", "user": userID, "error": errRows}).Error("failed to find withdrawal")
+			return errRows
+		}
+		if err = fn(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"TimBerk/gophermart/internal/app/models/balance"
+	model "TimBerk/gophermart/internal/app/models/order"
+	"TimBerk/gophermart/pkg/money"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreExport(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	otherID, _ := s.AddUser(ctx, "other", "hash")
+
+	numbers := []string{"12345678903", "49927398716", "79927398713"}
+	for _, number := range numbers {
+		require.NoError(t, s.AddOrder(ctx, userID, number))
+	}
+	require.NoError(t, s.AddOrder(ctx, otherID, "2377225624"))
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, numbers[0], Processed, money.FromUnits(30)))
+	require.NoError(t, s.AddWithdrawal(ctx, userID, "4532015112830366", money.FromUnits(10)))
+	require.NoError(t, s.AddWithdrawal(ctx, userID, "1234567812345670", money.FromUnits(20)))
+
+	var orders []string
+	err := s.ExportOrders(ctx, userID, time.Time{}, time.Time{}, func(record model.OrderResponse) error {
+		orders = append(orders, record.Number)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, numbers, orders, "oldest first")
+
+	var withdrawals []string
+	err = s.ExportWithdrawals(ctx, userID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), func(record balance.WithdrawnResponse) error {
+		withdrawals = append(withdrawals, record.Number)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4532015112830366", "1234567812345670"}, withdrawals)
+
+	stop := errors.New("client gone")
+	calls := 0
+	err = s.ExportOrders(ctx, userID, time.Time{}, time.Time{}, func(model.OrderResponse) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+
+	err = s.ExportWithdrawals(ctx, userID, time.Now().Add(time.Hour), time.Time{}, func(balance.WithdrawnResponse) error {
+		t.Fatal("no withdrawals in the future")
+		return nil
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// Ping checks that a connection can be taken from the pool and the database answers.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.Ping(ctx)
+}
+
+// CheckMigrations compares the schema version with the latest migration of the binary.
+// A newer schema is accepted, it is left by a newer replica during a rolling update.
+func (s *PostgresStore) CheckMigrations(ctx context.Context) error {
+	query := `SELECT version_id FROM goose_db_version WHERE is_applied ORDER BY id DESC LIMIT 1`
+
+	var version int64
+	if err := s.db.QueryRow(ctx, query).Scan(&version); err != nil {
+		return dbError(err)
+	}
+	if version < s.migrationVersion {
+		return fmt.Errorf("%w: version %d, expected %d", ErrMigrationsPending, version, s.migrationVersion)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// CheckMigrations always passes, the memory store has no schema.
+func (s *MemoryStore) CheckMigrations(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// IdempotencyRecord is a stored request result, StatusCode is zero while the request is in progress.
+type IdempotencyRecord struct {
+	UserID      int64
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+func (r IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// ReserveIdempotencyKey creates an in-progress record for the key and reports true,
+// or returns the existing record and false. Records older than ttl are replaced.
+func (s *PostgresStore) ReserveIdempotencyKey(ctx context.Context, userID int64, key string, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	record := IdempotencyRecord{UserID: userID, Key: key, Fingerprint: fingerprint}
+
+	query := `INSERT INTO idempotency_keys (user_id, key, fingerprint) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status_code = NULL,
+			content_type = '',
+			response_body = NULL,
+			created_at = CURRENT_TIMESTAMP
+		WHERE idempotency_keys.created_at < CURRENT_TIMESTAMP - make_interval(secs => $4)
+		RETURNING created_at`
+	err := s.db.QueryRow(ctx, query, userID, key, fingerprint, ttl.Seconds()).Scan(&record.CreatedAt)
+	if err == nil {
+		return record, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.ReserveIdempotencyKey", "user": userID, "error": err}).Error("failed to reserve key")
+		return record, false, err
+	}
+
+	var statusCode *int
+	query = `SELECT fingerprint, status_code, content_type, response_body, created_at
+		FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+	err = s.db.QueryRow(ctx, query, userID, key).Scan(&record.Fingerprint, &statusCode, &record.ContentType, &record.Body, &record.CreatedAt)
+	if err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.ReserveIdempotencyKey", "user": userID, "error": err}).Error("failed to find key")
+		return record, false, err
+	}
+	if statusCode != nil {
+		record.StatusCode = *statusCode
+	}
+	return record, false, nil
+}
+
+func (s *PostgresStore) CompleteIdempotencyKey(ctx context.Context, userID int64, key string, statusCode int, contentType string, body []byte) error {
+	query := `UPDATE idempotency_keys SET status_code = $3, content_type = $4, response_body = $5 WHERE user_id = $1 AND key = $2`
+	_, err := s.db.Exec(ctx, query, userID, key, statusCode, contentType, body)
+	return err
+}
+
+func (s *PostgresStore) DeleteIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+	_, err := s.db.Exec(ctx, query, userID, key)
+	return err
+}
+
+// PurgeIdempotencyKeys deletes records older than ttl, they can't be replayed any more
+// and keys that are never reused would otherwise keep their response bodies forever.
+func (s *PostgresStore) PurgeIdempotencyKeys(ctx context.Context, ttl time.Duration) (int64, error) {
+	query := `DELETE FROM idempotency_keys WHERE created_at < CURRENT_TIMESTAMP - make_interval(secs => $1)`
+	result, err := s.db.Exec(ctx, query, ttl.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
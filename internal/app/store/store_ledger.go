@@ -0,0 +1,153 @@
+package store
+
+import (
+	"TimBerk/gophermart/pkg/money"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+)
+
+type LedgerKind string
+
+type LedgerAccount string
+
+const (
+	LedgerAccrual    LedgerKind = "ACCRUAL"
+	LedgerWithdrawal LedgerKind = "WITHDRAWAL"
+	LedgerAdjustment LedgerKind = "ADJUSTMENT"
+)
+
+// Accounts are seen from the user's side: crediting an account increases it, debiting decreases it.
+// AccountAccrual and AccountAdjustment are external sources, only current and withdrawn are
+// materialized in the balance table.
+const (
+	AccountAccrual    LedgerAccount = "accrual"
+	AccountAdjustment LedgerAccount = "adjustment"
+	AccountCurrent    LedgerAccount = "current"
+	AccountWithdrawn  LedgerAccount = "withdrawn"
+)
+
+var ErrInvalidLedgerEntry = errors.New("invalid ledger entry")
+
+type LedgerEntry struct {
+	ID        int64
+	UserID    int64
+	Kind      LedgerKind
+	Debit     LedgerAccount
+	Credit    LedgerAccount
+	Amount    money.Amount
+	Order     string
+	Comment   string
+	CreatedAt time.Time
+}
+
+func AccrualEntry(userID int64, order string, amount money.Amount) LedgerEntry {
+	return LedgerEntry{UserID: userID, Kind: LedgerAccrual, Debit: AccountAccrual, Credit: AccountCurrent, Amount: amount, Order: order}
+}
+
+func WithdrawalEntry(userID int64, order string, amount money.Amount) LedgerEntry {
+	return LedgerEntry{UserID: userID, Kind: LedgerWithdrawal, Debit: AccountCurrent, Credit: AccountWithdrawn, Amount: amount, Order: order}
+}
+
+// AdjustmentEntry credits the current balance for a positive amount and debits it for a negative one.
+func AdjustmentEntry(userID int64, amount money.Amount, comment string) LedgerEntry {
+	entry := LedgerEntry{UserID: userID, Kind: LedgerAdjustment, Debit: AccountAdjustment, Credit: AccountCurrent, Amount: amount, Comment: comment}
+	if amount.IsNegative() {
+		entry.Debit, entry.Credit = AccountCurrent, AccountAdjustment
+		entry.Amount = -amount
+	}
+	return entry
+}
+
+func (e LedgerEntry) Validate() error {
+	if !e.Amount.IsPositive() {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidLedgerEntry)
+	}
+	if e.Debit == e.Credit {
+		return fmt.Errorf("%w: debit and credit accounts must differ", ErrInvalidLedgerEntry)
+	}
+	return nil
+}
+
+// Delta returns the change of an account caused by the entry.
+func (e LedgerEntry) Delta(account LedgerAccount) money.Amount {
+	switch account {
+	case e.Credit:
+		return e.Amount
+	case e.Debit:
+		return -e.Amount
+	}
+	return 0
+}
+
+func nullableOrder(order string) *string {
+	if order == "" {
+		return nil
+	}
+	return &order
+}
+
+// AddLedgerEntry appends the entry to the ledger, balance is updated by the after_ledger_entry_insert trigger.
+// Zero amounts are not recorded.
+func (s *PostgresStore) AddLedgerEntry(ctx context.Context, tx pgx.Tx, entry LedgerEntry) error {
+	if entry.Amount.IsZero() {
+		return nil
+	}
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO ledger_entries (user_id, kind, debit_account, credit_account, amount, order_number, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := tx.Exec(ctx, query, entry.UserID, entry.Kind, entry.Debit, entry.Credit, entry.Amount, nullableOrder(entry.Order), entry.Comment)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "balance_current_non_negative" {
+		return ErrInsufficientFunds
+	}
+	if err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.AddLedgerEntry", "user": entry.UserID, "error": err}).Error("failed to add entry")
+	}
+	return err
+}
+
+// GetLedgerEntries returns up to limit entries of the user older than beforeID, newest first.
+// A zero beforeID starts from the latest entry.
+func (s *PostgresStore) GetLedgerEntries(ctx context.Context, userID int64, beforeID int64, limit int) ([]LedgerEntry, error) {
+	query := `SELECT id, user_id, kind, debit_account, credit_account, amount, order_number, comment, created_at
+		FROM ledger_entries
+		WHERE user_id = $1 AND ($2::BIGINT = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3`
+	rows, err := s.db.Query(ctx, query, userID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []LedgerEntry
+	for rows.Next() {
+		var record LedgerEntry
+		var order *string
+		errRow := rows.Scan(&record.ID, &record.UserID, &record.Kind, &record.Debit, &record.Credit,
+			&record.Amount, &order, &record.Comment, &record.CreatedAt)
+		if errRow != nil {
+			logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetLedgerEntries", "user": userID, "error": errRow}).Error("failed to find entry")
+			return nil, errRow
+		}
+		if order != nil {
+			record.Order = *order
+		}
+		records = append(records, record)
+	}
+
+	if errRow := rows.Err(); errRow != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetLedgerEntries", "user": userID, "error": errRow}).Error("failed to find entries")
+		return nil, errRow
+	}
+	return records, nil
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type LoginScope string
+
+const (
+	LoginScopeUser LoginScope = "user"
+	LoginScopeIP   LoginScope = "ip"
+)
+
+const (
+	LoginReasonUnknownUser   = "unknown user"
+	LoginReasonWrongPassword = "wrong password"
+)
+
+// LoginPolicy locks a subject after MaxAttempts failures for Lockout, every next failure doubles
+// the lockout up to MaxLockout. Failures are forgotten when none happened within Window.
+// A zero MaxAttempts disables the scope.
+type LoginPolicy struct {
+	MaxAttempts int
+	Lockout     time.Duration
+	MaxLockout  time.Duration
+	Window      time.Duration
+}
+
+func (p LoginPolicy) LockDuration(failures int) time.Duration {
+	if p.MaxAttempts <= 0 || failures < p.MaxAttempts {
+		return 0
+	}
+
+	lockout := p.Lockout
+	for i := p.MaxAttempts; i < failures && (p.MaxLockout <= 0 || lockout < p.MaxLockout); i++ {
+		lockout *= 2
+	}
+	if p.MaxLockout > 0 && lockout > p.MaxLockout {
+		lockout = p.MaxLockout
+	}
+	return lockout
+}
+
+// LoginAttempt is a failed login, it is kept in the audit log.
+type LoginAttempt struct {
+	Username string
+	IP       string
+	Reason   string
+}
+
+type loginSubject struct {
+	scope LoginScope
+	value string
+}
+
+// subjects are always listed in the same order, so concurrent failures lock rows without deadlocks.
+func (a LoginAttempt) subjects() []loginSubject {
+	return []loginSubject{{LoginScopeUser, a.Username}, {LoginScopeIP, a.IP}}
+}
+
+// LoginLockout returns how long logins of the username or from the address are still locked.
+func (s *PostgresStore) LoginLockout(ctx context.Context, username string, ip string) (time.Duration, error) {
+	var seconds float64
+	query := `SELECT COALESCE(MAX(EXTRACT(EPOCH FROM locked_until - CURRENT_TIMESTAMP)), 0)::FLOAT8
+		FROM login_throttles
+		WHERE locked_until > CURRENT_TIMESTAMP
+			AND ((scope = $1 AND subject = $2) OR (scope = $3 AND subject = $4))`
+	err := s.db.QueryRow(ctx, query, LoginScopeUser, username, LoginScopeIP, ip).Scan(&seconds)
+	return time.Duration(seconds * float64(time.Second)), err
+}
+
+// RecordLoginFailure counts the failure for the username and the address, locks subjects which
+// exceeded their policy and adds the attempt to the audit log. It returns the longest lockout set.
+func (s *PostgresStore) RecordLoginFailure(ctx context.Context, attempt LoginAttempt, policies map[LoginScope]LoginPolicy) (time.Duration, error) {
+	tx, err := s.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transcation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var lockout time.Duration
+	var userFailures int
+	for _, subject := range attempt.subjects() {
+		policy, ok := policies[subject.scope]
+		if !ok || policy.MaxAttempts <= 0 {
+			continue
+		}
+
+		var failures int
+		query := `INSERT INTO login_throttles (scope, subject, failures) VALUES ($1, $2, 1)
+			ON CONFLICT (scope, subject) DO UPDATE SET
+				failures = CASE
+					WHEN login_throttles.updated_at < CURRENT_TIMESTAMP - make_interval(secs => $3) THEN 1
+					ELSE login_throttles.failures + 1
+				END,
+				updated_at = CURRENT_TIMESTAMP
+			RETURNING failures`
+		err = tx.QueryRow(ctx, query, subject.scope, subject.value, policy.Window.Seconds()).Scan(&failures)
+		if err != nil {
+			return 0, fmt.Errorf("count login failure error: %w", err)
+		}
+		if subject.scope == LoginScopeUser {
+			userFailures = failures
+		}
+
+		locked := policy.LockDuration(failures)
+		if locked <= 0 {
+			continue
+		}
+		query = `UPDATE login_throttles SET locked_until = CURRENT_TIMESTAMP + make_interval(secs => $3)
+			WHERE scope = $1 AND subject = $2`
+		if _, err = tx.Exec(ctx, query, subject.scope, subject.value, locked.Seconds()); err != nil {
+			return 0, fmt.Errorf("lock login error: %w", err)
+		}
+		lockout = max(lockout, locked)
+	}
+
+	var lockedFor *string
+	if lockout > 0 {
+		interval := fmt.Sprintf("%f seconds", lockout.Seconds())
+		lockedFor = &interval
+	}
+	query := `INSERT INTO login_attempts (username, ip, reason, failures, locked_for) VALUES ($1, $2, $3, $4, $5::INTERVAL)`
+	if _, err = tx.Exec(ctx, query, attempt.Username, attempt.IP, attempt.Reason, userFailures, lockedFor); err != nil {
+		return 0, fmt.Errorf("audit login attempt error: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.RecordLoginFailure", "user": attempt.Username, "error": err}).Error("failed to commit transaction")
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return lockout, nil
+}
+
+// ResetLoginFailures forgets failures of the username after a successful login. Address counters
+// are kept, otherwise an attacker could reset them with an own account.
+func (s *PostgresStore) ResetLoginFailures(ctx context.Context, username string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM login_throttles WHERE scope = $1 AND subject = $2`, LoginScopeUser, username)
+	return err
+}
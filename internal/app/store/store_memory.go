@@ -0,0 +1,983 @@
+package store
+
+import (
+	"TimBerk/gophermart/internal/app/metrics"
+	"TimBerk/gophermart/internal/app/models/balance"
+	model "TimBerk/gophermart/internal/app/models/order"
+	"TimBerk/gophermart/pkg/money"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrMemoryTxUnsupported = errors.New("operation is not supported by memory transaction")
+var ErrMemoryTxClosed = errors.New("memory transaction is closed")
+
+type memoryUser struct {
+	UserRecord
+	createdAt time.Time
+	deletedAt time.Time
+}
+
+type memoryOrder struct {
+	OrderRecord
+	createdAt   time.Time
+	lockedBy    string
+	lockedUntil time.Time
+}
+
+type memoryRefreshToken struct {
+	RefreshTokenRecord
+	expiresAt time.Time
+	used      bool
+	revoked   bool
+}
+
+type memoryLoginThrottle struct {
+	failures    int
+	lockedUntil time.Time
+	updatedAt   time.Time
+}
+
+type memoryLoginAttempt struct {
+	LoginAttempt
+	failures  int
+	lockedFor time.Duration
+	createdAt time.Time
+}
+
+type memoryWithdrawal struct {
+	id        int64
+	userID    int64
+	order     string
+	sum       money.Amount
+	createdAt time.Time
+}
+
+// MemoryStore keeps all data in process memory and mirrors PostgresStore semantics.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	lastUserID   int64
+	lastOrderID  int64
+	lastLedgerID int64
+
+	lastWithdrawalID int64
+
+	users       map[string]*memoryUser
+	deleted     map[int64]*memoryUser
+	orders      map[string]*memoryOrder
+	balances    map[int64]*balance.Balance
+	withdrawals []memoryWithdrawal
+	ledger      []LedgerEntry
+	idempotency map[memoryIdempotencyKey]IdempotencyRecord
+
+	refreshTokens map[string]*memoryRefreshToken
+	revokedTokens map[string]time.Time
+
+	loginThrottles map[loginSubject]*memoryLoginThrottle
+	loginAttempts  []memoryLoginAttempt
+}
+
+type memoryIdempotencyKey struct {
+	userID int64
+	key    string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:       make(map[string]*memoryUser),
+		deleted:     make(map[int64]*memoryUser),
+		orders:      make(map[string]*memoryOrder),
+		balances:    make(map[int64]*balance.Balance),
+		idempotency: make(map[memoryIdempotencyKey]IdempotencyRecord),
+
+		refreshTokens: make(map[string]*memoryRefreshToken),
+		revokedTokens: make(map[string]time.Time),
+
+		loginThrottles: make(map[loginSubject]*memoryLoginThrottle),
+	}
+}
+
+func uniqueViolation(constraint string) error {
+	return dbError(&pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           uniqueViolationCode,
+		Message:        "duplicate key value violates unique constraint",
+		ConstraintName: constraint,
+	})
+}
+
+func (s *MemoryStore) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &memoryTx{store: s}, nil
+}
+
+func (s *MemoryStore) AddUser(ctx context.Context, username string, password string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[username]; exists {
+		return 0, uniqueViolation("users_username_key")
+	}
+
+	s.lastUserID++
+	s.users[username] = &memoryUser{
+		UserRecord: UserRecord{ID: s.lastUserID, Username: username, PasswordHash: password},
+		createdAt:  time.Now(),
+	}
+	s.balances[s.lastUserID] = &balance.Balance{}
+	return s.lastUserID, nil
+}
+
+func (s *MemoryStore) CheckUser(ctx context.Context, username string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		return 0, nil
+	}
+	return user.ID, nil
+}
+
+func (s *MemoryStore) GetUser(ctx context.Context, username string) (UserRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[username]
+	if !exists || !user.deletedAt.IsZero() {
+		return UserRecord{}, ErrNotFound
+	}
+	return user.UserRecord, nil
+}
+
+func (s *MemoryStore) userByID(userID int64) (*memoryUser, bool) {
+	if user, exists := s.deleted[userID]; exists {
+		return user, true
+	}
+	for _, user := range s.users {
+		if user.ID == userID {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+func (s *MemoryStore) UpdatePasswordHash(ctx context.Context, userID int64, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.userByID(userID)
+	if !exists {
+		return ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	return nil
+}
+
+func (s *MemoryStore) ChangePassword(ctx context.Context, userID int64, passwordHash string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.userByID(userID)
+	if !exists || !user.deletedAt.IsZero() {
+		return 0, ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	user.SessionGeneration++
+
+	for _, token := range s.refreshTokens {
+		if token.UserID == userID {
+			token.revoked = true
+		}
+	}
+	return user.SessionGeneration, nil
+}
+
+func (s *MemoryStore) DeleteUser(ctx context.Context, userID int64, force bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.userByID(userID)
+	if !exists || !user.deletedAt.IsZero() {
+		return ErrNotFound
+	}
+
+	var pending []*memoryOrder
+	for _, order := range s.orders {
+		if order.UserID == userID && !order.Status.IsFinal() {
+			pending = append(pending, order)
+		}
+	}
+	if !force {
+		switch {
+		case s.balances[userID].Current.IsPositive():
+			return ErrUserHasBalance
+		case len(pending) > 0:
+			return ErrUserHasPendingOrders
+		}
+	}
+
+	now := time.Now()
+	for _, order := range pending {
+		order.Status = Invalid
+		order.lockedBy, order.lockedUntil = "", time.Time{}
+	}
+
+	delete(s.users, user.Username)
+	delete(s.loginThrottles, loginSubject{LoginScopeUser, user.Username})
+	user.Username = ""
+	user.PasswordHash = ""
+	user.deletedAt = now
+	user.SessionGeneration++
+	s.deleted[userID] = user
+
+	for hash, token := range s.refreshTokens {
+		if token.UserID == userID {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	for key := range s.idempotency {
+		if key.userID == userID {
+			delete(s.idempotency, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) UploadOrder(ctx context.Context, userID int64, order string) (UploadResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, exists := s.orders[order]; exists {
+		if record.UserID != userID {
+			return UploadConflict, nil
+		}
+		return UploadExisting, nil
+	}
+
+	s.lastOrderID++
+	s.orders[order] = &memoryOrder{
+		OrderRecord: OrderRecord{ID: s.lastOrderID, UserID: userID, Order: order, Status: New},
+		// Postgres keeps microseconds, cursors rely on the same precision.
+		createdAt: time.Now().Truncate(time.Microsecond),
+	}
+	return UploadAccepted, nil
+}
+
+func (s *MemoryStore) AddOrder(ctx context.Context, userID int64, order string) error {
+	result, err := s.UploadOrder(ctx, userID, order)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+func (s *MemoryStore) GetOrder(ctx context.Context, order string) (OrderRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.orders[order]
+	if !exists {
+		return OrderRecord{}, ErrNotFound
+	}
+	return record.OrderRecord, nil
+}
+
+func (s *MemoryStore) GetOrderList(ctx context.Context, userID int64) (model.OrderListResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var userOrders []*memoryOrder
+	for _, record := range s.orders {
+		if record.UserID == userID {
+			userOrders = append(userOrders, record)
+		}
+	}
+	sort.Slice(userOrders, func(i, j int) bool {
+		return userOrders[i].ID > userOrders[j].ID
+	})
+
+	var records model.OrderListResponse
+	for _, record := range userOrders {
+		item := model.OrderResponse{
+			Number:    record.Order,
+			Status:    string(record.Status),
+			Accrual:   record.Accrual.Ptr(),
+			CreatedAt: record.createdAt,
+		}
+		records = append(records, item)
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) ClaimOrdersForAccrual(ctx context.Context, owner string, limit int, lease time.Duration) ([]model.UserOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]*memoryOrder, 0, len(s.orders))
+	for _, record := range s.orders {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+
+	now := time.Now()
+	var orders []model.UserOrder
+	for _, record := range records {
+		if len(orders) >= limit {
+			break
+		}
+		if record.Status != New && record.Status != Processing {
+			continue
+		}
+		if record.lockedBy != "" && record.lockedUntil.After(now) {
+			continue
+		}
+		record.lockedBy = owner
+		record.lockedUntil = now.Add(lease)
+		orders = append(orders, model.UserOrder{
+			UserID: record.UserID,
+			Number: record.Order,
+			Status: string(record.Status),
+		})
+	}
+	return orders, nil
+}
+
+func (s *MemoryStore) RenewOrderLeases(ctx context.Context, owner string, lease time.Duration) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var orders []string
+	for _, record := range s.orders {
+		if record.lockedBy == owner && (record.Status == New || record.Status == Processing) {
+			record.lockedUntil = now.Add(lease)
+			orders = append(orders, record.Order)
+		}
+	}
+	return orders, nil
+}
+
+func (s *MemoryStore) ReleaseOrders(ctx context.Context, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range s.orders {
+		if record.lockedBy == owner {
+			record.lockedBy = ""
+			record.lockedUntil = time.Time{}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) AddWithdrawal(ctx context.Context, userID int64, order string, sum money.Amount) error {
+	tx, err := s.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transcation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	memTx := tx.(*memoryTx)
+	memTx.withdrawals = append(memTx.withdrawals, memoryWithdrawal{userID: userID, order: order, sum: sum})
+
+	err = s.AddLedgerEntry(ctx, tx, WithdrawalEntry(userID, order, sum))
+	if err != nil {
+		return fmt.Errorf("update user balance error: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	metrics.BalanceWithdrawn.Add(sum.Float64())
+
+	return err
+}
+
+func (s *MemoryStore) UpdateOrderStatus(ctx context.Context, userID int64, order string, status Status, accrual money.Amount) error {
+	tx, err := s.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transcation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	s.mu.RLock()
+	record, exists := s.orders[order]
+	var current Status
+	if exists {
+		exists = record.UserID == userID
+		current = record.Status
+	}
+	s.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("lock order error: %w", ErrNotFound)
+	}
+	if current == status && !current.IsFinal() {
+		return nil
+	}
+	if !CanTransition(current, status) {
+		return &StatusTransitionError{Order: order, From: current, To: status}
+	}
+
+	update := memoryOrderUpdate{order: order, from: current, status: status}
+	if status == Processed {
+		update.accrual = money.NewNullAmount(accrual)
+	}
+	memTx := tx.(*memoryTx)
+	memTx.orderUpdates = append(memTx.orderUpdates, update)
+
+	if status == Processed {
+		err = s.AddLedgerEntry(ctx, tx, AccrualEntry(userID, order, accrual))
+		if err != nil {
+			return fmt.Errorf("update user balance error: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return err
+}
+
+func (s *MemoryStore) GetBalance(ctx context.Context, userID int64) (balance.Balance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.balances[userID]
+	if !exists {
+		return balance.Balance{}, ErrNotFound
+	}
+	return *record, nil
+}
+
+func (s *MemoryStore) AddBalance(ctx context.Context, tx pgx.Tx, userID int64, sum money.Amount) error {
+	return s.AddLedgerEntry(ctx, tx, AdjustmentEntry(userID, sum, "manual adjustment"))
+}
+
+func (s *MemoryStore) WithdrawBalance(ctx context.Context, tx pgx.Tx, userID int64, sum money.Amount) error {
+	return s.AddLedgerEntry(ctx, tx, WithdrawalEntry(userID, "", sum))
+}
+
+func (s *MemoryStore) AddLedgerEntry(ctx context.Context, tx pgx.Tx, entry LedgerEntry) error {
+	memTx, err := s.ownTx(tx)
+	if err != nil {
+		return err
+	}
+	if entry.Amount.IsZero() {
+		return nil
+	}
+	if err = entry.Validate(); err != nil {
+		return err
+	}
+	memTx.ledgerEntries = append(memTx.ledgerEntries, entry)
+	return nil
+}
+
+func (s *MemoryStore) GetLedgerEntries(ctx context.Context, userID int64, beforeID int64, limit int) ([]LedgerEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []LedgerEntry
+	for i := len(s.ledger) - 1; i >= 0 && len(records) < limit; i-- {
+		record := s.ledger[i]
+		if record.UserID != userID || (beforeID != 0 && record.ID >= beforeID) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) GetOrderWithdrawals(ctx context.Context, userID int64) (balance.WithdrawnList, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records balance.WithdrawnList
+	for i := len(s.withdrawals) - 1; i >= 0; i-- {
+		record := s.withdrawals[i]
+		if record.userID != userID {
+			continue
+		}
+		records = append(records, balance.WithdrawnResponse{
+			Number:    record.order,
+			Sum:       record.sum,
+			CreatedAt: record.createdAt,
+		})
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) ReserveIdempotencyKey(ctx context.Context, userID int64, key string, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := memoryIdempotencyKey{userID: userID, key: key}
+	now := time.Now()
+	if record, exists := s.idempotency[id]; exists && !record.CreatedAt.Before(now.Add(-ttl)) {
+		return record, false, nil
+	}
+
+	record := IdempotencyRecord{UserID: userID, Key: key, Fingerprint: fingerprint, CreatedAt: now}
+	s.idempotency[id] = record
+	return record, true, nil
+}
+
+func (s *MemoryStore) CompleteIdempotencyKey(ctx context.Context, userID int64, key string, statusCode int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := memoryIdempotencyKey{userID: userID, key: key}
+	record, exists := s.idempotency[id]
+	if !exists {
+		return nil
+	}
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Body = append([]byte(nil), body...)
+	s.idempotency[id] = record
+	return nil
+}
+
+func (s *MemoryStore) DeleteIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.idempotency, memoryIdempotencyKey{userID: userID, key: key})
+	return nil
+}
+
+func (s *MemoryStore) PurgeIdempotencyKeys(ctx context.Context, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	expired := time.Now().Add(-ttl)
+	for id, record := range s.idempotency {
+		if record.CreatedAt.Before(expired) {
+			delete(s.idempotency, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *MemoryStore) usernameByID(userID int64) (string, bool) {
+	for _, user := range s.users {
+		if user.ID == userID {
+			return user.Username, true
+		}
+	}
+	return "", false
+}
+
+func (s *MemoryStore) AddRefreshToken(ctx context.Context, userID int64, familyID string, tokenHash string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	username, exists := s.usernameByID(userID)
+	if !exists {
+		return fmt.Errorf("user %d: %w", userID, ErrNotFound)
+	}
+	if _, exists = s.refreshTokens[tokenHash]; exists {
+		return uniqueViolation("refresh_tokens_token_hash_key")
+	}
+	s.refreshTokens[tokenHash] = &memoryRefreshToken{
+		RefreshTokenRecord: RefreshTokenRecord{UserID: userID, Username: username, FamilyID: familyID, TokenHash: tokenHash},
+		expiresAt:          time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (s *MemoryStore) RotateRefreshToken(ctx context.Context, tokenHash string, newHash string, ttl time.Duration) (RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.refreshTokens[tokenHash]
+	if !exists || token.revoked || time.Now().After(token.expiresAt) {
+		return RefreshTokenRecord{TokenHash: newHash}, ErrRefreshTokenInvalid
+	}
+
+	record := token.RefreshTokenRecord
+	record.TokenHash = newHash
+	if user, exists := s.userByID(record.UserID); exists {
+		record.SessionGeneration = user.SessionGeneration
+	}
+	if token.used {
+		s.revokeFamily(token.FamilyID)
+		return record, ErrRefreshTokenReused
+	}
+	if _, exists = s.refreshTokens[newHash]; exists {
+		return record, uniqueViolation("refresh_tokens_token_hash_key")
+	}
+
+	token.used = true
+	s.refreshTokens[newHash] = &memoryRefreshToken{RefreshTokenRecord: record, expiresAt: time.Now().Add(ttl)}
+	return record, nil
+}
+
+func (s *MemoryStore) revokeFamily(familyID string) {
+	for _, token := range s.refreshTokens {
+		if token.FamilyID == familyID {
+			token.revoked = true
+		}
+	}
+}
+
+func (s *MemoryStore) RevokeRefreshFamily(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token, exists := s.refreshTokens[tokenHash]; exists {
+		s.revokeFamily(token.FamilyID)
+	}
+	return nil
+}
+
+func (s *MemoryStore) RevokeAccessToken(ctx context.Context, jti string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, expiresAt := range s.revokedTokens {
+		if expiresAt.Before(now) {
+			delete(s.revokedTokens, id)
+		}
+	}
+	if _, exists := s.revokedTokens[jti]; !exists {
+		s.revokedTokens[jti] = now.Add(ttl)
+	}
+	return nil
+}
+
+func (s *MemoryStore) IsAccessTokenRevoked(ctx context.Context, userID int64, jti string, generation int64) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, revoked := s.revokedTokens[jti]; revoked {
+		return true, nil
+	}
+	if user, exists := s.userByID(userID); exists {
+		return !user.deletedAt.IsZero() || user.SessionGeneration > generation, nil
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) ownTx(tx pgx.Tx) (*memoryTx, error) {
+	memTx, ok := tx.(*memoryTx)
+	if !ok || memTx.store != s {
+		return nil, fmt.Errorf("foreign transaction: %w", ErrMemoryTxUnsupported)
+	}
+	if memTx.closed {
+		return nil, ErrMemoryTxClosed
+	}
+	return memTx, nil
+}
+
+// apply validates and writes all staged changes of the transaction while holding the store lock,
+// so either every change becomes visible or none of them does.
+func (s *MemoryStore) apply(memTx *memoryTx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The status read before staging may be stale, the update is applied only if it is still the same.
+	for _, update := range memTx.orderUpdates {
+		if record, exists := s.orders[update.order]; exists && record.Status != update.from {
+			return &StatusTransitionError{Order: update.order, From: record.Status, To: update.status}
+		}
+	}
+
+	seen := make(map[string]struct{}, len(memTx.withdrawals))
+	for _, record := range memTx.withdrawals {
+		if _, exists := seen[record.order]; exists {
+			return uniqueViolation("withdrawals_order_number_key")
+		}
+		for _, existing := range s.withdrawals {
+			if existing.order == record.order {
+				return uniqueViolation("withdrawals_order_number_key")
+			}
+		}
+		if !s.userExists(record.userID) {
+			return fmt.Errorf("user %d: %w", record.userID, ErrNotFound)
+		}
+		seen[record.order] = struct{}{}
+	}
+
+	currentChanges := make(map[int64]money.Amount)
+	for _, entry := range memTx.ledgerEntries {
+		if !s.userExists(entry.UserID) {
+			return fmt.Errorf("user %d: %w", entry.UserID, ErrNotFound)
+		}
+		currentChanges[entry.UserID] += entry.Delta(AccountCurrent)
+	}
+	for userID, change := range currentChanges {
+		if s.balances[userID].Current+change < 0 {
+			return ErrInsufficientFunds
+		}
+	}
+
+	now := time.Now()
+	for _, update := range memTx.orderUpdates {
+		if record, exists := s.orders[update.order]; exists {
+			record.Status = update.status
+			record.Accrual = update.accrual
+			record.lockedBy = ""
+			record.lockedUntil = time.Time{}
+		}
+	}
+	for _, record := range memTx.withdrawals {
+		s.lastWithdrawalID++
+		record.id = s.lastWithdrawalID
+		record.createdAt = now.Truncate(time.Microsecond)
+		s.withdrawals = append(s.withdrawals, record)
+	}
+	for _, entry := range memTx.ledgerEntries {
+		s.lastLedgerID++
+		entry.ID = s.lastLedgerID
+		entry.CreatedAt = now
+		s.ledger = append(s.ledger, entry)
+
+		if record, exists := s.balances[entry.UserID]; exists {
+			record.Current += entry.Delta(AccountCurrent)
+			record.Withdrawn += entry.Delta(AccountWithdrawn)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) userExists(userID int64) bool {
+	_, exists := s.balances[userID]
+	return exists
+}
+
+func (s *MemoryStore) LoginLockout(ctx context.Context, username string, ip string) (time.Duration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var lockout time.Duration
+	for _, subject := range (LoginAttempt{Username: username, IP: ip}).subjects() {
+		if throttle, exists := s.loginThrottles[subject]; exists {
+			lockout = max(lockout, time.Until(throttle.lockedUntil))
+		}
+	}
+	return lockout, nil
+}
+
+func (s *MemoryStore) RecordLoginFailure(ctx context.Context, attempt LoginAttempt, policies map[LoginScope]LoginPolicy) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var lockout time.Duration
+	var userFailures int
+	for _, subject := range attempt.subjects() {
+		policy, ok := policies[subject.scope]
+		if !ok || policy.MaxAttempts <= 0 {
+			continue
+		}
+
+		throttle, exists := s.loginThrottles[subject]
+		if !exists {
+			throttle = &memoryLoginThrottle{}
+			s.loginThrottles[subject] = throttle
+		}
+		if throttle.updatedAt.Before(now.Add(-policy.Window)) {
+			throttle.failures = 0
+		}
+		throttle.failures++
+		throttle.updatedAt = now
+		if subject.scope == LoginScopeUser {
+			userFailures = throttle.failures
+		}
+
+		if locked := policy.LockDuration(throttle.failures); locked > 0 {
+			throttle.lockedUntil = now.Add(locked)
+			lockout = max(lockout, locked)
+		}
+	}
+
+	s.loginAttempts = append(s.loginAttempts, memoryLoginAttempt{
+		LoginAttempt: attempt,
+		failures:     userFailures,
+		lockedFor:    lockout,
+		createdAt:    now,
+	})
+	return lockout, nil
+}
+
+func (s *MemoryStore) ResetLoginFailures(ctx context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginThrottles, loginSubject{LoginScopeUser, username})
+	return nil
+}
+
+func (s *MemoryStore) GetOrderPage(ctx context.Context, userID int64, filter OrderFilter) (model.OrderListResponse, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var userOrders []*memoryOrder
+	for _, record := range s.orders {
+		if record.UserID == userID && filter.matches(record) {
+			userOrders = append(userOrders, record)
+		}
+	}
+	sort.Slice(userOrders, func(i, j int) bool {
+		if !userOrders[i].createdAt.Equal(userOrders[j].createdAt) {
+			return userOrders[i].createdAt.After(userOrders[j].createdAt)
+		}
+		return userOrders[i].ID > userOrders[j].ID
+	})
+
+	var next *Cursor
+	if len(userOrders) > filter.Limit {
+		userOrders = userOrders[:filter.Limit]
+		last := userOrders[len(userOrders)-1]
+		next = &Cursor{CreatedAt: last.createdAt, ID: last.ID}
+	}
+
+	var records model.OrderListResponse
+	for _, record := range userOrders {
+		records = append(records, model.OrderResponse{
+			Number:    record.Order,
+			Status:    string(record.Status),
+			Accrual:   record.Accrual.Ptr(),
+			CreatedAt: record.createdAt,
+		})
+	}
+	return records, next, nil
+}
+
+func (s *MemoryStore) GetWithdrawalPage(ctx context.Context, userID int64, filter PageFilter) (balance.WithdrawnList, *Cursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Withdrawals are appended in the order of creation, so the newest are at the end.
+	var records balance.WithdrawnList
+	var last memoryWithdrawal
+	for i := len(s.withdrawals) - 1; i >= 0; i-- {
+		record := s.withdrawals[i]
+		if record.userID != userID || !filter.matches(record.createdAt, record.id) {
+			continue
+		}
+		if len(records) == filter.Limit {
+			return records, &Cursor{CreatedAt: last.createdAt, ID: last.id}, nil
+		}
+		last = record
+		records = append(records, balance.WithdrawnResponse{
+			Number:    record.order,
+			Sum:       record.sum,
+			CreatedAt: record.createdAt,
+		})
+	}
+	return records, nil, nil
+}
+
+func (s *MemoryStore) GetWithdrawalSummary(ctx context.Context, userID int64, filter PageFilter) (balance.WithdrawnSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	period := PageFilter{From: filter.From, To: filter.To}
+	var record balance.WithdrawnSummary
+	for _, withdrawal := range s.withdrawals {
+		if withdrawal.userID == userID && period.matches(withdrawal.createdAt, withdrawal.id) {
+			record.Count++
+			record.Sum += withdrawal.sum
+		}
+	}
+	return record, nil
+}
+
+// ExportOrders copies matching orders under the lock and passes them to fn after it is released,
+// so a slow client doesn't block writers.
+func (s *MemoryStore) ExportOrders(ctx context.Context, userID int64, from time.Time, to time.Time, fn func(model.OrderResponse) error) error {
+	period := PageFilter{From: from, To: to}
+
+	s.mu.RLock()
+	var userOrders []memoryOrder
+	for _, record := range s.orders {
+		if record.UserID == userID && period.matches(record.createdAt, record.ID) {
+			userOrders = append(userOrders, *record)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(userOrders, func(i, j int) bool {
+		if !userOrders[i].createdAt.Equal(userOrders[j].createdAt) {
+			return userOrders[i].createdAt.Before(userOrders[j].createdAt)
+		}
+		return userOrders[i].ID < userOrders[j].ID
+	})
+
+	for _, record := range userOrders {
+		err := fn(model.OrderResponse{
+			Number:    record.Order,
+			Status:    string(record.Status),
+			Accrual:   record.Accrual.Ptr(),
+			CreatedAt: record.createdAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ExportWithdrawals(ctx context.Context, userID int64, from time.Time, to time.Time, fn func(balance.WithdrawnResponse) error) error {
+	period := PageFilter{From: from, To: to}
+
+	s.mu.RLock()
+	var withdrawals []memoryWithdrawal
+	for _, record := range s.withdrawals {
+		if record.userID == userID && period.matches(record.createdAt, record.id) {
+			withdrawals = append(withdrawals, record)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, record := range withdrawals {
+		err := fn(balance.WithdrawnResponse{
+			Number:    record.order,
+			Sum:       record.sum,
+			CreatedAt: record.createdAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) AddOrders(ctx context.Context, userID int64, orders []string) (map[string]UploadResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().Truncate(time.Microsecond)
+	inserted := make(map[int64]struct{}, len(orders))
+	results := make(map[string]UploadResult, len(orders))
+	for _, order := range orders {
+		record, exists := s.orders[order]
+		if !exists {
+			s.lastOrderID++
+			record = &memoryOrder{
+				OrderRecord: OrderRecord{ID: s.lastOrderID, UserID: userID, Order: order, Status: New},
+				createdAt:   now,
+			}
+			s.orders[order] = record
+			inserted[record.ID] = struct{}{}
+		}
+		results[order] = uploadResult(userID, record.UserID, record.ID, inserted)
+	}
+	return results, nil
+}
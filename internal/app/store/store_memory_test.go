@@ -0,0 +1,664 @@
+package store
+
+import (
+	"TimBerk/gophermart/pkg/money"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreUsers(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	userID, err := s.AddUser(ctx, "user", "hash")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+
+	_, err = s.AddUser(ctx, "user", "hash")
+	assert.ErrorIs(t, err, ErrDuplicateUser)
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	assert.Equal(t, "23505", pgErr.Code)
+
+	checkedID, err := s.CheckUser(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, userID, checkedID)
+
+	checkedID, err = s.CheckUser(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), checkedID)
+
+	record, err := s.GetUser(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, UserRecord{ID: userID, Username: "user", PasswordHash: "hash"}, record)
+
+	_, err = s.GetUser(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	current, err := s.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), current.Current)
+}
+
+func TestMemoryStoreOrders(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+
+	require.NoError(t, s.AddOrder(ctx, userID, "12345678903"))
+	require.NoError(t, s.AddOrder(ctx, userID, "79927398713"))
+	assert.ErrorIs(t, s.AddOrder(ctx, userID, "12345678903"), ErrDuplicateOrder)
+	otherID, _ := s.AddUser(ctx, "other", "hash")
+	assert.ErrorIs(t, s.AddOrder(ctx, otherID, "12345678903"), ErrOrderOwnedByOther)
+
+	_, err := s.GetOrder(ctx, "4561261212345467")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	pending, err := s.ClaimOrdersForAccrual(ctx, "owner", 10, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+	require.NoError(t, s.ReleaseOrders(ctx, "owner"))
+
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processed, money.MustParse("500.5")))
+
+	record, err := s.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, Processed, record.Status)
+	assert.True(t, record.Accrual.Valid)
+	assert.Equal(t, money.MustParse("500.5"), record.Accrual.Amount)
+
+	list, err := s.GetOrderList(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "79927398713", list[0].Number)
+	assert.Nil(t, list[0].Accrual)
+	assert.Equal(t, "12345678903", list[1].Number)
+	require.NotNil(t, list[1].Accrual)
+	assert.Equal(t, money.MustParse("500.5"), *list[1].Accrual)
+
+	pending, err = s.ClaimOrdersForAccrual(ctx, "owner", 10, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	current, err := s.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("500.5"), current.Current)
+}
+
+func TestMemoryStoreOrderLeases(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	for _, number := range []string{"12345678903", "79927398713", "49927398716"} {
+		require.NoError(t, s.AddOrder(ctx, userID, number))
+	}
+
+	first, err := s.ClaimOrdersForAccrual(ctx, "replica-1", 2, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	assert.Equal(t, "12345678903", first[0].Number)
+
+	second, err := s.ClaimOrdersForAccrual(ctx, "replica-2", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, second, 1, "orders leased by another replica must be skipped")
+	assert.Equal(t, "49927398716", second[0].Number)
+
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processing, 0))
+	require.NoError(t, s.ReleaseOrders(ctx, "replica-2"))
+
+	third, err := s.ClaimOrdersForAccrual(ctx, "replica-2", 10, time.Millisecond)
+	require.NoError(t, err)
+	assert.Len(t, third, 2, "updated and released orders are available again")
+
+	time.Sleep(2 * time.Millisecond)
+	expired, err := s.ClaimOrdersForAccrual(ctx, "replica-1", 10, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, expired, 2, "expired leases are taken over")
+
+	renewed, err := s.RenewOrderLeases(ctx, "replica-2", time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, renewed, "leases taken over are not renewed")
+
+	renewed, err = s.RenewOrderLeases(ctx, "replica-1", time.Millisecond)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"12345678903", "79927398713", "49927398716"}, renewed)
+}
+
+func TestCanTransition(t *testing.T) {
+	testCases := []struct {
+		from, to Status
+		expected bool
+	}{
+		{from: New, to: Processing, expected: true},
+		{from: New, to: Processed, expected: true},
+		{from: New, to: Invalid, expected: true},
+		{from: Processing, to: Processed, expected: true},
+		{from: Processing, to: Invalid, expected: true},
+		{from: Processing, to: New, expected: false},
+		{from: Processed, to: Processed, expected: false},
+		{from: Processed, to: Invalid, expected: false},
+		{from: Invalid, to: Processed, expected: false},
+		{from: New, to: Undefined, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s to %s", tc.from, tc.to), func(t *testing.T) {
+			assert.Equal(t, tc.expected, CanTransition(tc.from, tc.to))
+		})
+	}
+}
+
+func TestMemoryStoreOrderTransitions(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	require.NoError(t, s.AddOrder(ctx, userID, "12345678903"))
+	require.NoError(t, s.AddOrder(ctx, userID, "79927398713"))
+
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processing, 0))
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processing, 0), "repeated status is a no-op")
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processed, money.FromUnits(100)))
+
+	err := s.UpdateOrderStatus(ctx, userID, "12345678903", Processed, money.FromUnits(100))
+	assert.ErrorIs(t, err, ErrInvalidStatusTransition)
+	var transitionErr *StatusTransitionError
+	require.ErrorAs(t, err, &transitionErr)
+	assert.Equal(t, Processed, transitionErr.From)
+
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "79927398713", Invalid, 0))
+	assert.ErrorIs(t, s.UpdateOrderStatus(ctx, userID, "79927398713", Processed, money.FromUnits(5)), ErrInvalidStatusTransition)
+
+	invalid, err := s.GetOrder(ctx, "79927398713")
+	require.NoError(t, err)
+	assert.False(t, invalid.Accrual.Valid, "only processed orders have accrual")
+
+	assert.ErrorIs(t, s.UpdateOrderStatus(ctx, userID+1, "12345678903", Processed, 0), ErrNotFound)
+
+	current, err := s.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromUnits(100), current.Current, "accrual must be credited once")
+
+	entries, err := s.GetLedgerEntries(ctx, userID, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestMemoryStoreParallelProcessing(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	require.NoError(t, s.AddOrder(ctx, userID, "12345678903"))
+
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.UpdateOrderStatus(ctx, userID, "12345678903", Processed, money.FromUnits(100))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrInvalidStatusTransition)
+	}
+	assert.Equal(t, 1, succeeded)
+
+	current, err := s.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromUnits(100), current.Current)
+}
+
+func TestMemoryStoreWithdrawals(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	require.NoError(t, s.AddOrder(ctx, userID, "12345678903"))
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processed, money.FromUnits(100)))
+
+	require.NoError(t, s.AddWithdrawal(ctx, userID, "2377225624", money.FromUnits(40)))
+	assert.Error(t, s.AddWithdrawal(ctx, userID, "2377225624", money.FromUnits(10)))
+	assert.ErrorIs(t, s.AddWithdrawal(ctx, userID, "49927398716", money.FromUnits(61)), ErrInsufficientFunds)
+
+	current, err := s.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromUnits(60), current.Current)
+	assert.Equal(t, money.FromUnits(40), current.Withdrawn)
+
+	list, err := s.GetOrderWithdrawals(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "2377225624", list[0].Number)
+	assert.Equal(t, money.FromUnits(40), list[0].Sum)
+}
+
+func TestMemoryStoreTransaction(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+
+	tx, err := s.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.AddBalance(ctx, tx, userID, money.FromUnits(100)))
+	require.NoError(t, tx.Rollback(ctx))
+	assert.ErrorIs(t, s.AddBalance(ctx, tx, userID, money.FromUnits(100)), ErrMemoryTxClosed)
+	assert.ErrorIs(t, tx.Commit(ctx), pgx.ErrTxClosed)
+
+	current, _ := s.GetBalance(ctx, userID)
+	assert.Equal(t, money.Amount(0), current.Current)
+
+	tx, err = s.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.AddBalance(ctx, tx, userID, money.FromUnits(100)))
+	require.NoError(t, s.WithdrawBalance(ctx, tx, userID, money.FromUnits(30)))
+
+	current, _ = s.GetBalance(ctx, userID)
+	assert.Equal(t, money.Amount(0), current.Current, "changes must be invisible before commit")
+
+	require.NoError(t, tx.Commit(ctx))
+	current, _ = s.GetBalance(ctx, userID)
+	assert.Equal(t, money.FromUnits(70), current.Current)
+	assert.Equal(t, money.FromUnits(30), current.Withdrawn)
+
+	_, err = tx.Exec(ctx, "SELECT 1")
+	assert.True(t, errors.Is(err, ErrMemoryTxUnsupported))
+
+	other := NewMemoryStore()
+	otherTx, _ := other.BeginTx(ctx)
+	assert.ErrorIs(t, s.AddBalance(ctx, otherTx, userID, money.FromUnits(1)), ErrMemoryTxUnsupported)
+}
+
+func TestMemoryStoreConcurrency(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+
+	const workers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			order := fmt.Sprintf("order-%d", i)
+			assert.NoError(t, s.AddOrder(ctx, userID, order))
+			assert.NoError(t, s.UpdateOrderStatus(ctx, userID, order, Processed, money.FromUnits(2)))
+			assert.NoError(t, s.AddWithdrawal(ctx, userID, "w-"+order, money.FromUnits(1)))
+		}(i)
+	}
+	wg.Wait()
+
+	current, err := s.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromUnits(workers), current.Current)
+	assert.Equal(t, money.FromUnits(workers), current.Withdrawn)
+
+	list, err := s.GetOrderList(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, list, workers)
+}
+
+func TestMemoryStoreParallelWithdrawals(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	require.NoError(t, s.AddOrder(ctx, userID, "12345678903"))
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processed, money.FromUnits(100)))
+
+	const workers = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded, rejected := 0, 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := s.AddWithdrawal(ctx, userID, fmt.Sprintf("w-%d", i), money.FromUnits(30))
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, ErrInsufficientFunds):
+				rejected++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, succeeded)
+	assert.Equal(t, workers-3, rejected)
+
+	current, err := s.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromUnits(10), current.Current)
+	assert.Equal(t, money.FromUnits(90), current.Withdrawn)
+}
+
+func TestMemoryStoreLedger(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	require.NoError(t, s.AddOrder(ctx, userID, "12345678903"))
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processing, 0))
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processed, money.FromUnits(100)))
+	require.NoError(t, s.AddWithdrawal(ctx, userID, "2377225624", money.FromUnits(30)))
+
+	tx, _ := s.BeginTx(ctx)
+	require.NoError(t, s.AddBalance(ctx, tx, userID, money.FromUnits(-5)))
+	assert.ErrorIs(t, s.AddLedgerEntry(ctx, tx, LedgerEntry{UserID: userID, Debit: AccountCurrent, Credit: AccountCurrent, Amount: 1}), ErrInvalidLedgerEntry)
+	require.NoError(t, tx.Commit(ctx))
+
+	entries, err := s.GetLedgerEntries(ctx, userID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3, "zero accrual must not be recorded")
+	assert.Equal(t, LedgerAdjustment, entries[0].Kind)
+	assert.Equal(t, AccountCurrent, entries[0].Debit)
+	assert.Equal(t, money.FromUnits(5), entries[0].Amount)
+	assert.Equal(t, LedgerWithdrawal, entries[1].Kind)
+	assert.Equal(t, "2377225624", entries[1].Order)
+	assert.Equal(t, LedgerAccrual, entries[2].Kind)
+
+	page, err := s.GetLedgerEntries(ctx, userID, entries[0].ID, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, entries[1].ID, page[0].ID)
+
+	var current, withdrawn money.Amount
+	for _, entry := range entries {
+		current += entry.Delta(AccountCurrent)
+		withdrawn += entry.Delta(AccountWithdrawn)
+	}
+	record, err := s.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, record.Current, current)
+	assert.Equal(t, record.Withdrawn, withdrawn)
+	assert.Equal(t, money.FromUnits(65), record.Current)
+}
+
+func TestLoginPolicyLockDuration(t *testing.T) {
+	policy := LoginPolicy{MaxAttempts: 3, Lockout: 30 * time.Second, MaxLockout: 2 * time.Minute}
+
+	testCases := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 1, expected: 0},
+		{failures: 2, expected: 0},
+		{failures: 3, expected: 30 * time.Second},
+		{failures: 4, expected: time.Minute},
+		{failures: 5, expected: 2 * time.Minute},
+		{failures: 100, expected: 2 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d failures", tc.failures), func(t *testing.T) {
+			assert.Equal(t, tc.expected, policy.LockDuration(tc.failures))
+		})
+	}
+
+	assert.Zero(t, LoginPolicy{Lockout: time.Minute}.LockDuration(100), "zero attempts disable the scope")
+}
+
+func TestMemoryStoreLoginThrottling(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	policies := map[LoginScope]LoginPolicy{
+		LoginScopeUser: {MaxAttempts: 2, Lockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour},
+		LoginScopeIP:   {MaxAttempts: 3, Lockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour},
+	}
+
+	lockout, err := s.RecordLoginFailure(ctx, LoginAttempt{Username: "user", IP: "10.0.0.1", Reason: LoginReasonWrongPassword}, policies)
+	require.NoError(t, err)
+	assert.Zero(t, lockout)
+
+	lockout, err = s.RecordLoginFailure(ctx, LoginAttempt{Username: "user", IP: "10.0.0.2", Reason: LoginReasonWrongPassword}, policies)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, lockout)
+
+	remaining, err := s.LoginLockout(ctx, "user", "10.0.0.3")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, remaining, float64(time.Second), "username is locked from any address")
+
+	require.NoError(t, s.ResetLoginFailures(ctx, "user"))
+	remaining, err = s.LoginLockout(ctx, "user", "10.0.0.3")
+	require.NoError(t, err)
+	assert.LessOrEqual(t, remaining, time.Duration(0))
+
+	for _, username := range []string{"a", "b", "c"} {
+		_, err = s.RecordLoginFailure(ctx, LoginAttempt{Username: username, IP: "10.0.0.9", Reason: LoginReasonUnknownUser}, policies)
+		require.NoError(t, err)
+	}
+	remaining, err = s.LoginLockout(ctx, "d", "10.0.0.9")
+	require.NoError(t, err)
+	assert.Positive(t, remaining, "address is locked for every username")
+
+	require.Len(t, s.loginAttempts, 5)
+	assert.Equal(t, LoginAttempt{Username: "user", IP: "10.0.0.2", Reason: LoginReasonWrongPassword}, s.loginAttempts[1].LoginAttempt)
+	assert.Equal(t, 2, s.loginAttempts[1].failures)
+	assert.Equal(t, time.Minute, s.loginAttempts[1].lockedFor)
+}
+
+func TestMemoryStoreLoginWindow(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	policies := map[LoginScope]LoginPolicy{LoginScopeUser: {MaxAttempts: 2, Lockout: time.Minute, Window: time.Millisecond}}
+	attempt := LoginAttempt{Username: "user", IP: "10.0.0.1", Reason: LoginReasonWrongPassword}
+
+	_, err := s.RecordLoginFailure(ctx, attempt, policies)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	lockout, err := s.RecordLoginFailure(ctx, attempt, policies)
+	require.NoError(t, err)
+	assert.Zero(t, lockout, "failures outside the window are forgotten")
+}
+
+func TestMemoryStoreChangePassword(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	require.NoError(t, s.AddRefreshToken(ctx, userID, "family", "refresh", time.Hour))
+
+	before, err := s.GetUser(ctx, "user")
+	require.NoError(t, err)
+	generation, err := s.ChangePassword(ctx, userID, "new-hash")
+	require.NoError(t, err)
+	assert.Equal(t, before.SessionGeneration+1, generation)
+
+	record, err := s.GetUser(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, "new-hash", record.PasswordHash)
+	assert.Equal(t, generation, record.SessionGeneration)
+
+	_, err = s.RotateRefreshToken(ctx, "refresh", "next", time.Hour)
+	assert.ErrorIs(t, err, ErrRefreshTokenInvalid)
+
+	revoked, err := s.IsAccessTokenRevoked(ctx, userID, "old", before.SessionGeneration)
+	require.NoError(t, err)
+	assert.True(t, revoked, "token issued just before the change is revoked")
+
+	revoked, err = s.IsAccessTokenRevoked(ctx, userID, "new", generation)
+	require.NoError(t, err)
+	assert.False(t, revoked, "token issued after the change is kept")
+}
+
+func TestMemoryStoreDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	require.NoError(t, s.AddOrder(ctx, userID, "12345678903"))
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processed, money.FromUnits(100)))
+	require.NoError(t, s.AddWithdrawal(ctx, userID, "2377225624", money.FromUnits(40)))
+	require.NoError(t, s.AddOrder(ctx, userID, "49927398716"))
+	require.NoError(t, s.AddRefreshToken(ctx, userID, "family", "refresh", time.Hour))
+
+	assert.ErrorIs(t, s.DeleteUser(ctx, userID, false), ErrUserHasBalance)
+
+	require.NoError(t, s.AddWithdrawal(ctx, userID, "79927398713", money.FromUnits(60)))
+	assert.ErrorIs(t, s.DeleteUser(ctx, userID, false), ErrUserHasPendingOrders)
+
+	require.NoError(t, s.DeleteUser(ctx, userID, true))
+	assert.ErrorIs(t, s.DeleteUser(ctx, userID, true), ErrNotFound)
+
+	_, err := s.GetUser(ctx, "user")
+	assert.ErrorIs(t, err, ErrNotFound)
+	deleted, exists := s.userByID(userID)
+	require.True(t, exists, "anonymized user keeps its id")
+	assert.Empty(t, deleted.Username)
+
+	pending, err := s.GetOrder(ctx, "49927398716")
+	require.NoError(t, err)
+	assert.Equal(t, Invalid, pending.Status)
+
+	list, err := s.GetOrderWithdrawals(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, list, 2, "withdrawals are kept")
+
+	_, err = s.RotateRefreshToken(ctx, "refresh", "next", time.Hour)
+	assert.ErrorIs(t, err, ErrRefreshTokenInvalid)
+	revoked, err := s.IsAccessTokenRevoked(ctx, userID, "token", 1<<32)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	newID, err := s.AddUser(ctx, "user", "hash")
+	require.NoError(t, err)
+	assert.NotEqual(t, userID, newID, "login can be registered again")
+}
+
+func TestMemoryStoreDeleteUserPlaceholderTaken(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	squatterID, err := s.AddUser(ctx, fmt.Sprintf("deleted-%d", userID), "hash")
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteUser(ctx, userID, false))
+
+	record, err := s.GetUser(ctx, fmt.Sprintf("deleted-%d", userID))
+	require.NoError(t, err)
+	assert.Equal(t, squatterID, record.ID, "registered login is left alone")
+
+	otherID, _ := s.AddUser(ctx, "other", "hash")
+	require.NoError(t, s.DeleteUser(ctx, otherID, false), "several deleted users don't collide")
+}
+
+func TestMemoryStoreAddOrders(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	otherID, _ := s.AddUser(ctx, "other", "hash")
+	require.NoError(t, s.AddOrder(ctx, userID, "12345678903"))
+	require.NoError(t, s.AddOrder(ctx, otherID, "49927398716"))
+
+	results, err := s.AddOrders(ctx, userID, []string{"12345678903", "49927398716", "79927398713"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]UploadResult{
+		"12345678903": UploadExisting,
+		"49927398716": UploadConflict,
+		"79927398713": UploadAccepted,
+	}, results)
+
+	record, err := s.GetOrder(ctx, "79927398713")
+	require.NoError(t, err)
+	assert.Equal(t, userID, record.UserID)
+	assert.Equal(t, New, record.Status)
+
+	results, err = s.AddOrders(ctx, otherID, []string{"79927398713"})
+	require.NoError(t, err)
+	assert.Equal(t, UploadConflict, results["79927398713"])
+}
+
+func TestMemoryStoreUploadOrder(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	otherID, _ := s.AddUser(ctx, "other", "hash")
+
+	result, err := s.UploadOrder(ctx, userID, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, UploadAccepted, result)
+	assert.NoError(t, result.Err())
+
+	result, err = s.UploadOrder(ctx, userID, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, UploadExisting, result)
+	assert.ErrorIs(t, result.Err(), ErrDuplicateOrder)
+
+	result, err = s.UploadOrder(ctx, otherID, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, UploadConflict, result)
+	assert.ErrorIs(t, result.Err(), ErrOrderOwnedByOther)
+
+	record, err := s.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, userID, record.UserID)
+}
+
+func TestMemoryStorePendingOrdersMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+
+	for _, number := range []string{"12345678903", "4561261212345467", "79927398713"} {
+		_, err := s.UploadOrder(ctx, userID, number)
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "12345678903", Processing, 0))
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, "79927398713", Invalid, 0))
+
+	counts, err := s.CountOrdersByStatus(ctx, New, Processing, Processed)
+	require.NoError(t, err)
+	assert.Equal(t, map[Status]int64{New: 1, Processing: 1, Processed: 0}, counts)
+
+	expected := `
+# HELP gophermart_orders_pending Number of orders waiting for the accrual system by status.
+# TYPE gophermart_orders_pending gauge
+gophermart_orders_pending{status="NEW"} 1
+gophermart_orders_pending{status="PROCESSING"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(s, strings.NewReader(expected)))
+}
+
+func TestMemoryStorePurgeIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	_, reserved, err := s.ReserveIdempotencyKey(ctx, 1, "old", "fingerprint", time.Hour)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	_, reserved, err = s.ReserveIdempotencyKey(ctx, 1, "fresh", "fingerprint", time.Hour)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	old := memoryIdempotencyKey{userID: 1, key: "old"}
+	record := s.idempotency[old]
+	record.CreatedAt = time.Now().Add(-2 * time.Hour)
+	s.idempotency[old] = record
+
+	purged, err := s.PurgeIdempotencyKeys(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.NotContains(t, s.idempotency, old)
+	assert.Contains(t, s.idempotency, memoryIdempotencyKey{userID: 1, key: "fresh"})
+}
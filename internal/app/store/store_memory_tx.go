@@ -0,0 +1,116 @@
+package store
+
+import (
+	"TimBerk/gophermart/pkg/money"
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type memoryOrderUpdate struct {
+	order   string
+	from    Status
+	status  Status
+	accrual money.NullAmount
+}
+
+// memoryTx stages changes for MemoryStore and applies them atomically on Commit.
+// Only the operations used by the store itself are supported, raw SQL is rejected.
+type memoryTx struct {
+	store *MemoryStore
+
+	mu     sync.Mutex
+	closed bool
+
+	orderUpdates  []memoryOrderUpdate
+	withdrawals   []memoryWithdrawal
+	ledgerEntries []LedgerEntry
+}
+
+func (tx *memoryTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return nil, ErrMemoryTxUnsupported
+}
+
+func (tx *memoryTx) Commit(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return tx.store.apply(tx)
+}
+
+func (tx *memoryTx) Rollback(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	return nil
+}
+
+func (tx *memoryTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return 0, ErrMemoryTxUnsupported
+}
+
+func (tx *memoryTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return memoryBatchResults{}
+}
+
+func (tx *memoryTx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (tx *memoryTx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	return nil, ErrMemoryTxUnsupported
+}
+
+func (tx *memoryTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, ErrMemoryTxUnsupported
+}
+
+func (tx *memoryTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, ErrMemoryTxUnsupported
+}
+
+func (tx *memoryTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return memoryRow{}
+}
+
+func (tx *memoryTx) Conn() *pgx.Conn {
+	return nil
+}
+
+type memoryRow struct{}
+
+func (memoryRow) Scan(dest ...any) error {
+	return ErrMemoryTxUnsupported
+}
+
+type memoryBatchResults struct{}
+
+func (memoryBatchResults) Exec() (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, ErrMemoryTxUnsupported
+}
+
+func (memoryBatchResults) Query() (pgx.Rows, error) {
+	return nil, ErrMemoryTxUnsupported
+}
+
+func (memoryBatchResults) QueryRow() pgx.Row {
+	return memoryRow{}
+}
+
+func (memoryBatchResults) Close() error {
+	return nil
+}
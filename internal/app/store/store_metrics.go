@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// collectTimeout bounds the backlog query made on each scrape.
+const collectTimeout = 5 * time.Second
+
+// pendingStatuses are the statuses of orders the accrual worker still has to check.
+var pendingStatuses = []Status{New, Processing}
+
+var (
+	pendingOrdersDesc = prometheus.NewDesc("gophermart_orders_pending",
+		"Number of orders waiting for the accrual system by status.", []string{"status"}, nil)
+
+	poolMaxConnsDesc = prometheus.NewDesc("gophermart_db_pool_max_conns",
+		"Maximum size of the connection pool.", nil, nil)
+	poolTotalConnsDesc = prometheus.NewDesc("gophermart_db_pool_total_conns",
+		"Number of connections in the pool.", nil, nil)
+	poolAcquiredConnsDesc = prometheus.NewDesc("gophermart_db_pool_acquired_conns",
+		"Number of connections in use.", nil, nil)
+	poolIdleConnsDesc = prometheus.NewDesc("gophermart_db_pool_idle_conns",
+		"Number of idle connections.", nil, nil)
+	poolConstructingConnsDesc = prometheus.NewDesc("gophermart_db_pool_constructing_conns",
+		"Number of connections being opened.", nil, nil)
+	poolAcquiresDesc = prometheus.NewDesc("gophermart_db_pool_acquires_total",
+		"Number of successful acquires from the pool.", nil, nil)
+	poolAcquireDurationDesc = prometheus.NewDesc("gophermart_db_pool_acquire_duration_seconds_total",
+		"Total time spent on successful acquires.", nil, nil)
+	poolEmptyAcquiresDesc = prometheus.NewDesc("gophermart_db_pool_empty_acquires_total",
+		"Number of acquires that waited for a connection.", nil, nil)
+	poolCanceledAcquiresDesc = prometheus.NewDesc("gophermart_db_pool_canceled_acquires_total",
+		"Number of acquires cancelled by the context.", nil, nil)
+	poolNewConnsDesc = prometheus.NewDesc("gophermart_db_pool_new_conns_total",
+		"Number of connections opened.", nil, nil)
+)
+
+// CountOrdersByStatus returns the number of orders in each of statuses, missing statuses are zero.
+func (s *PostgresStore) CountOrdersByStatus(ctx context.Context, statuses ...Status) (map[Status]int64, error) {
+	names := make([]string, 0, len(statuses))
+	counts := make(map[Status]int64, len(statuses))
+	for _, status := range statuses {
+		names = append(names, string(status))
+		counts[status] = 0
+	}
+
+	query := `SELECT status, COUNT(*) FROM orders WHERE status = ANY($1::order_status[]) GROUP BY status`
+	rows, err := s.db.Query(ctx, query, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status Status
+		var count int64
+		if errRows := rows.Scan(&status, &count); errRows != nil {
+			return nil, errRows
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+func (s *MemoryStore) CountOrdersByStatus(ctx context.Context, statuses ...Status) (map[Status]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[Status]int64, len(statuses))
+	for _, status := range statuses {
+		counts[status] = 0
+	}
+	for _, record := range s.orders {
+		if _, ok := counts[record.Status]; ok {
+			counts[record.Status]++
+		}
+	}
+	return counts, nil
+}
+
+// Describe and Collect expose the connection pool and the backlog of the accrual worker.
+func (s *PostgresStore) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolMaxConnsDesc
+	ch <- poolTotalConnsDesc
+	ch <- poolAcquiredConnsDesc
+	ch <- poolIdleConnsDesc
+	ch <- poolConstructingConnsDesc
+	ch <- poolAcquiresDesc
+	ch <- poolAcquireDurationDesc
+	ch <- poolEmptyAcquiresDesc
+	ch <- poolCanceledAcquiresDesc
+	ch <- poolNewConnsDesc
+	ch <- pendingOrdersDesc
+}
+
+func (s *PostgresStore) Collect(ch chan<- prometheus.Metric) {
+	stat := s.db.Stat()
+	ch <- prometheus.MustNewConstMetric(poolMaxConnsDesc, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConnsDesc, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConnsDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConnsDesc, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolConstructingConnsDesc, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquiresDesc, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDurationDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquiresDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquiresDesc, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolNewConnsDesc, prometheus.CounterValue, float64(stat.NewConnsCount()))
+
+	collectPendingOrders(ch, s)
+}
+
+// Describe and Collect expose the backlog of the accrual worker.
+func (s *MemoryStore) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pendingOrdersDesc
+}
+
+func (s *MemoryStore) Collect(ch chan<- prometheus.Metric) {
+	collectPendingOrders(ch, s)
+}
+
+type orderCounter interface {
+	CountOrdersByStatus(ctx context.Context, statuses ...Status) (map[Status]int64, error)
+}
+
+// collectPendingOrders counts the backlog on scrape. A failed query drops the metric from this scrape only.
+func collectPendingOrders(ch chan<- prometheus.Metric, dataStore orderCounter) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	counts, err := dataStore.CountOrdersByStatus(ctx, pendingStatuses...)
+	if err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.CollectPendingOrders", "error": err}).Error("failed to count orders")
+		return
+	}
+
+	for _, status := range pendingStatuses {
+		ch <- prometheus.MustNewConstMetric(pendingOrdersDesc, prometheus.GaugeValue, float64(counts[status]), string(status))
+	}
+}
@@ -1,11 +1,15 @@
 package store
 
 import (
+	"TimBerk/gophermart/internal/app/metrics"
 	model "TimBerk/gophermart/internal/app/models/order"
+	"TimBerk/gophermart/pkg/money"
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5"
 	"github.com/sirupsen/logrus"
+	"time"
 )
 
 type Status string
@@ -15,7 +19,7 @@ type OrderRecord struct {
 	UserID  int64
 	Order   string
 	Status  Status
-	Accrual sql.NullFloat64
+	Accrual money.NullAmount
 }
 
 const (
@@ -26,8 +30,29 @@ const (
 	Undefined  Status = "UNDEFINED"
 )
 
+// transitions lists the allowed status changes, PROCESSED and INVALID are final.
+var transitions = map[Status][]Status{
+	New:        {Processing, Processed, Invalid},
+	Processing: {Processed, Invalid},
+}
+
+func (s Status) IsFinal() bool {
+	return s == Processed || s == Invalid
+}
+
+func CanTransition(from, to Status) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConstStatus maps a status of the accrual system, REGISTERED means the order is not processed yet.
 func GetConstStatus(status string) Status {
 	statusMap := map[string]Status{
+		"REGISTERED": New,
 		"NEW":        New,
 		"PROCESSING": Processing,
 		"INVALID":    Invalid,
@@ -41,17 +66,62 @@ func GetConstStatus(status string) Status {
 	return Undefined
 }
 
+// uploadAttempts bounds the retries of an upload which met an order committed after the statement started.
+const uploadAttempts = 3
+
+// UploadOrder inserts the order unless it exists and tells who owns it, in a single statement,
+// so parallel uploads of the same number never fail with a unique violation.
+func (s *PostgresStore) UploadOrder(ctx context.Context, userID int64, order string) (UploadResult, error) {
+	// The owner of a conflicting order is read by the same statement. An order committed by a parallel upload
+	// after the statement started is not visible to it, the next attempt with a new snapshot finds it.
+	query := `WITH inserted AS (
+			INSERT INTO orders (user_id, order_number) VALUES ($1, $2)
+			ON CONFLICT (order_number) DO NOTHING
+			RETURNING user_id
+		)
+		SELECT user_id, TRUE FROM inserted
+		UNION ALL
+		SELECT user_id, FALSE FROM orders WHERE order_number = $2 AND NOT EXISTS (SELECT 1 FROM inserted)`
+
+	var ownerID int64
+	var created bool
+	var err error
+	for attempt := 0; attempt < uploadAttempts; attempt++ {
+		err = s.db.QueryRow(ctx, query, userID, order).Scan(&ownerID, &created)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			break
+		}
+	}
+	if err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.UploadOrder", "user": userID, "order": order, "error": err}).Error("failed to upload order")
+		return "", dbError(err)
+	}
+
+	switch {
+	case created:
+		return UploadAccepted, nil
+	case ownerID == userID:
+		return UploadExisting, nil
+	default:
+		return UploadConflict, nil
+	}
+}
+
+// AddOrder uploads the order of the user. An order uploaded already gives ErrDuplicateOrder
+// for the same user and ErrOrderOwnedByOther for anybody else.
 func (s *PostgresStore) AddOrder(ctx context.Context, userID int64, order string) error {
-	query := `INSERT INTO orders (user_id, order_number) VALUES ($1, $2)`
-	_, err := s.db.Exec(ctx, query, userID, order)
-	return err
+	result, err := s.UploadOrder(ctx, userID, order)
+	if err != nil {
+		return err
+	}
+	return result.Err()
 }
 
 func (s *PostgresStore) GetOrder(ctx context.Context, order string) (OrderRecord, error) {
 	var record OrderRecord
 	query := `SELECT id, user_id, order_number, status, accrual FROM orders WHERE order_number = $1`
 	err := s.db.QueryRow(ctx, query, order).Scan(&record.ID, &record.UserID, &record.Order, &record.Status, &record.Accrual)
-	return record, err
+	return record, dbError(err)
 }
 
 func (s *PostgresStore) GetOrderList(ctx context.Context, userID int64) (model.OrderListResponse, error) {
@@ -66,32 +136,43 @@ func (s *PostgresStore) GetOrderList(ctx context.Context, userID int64) (model.O
 	for rows.Next() {
 		var record model.OrderResponse
 		if errRows := rows.Scan(&record.Number, &record.Status, &record.Accrual, &record.CreatedAt); errRows != nil {
-			logrus.WithFields(logrus.Fields{"action": "DB.GetOrderList", "user": userID, "error": errRows}).Error("failed to find order")
+			logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetOrderList", "user": userID, "error": errRows}).Error("failed to find order")
 			return nil, errRows
 		}
 		records = append(records, record)
 	}
 	if errRows := rows.Err(); errRows != nil {
-		logrus.WithFields(logrus.Fields{"action": "DB.GetOrderList", "user": userID, "error": errRows}).Error("failed to find orders")
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetOrderList", "user": userID, "error": errRows}).Error("failed to find orders")
 		return nil, errRows
 	}
 	return records, err
 }
 
-func (s *PostgresStore) AddWithdrawal(ctx context.Context, userID int64, order string, sum float64) error {
+// AddWithdrawal debits sum from the user balance only if it is sufficient, otherwise ErrInsufficientFunds
+// is returned. The balance row stays locked from the check until commit, so parallel calls are serialized.
+func (s *PostgresStore) AddWithdrawal(ctx context.Context, userID int64, order string, sum money.Amount) error {
 	tx, err := s.BeginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("begin transcation: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
+	var current money.Amount
+	err = tx.QueryRow(ctx, `SELECT current FROM balance WHERE user_id = $1 FOR UPDATE`, userID).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("lock user balance error: %w", err)
+	}
+	if current < sum {
+		return ErrInsufficientFunds
+	}
+
 	query := `INSERT INTO withdrawals (user_id, order_number, sum) VALUES ($1, $2, $3)`
 	_, err = tx.Exec(ctx, query, userID, order, sum)
 	if err != nil {
-		return fmt.Errorf("create withdrawals error: %w", err)
+		return fmt.Errorf("create withdrawals error: %w", dbError(err))
 	}
 
-	err = s.WithdrawBalance(ctx, tx, userID, sum)
+	err = s.AddLedgerEntry(ctx, tx, WithdrawalEntry(userID, order, sum))
 	if err != nil {
 		return fmt.Errorf("update user balance error: %w", err)
 	}
@@ -99,13 +180,25 @@ func (s *PostgresStore) AddWithdrawal(ctx context.Context, userID int64, order s
 	if err = tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	metrics.BalanceWithdrawn.Add(sum.Float64())
 
 	return err
 }
 
-func (s *PostgresStore) GetOrdersForAccrual(ctx context.Context) ([]model.UserOrder, error) {
-	query := `SELECT user_id, order_number, status FROM orders WHERE status in ('NEW', 'PROCESSING')`
-	rows, err := s.db.Query(ctx, query)
+// ClaimOrdersForAccrual leases up to limit pending orders to owner for the lease duration.
+// Orders leased by another owner are skipped until the lease expires or is released,
+// so several replicas can poll the accrual system without checking the same order.
+func (s *PostgresStore) ClaimOrdersForAccrual(ctx context.Context, owner string, limit int, lease time.Duration) ([]model.UserOrder, error) {
+	query := `UPDATE orders SET locked_by = $1, locked_until = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM orders
+			WHERE status IN ('NEW', 'PROCESSING') AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+			ORDER BY updated_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING user_id, order_number, status`
+	rows, err := s.db.Query(ctx, query, owner, lease.Seconds(), limit)
 	if err != nil {
 		return nil, err
 	}
@@ -115,30 +208,87 @@ func (s *PostgresStore) GetOrdersForAccrual(ctx context.Context) ([]model.UserOr
 	for rows.Next() {
 		var o model.UserOrder
 		if errRows := rows.Scan(&o.UserID, &o.Number, &o.Status); errRows != nil {
-			logrus.WithFields(logrus.Fields{"action": "DB.GetOrdersForAccrual", "error": errRows}).Error("failed to find order")
+			logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.ClaimOrdersForAccrual", "error": errRows}).Error("failed to find order")
 			return nil, errRows
 		}
 		orders = append(orders, o)
 	}
-	return orders, nil
+	return orders, rows.Err()
 }
 
-func (s *PostgresStore) UpdateOrderStatus(ctx context.Context, userID int64, order string, status Status, accrual float64) error {
+// RenewOrderLeases extends the leases still held by owner and returns the numbers of their orders.
+// A lease that expired and was claimed by another owner is lost, its order is missing from the result.
+func (s *PostgresStore) RenewOrderLeases(ctx context.Context, owner string, lease time.Duration) ([]string, error) {
+	query := `UPDATE orders SET locked_until = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE locked_by = $1 AND status IN ('NEW', 'PROCESSING')
+		RETURNING order_number`
+	rows, err := s.db.Query(ctx, query, owner, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []string
+	for rows.Next() {
+		var number string
+		if errRows := rows.Scan(&number); errRows != nil {
+			return nil, errRows
+		}
+		orders = append(orders, number)
+	}
+	return orders, rows.Err()
+}
+
+// ReleaseOrders drops all leases held by owner, unfinished orders become available to every replica again.
+func (s *PostgresStore) ReleaseOrders(ctx context.Context, owner string) error {
+	query := `UPDATE orders SET locked_by = NULL, locked_until = NULL WHERE locked_by = $1`
+	_, err := s.db.Exec(ctx, query, owner)
+	return err
+}
+
+// UpdateOrderStatus moves the order to status if the transition is allowed, otherwise StatusTransitionError
+// is returned. Repeating a non-final status is a no-op. The accrual is credited only on the move to PROCESSED,
+// the status is compared and set in the same transaction, so it happens once per order.
+func (s *PostgresStore) UpdateOrderStatus(ctx context.Context, userID int64, order string, status Status, accrual money.Amount) error {
 	tx, err := s.BeginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("begin transcation: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	query := `UPDATE orders SET status = $1, accrual = $2 WHERE order_number = $3`
-	_, err = s.db.Exec(ctx, query, status, accrual, order)
+	var current Status
+	query := `SELECT status FROM orders WHERE order_number = $1 AND user_id = $2 FOR UPDATE`
+	err = tx.QueryRow(ctx, query, order, userID).Scan(&current)
 	if err != nil {
-		return fmt.Errorf("update order status with accrual error: %w", err)
+		return fmt.Errorf("lock order error: %w", dbError(err))
+	}
+	if current == status && !current.IsFinal() {
+		return nil
+	}
+	if !CanTransition(current, status) {
+		return &StatusTransitionError{Order: order, From: current, To: status}
+	}
+
+	var orderAccrual money.NullAmount
+	if status == Processed {
+		orderAccrual = money.NewNullAmount(accrual)
 	}
 
-	err = s.AddBalance(ctx, tx, userID, accrual)
+	query = `UPDATE orders SET status = $1, accrual = $2, updated_at = CURRENT_TIMESTAMP, locked_by = NULL, locked_until = NULL
+		WHERE order_number = $3 AND status = $4`
+	tag, err := tx.Exec(ctx, query, status, orderAccrual, order, current)
 	if err != nil {
-		return fmt.Errorf("update user balance error: %w", err)
+		return fmt.Errorf("update order status with accrual error: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return &StatusTransitionError{Order: order, From: current, To: status}
+	}
+
+	if status == Processed {
+		err = s.AddLedgerEntry(ctx, tx, AccrualEntry(userID, order, accrual))
+		if err != nil {
+			return fmt.Errorf("update user balance error: %w", err)
+		}
 	}
 
 	if err = tx.Commit(ctx); err != nil {
@@ -0,0 +1,167 @@
+package store
+
+import (
+	model "TimBerk/gophermart/internal/app/models/order"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last record of a page ordered by creation time and id, newest first.
+// The next page starts right after it, so records added meanwhile don't shift pages.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// String encodes the cursor as an opaque URL-safe token.
+func (c Cursor) String() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixMicro(), 10) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseCursor(value string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	micros, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return Cursor{}, ErrInvalidCursor
+	}
+	createdAt, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	cursor := Cursor{CreatedAt: time.UnixMicro(createdAt).UTC()}
+	if cursor.ID, err = strconv.ParseInt(id, 10, 64); err != nil || cursor.ID <= 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// before reports whether a record goes after the cursor in the newest first order.
+func (c Cursor) before(createdAt time.Time, id int64) bool {
+	return createdAt.Before(c.CreatedAt) || (createdAt.Equal(c.CreatedAt) && id < c.ID)
+}
+
+// PageFilter selects a page of user records by their creation time. From is inclusive, To is exclusive,
+// zero values are not applied.
+type PageFilter struct {
+	From  time.Time
+	To    time.Time
+	After *Cursor
+	Limit int
+}
+
+func (f PageFilter) matches(createdAt time.Time, id int64) bool {
+	if !f.From.IsZero() && createdAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !createdAt.Before(f.To) {
+		return false
+	}
+	return f.After == nil || f.After.before(createdAt, id)
+}
+
+// conditions adds the time range and the cursor to a query, the page is taken by limit.
+func (f PageFilter) conditions(query string, args []any) (string, []any) {
+	if !f.From.IsZero() {
+		args = append(args, f.From.UTC())
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if !f.To.IsZero() {
+		args = append(args, f.To.UTC())
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	if f.After != nil {
+		args = append(args, f.After.CreatedAt, f.After.ID)
+		query += fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+	return query, args
+}
+
+// limit orders the page newest first and fetches one extra record to find out whether there is a next page.
+func (f PageFilter) limit(query string, args []any) (string, []any) {
+	args = append(args, f.Limit+1)
+	return query + fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args)), args
+}
+
+// OrderFilter selects a page of user orders, an empty status list is not applied.
+type OrderFilter struct {
+	PageFilter
+	Statuses []Status
+}
+
+func (f OrderFilter) matches(record *memoryOrder) bool {
+	if len(f.Statuses) > 0 && !containsStatus(f.Statuses, record.Status) {
+		return false
+	}
+	return f.PageFilter.matches(record.createdAt, record.ID)
+}
+
+func containsStatus(statuses []Status, status Status) bool {
+	for _, item := range statuses {
+		if item == status {
+			return true
+		}
+	}
+	return false
+}
+
+// GetOrderPage returns up to filter.Limit orders of the user, newest first, and the cursor
+// of the next page, which is nil on the last page.
+func (s *PostgresStore) GetOrderPage(ctx context.Context, userID int64, filter OrderFilter) (model.OrderListResponse, *Cursor, error) {
+	query := `SELECT id, order_number, status, accrual, created_at FROM orders WHERE user_id = $1`
+	args := []any{userID}
+
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, 0, len(filter.Statuses))
+		for _, status := range filter.Statuses {
+			statuses = append(statuses, string(status))
+		}
+		args = append(args, statuses)
+		query += fmt.Sprintf(" AND status = ANY($%d::order_status[])", len(args))
+	}
+	query, args = filter.conditions(query, args)
+	query, args = filter.limit(query, args)
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var records model.OrderListResponse
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var record model.OrderResponse
+		if errRows := rows.Scan(&id, &record.Number, &record.Status, &record.Accrual, &record.CreatedAt); errRows != nil {
+			logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetOrderPage", "user": userID, "error": errRows}).Error("failed to find order")
+			return nil, nil, errRows
+		}
+		records = append(records, record)
+		ids = append(ids, id)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.GetOrderPage", "user": userID, "error": errRows}).Error("failed to find orders")
+		return nil, nil, errRows
+	}
+
+	if len(records) <= filter.Limit {
+		return records, nil, nil
+	}
+	records = records[:filter.Limit]
+	last := records[len(records)-1]
+	return records, &Cursor{CreatedAt: last.CreatedAt, ID: ids[len(records)-1]}, nil
+}
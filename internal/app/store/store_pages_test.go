@@ -0,0 +1,121 @@
+package store
+
+import (
+	"TimBerk/gophermart/pkg/money"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Date(2025, 4, 1, 10, 30, 0, 123456000, time.UTC), ID: 42}
+
+	parsed, err := ParseCursor(cursor.String())
+	require.NoError(t, err)
+	assert.Equal(t, cursor, parsed)
+
+	for _, value := range []string{"", "!!!", "MTIz", "YWJjOjE", "MTIzOjA", "MTIzOmFiYw"} {
+		_, err = ParseCursor(value)
+		assert.ErrorIs(t, err, ErrInvalidCursor, value)
+	}
+}
+
+func TestMemoryStoreOrderPage(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	otherID, _ := s.AddUser(ctx, "other", "hash")
+
+	numbers := []string{"12345678903", "49927398716", "79927398713", "2377225624", "4532015112830366"}
+	for _, number := range numbers {
+		require.NoError(t, s.AddOrder(ctx, userID, number))
+	}
+	require.NoError(t, s.AddOrder(ctx, otherID, "1234567812345670"))
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, numbers[1], Processed, money.FromUnits(10)))
+	require.NoError(t, s.UpdateOrderStatus(ctx, userID, numbers[3], Invalid, 0))
+
+	// Orders are created within the same microsecond, ids keep the order stable.
+	var seen []string
+	filter := OrderFilter{PageFilter: PageFilter{Limit: 2}}
+	for page := 0; page < 5; page++ {
+		records, next, err := s.GetOrderPage(ctx, userID, filter)
+		require.NoError(t, err)
+		for _, record := range records {
+			seen = append(seen, record.Number)
+		}
+		if next == nil {
+			break
+		}
+		cursor, err := ParseCursor(next.String())
+		require.NoError(t, err)
+		filter.After = &cursor
+	}
+	assert.Equal(t, []string{numbers[4], numbers[3], numbers[2], numbers[1], numbers[0]}, seen)
+
+	records, next, err := s.GetOrderPage(ctx, userID, OrderFilter{Statuses: []Status{Processed, Invalid}, PageFilter: PageFilter{Limit: 10}})
+	require.NoError(t, err)
+	assert.Nil(t, next)
+	require.Len(t, records, 2)
+	assert.Equal(t, numbers[3], records[0].Number)
+	assert.Equal(t, numbers[1], records[1].Number)
+
+	records, _, err = s.GetOrderPage(ctx, userID, OrderFilter{PageFilter: PageFilter{From: time.Now().Add(time.Hour), Limit: 10}})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	records, _, err = s.GetOrderPage(ctx, userID, OrderFilter{PageFilter: PageFilter{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour), Limit: 10}})
+	require.NoError(t, err)
+	assert.Len(t, records, 5)
+}
+
+func TestMemoryStoreWithdrawalPage(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	userID, _ := s.AddUser(ctx, "user", "hash")
+	otherID, _ := s.AddUser(ctx, "other", "hash")
+
+	tx, err := s.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.AddBalance(ctx, tx, userID, money.FromUnits(100)))
+	require.NoError(t, s.AddBalance(ctx, tx, otherID, money.FromUnits(100)))
+	require.NoError(t, tx.Commit(ctx))
+
+	numbers := []string{"12345678903", "49927398716", "79927398713"}
+	for i, number := range numbers {
+		require.NoError(t, s.AddWithdrawal(ctx, userID, number, money.FromUnits(int64(i+1)*10)))
+	}
+	require.NoError(t, s.AddWithdrawal(ctx, otherID, "2377225624", money.FromUnits(50)))
+
+	var seen []string
+	filter := PageFilter{Limit: 2}
+	for page := 0; page < 3; page++ {
+		records, next, errPage := s.GetWithdrawalPage(ctx, userID, filter)
+		require.NoError(t, errPage)
+		for _, record := range records {
+			seen = append(seen, record.Number)
+		}
+		if next == nil {
+			break
+		}
+		filter.After = next
+	}
+	assert.Equal(t, []string{numbers[2], numbers[1], numbers[0]}, seen)
+
+	summary, err := s.GetWithdrawalSummary(ctx, userID, PageFilter{After: filter.After, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), summary.Count, "cursor and limit don't narrow the summary")
+	assert.Equal(t, money.FromUnits(60), summary.Sum)
+
+	summary, err = s.GetWithdrawalSummary(ctx, userID, PageFilter{From: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Zero(t, summary.Count)
+	assert.Zero(t, summary.Sum)
+
+	records, next, err := s.GetWithdrawalPage(ctx, userID, PageFilter{To: time.Now().Add(-time.Hour), Limit: 10})
+	require.NoError(t, err)
+	assert.Nil(t, next)
+	assert.Empty(t, records)
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"TimBerk/gophermart/internal/app/metrics"
+	"TimBerk/gophermart/pkg/money"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMockPostgresStore runs the queries of PostgresStore against a mock that expects them in order.
+func newMockPostgresStore(t *testing.T) (*PostgresStore, pgxmock.PgxPoolIface) {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+	return &PostgresStore{db: mock}, mock
+}
+
+// matchSQL matches a query by a fragment of its text.
+func matchSQL(fragment string) string {
+	return regexp.QuoteMeta(fragment)
+}
+
+func TestPostgresStoreDeleteUser(t *testing.T) {
+	const userID = int64(42)
+
+	tests := []struct {
+		name        string
+		anonymize   error
+		expectedErr error
+	}{
+		// The former placeholder login deleted-42 may be registered, NULL doesn't collide with it.
+		{name: "placeholder login is taken"},
+		{
+			name:        "unique violation is translated",
+			anonymize:   &pgconn.PgError{Code: uniqueViolationCode, ConstraintName: "users_username_key"},
+			expectedErr: ErrDuplicateUser,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockPostgresStore(t)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(matchSQL(`SELECT username FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`)).
+				WithArgs(userID).
+				WillReturnRows(pgxmock.NewRows([]string{"username"}).AddRow("user"))
+			mock.ExpectQuery(matchSQL(`SELECT EXISTS (SELECT 1 FROM balance`)).
+				WithArgs(userID).
+				WillReturnRows(pgxmock.NewRows([]string{"balance", "pending"}).AddRow(false, false))
+			mock.ExpectExec(matchSQL(`UPDATE orders SET status = 'INVALID'`)).
+				WithArgs(userID).
+				WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+			anonymize := mock.ExpectExec(matchSQL(`UPDATE users SET username = NULL, password_hash = ''`)).WithArgs(userID)
+			if tt.anonymize != nil {
+				anonymize.WillReturnError(tt.anonymize)
+				mock.ExpectRollback()
+			} else {
+				anonymize.WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				mock.ExpectExec(matchSQL(`DELETE FROM refresh_tokens`)).WithArgs(userID).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+				mock.ExpectExec(matchSQL(`DELETE FROM idempotency_keys`)).WithArgs(userID).WillReturnResult(pgxmock.NewResult("DELETE", 0))
+				mock.ExpectExec(matchSQL(`DELETE FROM login_throttles`)).WithArgs(LoginScopeUser, "user").WillReturnResult(pgxmock.NewResult("DELETE", 0))
+				mock.ExpectCommit()
+			}
+
+			err := s.DeleteUser(context.Background(), userID, false)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPostgresStoreChangePassword(t *testing.T) {
+	const userID = int64(42)
+	s, mock := newMockPostgresStore(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(matchSQL(`session_generation = session_generation + 1`)).
+		WithArgs(userID, "new-hash").
+		WillReturnRows(pgxmock.NewRows([]string{"session_generation"}).AddRow(int64(3)))
+	mock.ExpectExec(matchSQL(`UPDATE refresh_tokens SET revoked_at`)).WithArgs(userID).WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	mock.ExpectCommit()
+	// A token issued just before the change carries the previous generation.
+	mock.ExpectQuery(matchSQL(`session_generation > $3`)).
+		WithArgs("old", userID, int64(2)).
+		WillReturnRows(pgxmock.NewRows([]string{"revoked"}).AddRow(true))
+
+	generation, err := s.ChangePassword(context.Background(), userID, "new-hash")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), generation)
+
+	revoked, err := s.IsAccessTokenRevoked(context.Background(), userID, "old", generation-1)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStoreAddWithdrawal(t *testing.T) {
+	const (
+		userID = int64(42)
+		order  = "2377225624"
+	)
+	sum := money.FromUnits(500)
+
+	tests := []struct {
+		name        string
+		current     money.Amount
+		ledger      error
+		expectedErr error
+	}{
+		{name: "sufficient balance", current: money.FromUnits(700)},
+		{name: "insufficient locked balance", current: money.FromUnits(300), expectedErr: ErrInsufficientFunds},
+		{
+			// The balance went below zero between the check and the ledger trigger.
+			name:        "check constraint is translated",
+			current:     money.FromUnits(700),
+			ledger:      &pgconn.PgError{Code: "23514", ConstraintName: "balance_current_non_negative"},
+			expectedErr: ErrInsufficientFunds,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockPostgresStore(t)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(matchSQL(`SELECT current FROM balance WHERE user_id = $1 FOR UPDATE`)).
+				WithArgs(userID).
+				WillReturnRows(pgxmock.NewRows([]string{"current"}).AddRow(tt.current))
+			if tt.current >= sum {
+				mock.ExpectExec(matchSQL(`INSERT INTO withdrawals`)).
+					WithArgs(userID, order, sum).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				ledger := mock.ExpectExec(matchSQL(`INSERT INTO ledger_entries`)).
+					WithArgs(userID, LedgerWithdrawal, AccountCurrent, AccountWithdrawn, sum, pgxmock.AnyArg(), "")
+				if tt.ledger != nil {
+					ledger.WillReturnError(tt.ledger)
+				} else {
+					ledger.WillReturnResult(pgxmock.NewResult("INSERT", 1))
+					mock.ExpectCommit()
+				}
+			}
+			if tt.expectedErr != nil {
+				mock.ExpectRollback()
+			}
+
+			withdrawn := testutil.ToFloat64(metrics.BalanceWithdrawn)
+			err := s.AddWithdrawal(context.Background(), userID, order, sum)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Equal(t, withdrawn, testutil.ToFloat64(metrics.BalanceWithdrawn), "failed withdrawals are not counted")
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, withdrawn+sum.Float64(), testutil.ToFloat64(metrics.BalanceWithdrawn))
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPostgresStoreCountOrdersByStatus(t *testing.T) {
+	s, mock := newMockPostgresStore(t)
+
+	// The statuses are sent as text, the order_status enum has no implicit cast from text[].
+	mock.ExpectQuery(matchSQL(`WHERE status = ANY($1::order_status[]) GROUP BY status`)).
+		WithArgs([]string{"NEW", "PROCESSING"}).
+		WillReturnRows(pgxmock.NewRows([]string{"status", "count"}).AddRow(Processing, int64(3)))
+
+	counts, err := s.CountOrdersByStatus(context.Background(), New, Processing)
+	require.NoError(t, err)
+	assert.Equal(t, map[Status]int64{New: 0, Processing: 3}, counts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStorePurgeIdempotencyKeys(t *testing.T) {
+	s, mock := newMockPostgresStore(t)
+
+	mock.ExpectExec(matchSQL(`DELETE FROM idempotency_keys WHERE created_at < CURRENT_TIMESTAMP - make_interval(secs => $1)`)).
+		WithArgs(float64(3600)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 2))
+
+	purged, err := s.PurgeIdempotencyKeys(context.Background(), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// RefreshTokenRecord describes a stored refresh token, the token itself is never stored, only its hash.
+type RefreshTokenRecord struct {
+	UserID            int64
+	Username          string
+	SessionGeneration int64
+	FamilyID          string
+	TokenHash         string
+}
+
+func (s *PostgresStore) AddRefreshToken(ctx context.Context, userID int64, familyID string, tokenHash string, ttl time.Duration) error {
+	query := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))`
+	_, err := s.db.Exec(ctx, query, userID, familyID, tokenHash, ttl.Seconds())
+	if err != nil {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.AddRefreshToken", "user": userID, "error": err}).Error("failed to add token")
+	}
+	return err
+}
+
+// RotateRefreshToken marks the token as used and stores newHash in the same family.
+// A used token means it was stolen or replayed, then the whole family is revoked and ErrRefreshTokenReused is returned.
+// Unknown, revoked and expired tokens give ErrRefreshTokenInvalid.
+func (s *PostgresStore) RotateRefreshToken(ctx context.Context, tokenHash string, newHash string, ttl time.Duration) (RefreshTokenRecord, error) {
+	record := RefreshTokenRecord{TokenHash: newHash}
+
+	tx, err := s.BeginTx(ctx)
+	if err != nil {
+		return record, fmt.Errorf("begin transcation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	var used, revoked, expired bool
+	query := `SELECT r.id, r.user_id, u.username, u.session_generation, r.family_id,
+			r.used_at IS NOT NULL, r.revoked_at IS NOT NULL, r.expires_at < CURRENT_TIMESTAMP
+		FROM refresh_tokens r JOIN users u ON u.id = r.user_id
+		WHERE r.token_hash = $1
+		FOR UPDATE OF r`
+	err = tx.QueryRow(ctx, query, tokenHash).Scan(&id, &record.UserID, &record.Username, &record.SessionGeneration, &record.FamilyID, &used, &revoked, &expired)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return record, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return record, fmt.Errorf("lock refresh token error: %w", err)
+	}
+
+	switch {
+	case revoked || expired:
+		return record, ErrRefreshTokenInvalid
+	case used:
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "DB.RotateRefreshToken", "user": record.UserID}).Warning("refresh token reuse, revoke family")
+		_, err = tx.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL`, record.FamilyID)
+		if err != nil {
+			return record, fmt.Errorf("revoke token family error: %w", err)
+		}
+		if err = tx.Commit(ctx); err != nil {
+			return record, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return record, ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	if err != nil {
+		return record, fmt.Errorf("use refresh token error: %w", err)
+	}
+
+	query = `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))`
+	_, err = tx.Exec(ctx, query, record.UserID, record.FamilyID, newHash, ttl.Seconds())
+	if err != nil {
+		return record, fmt.Errorf("add refresh token error: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return record, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return record, nil
+}
+
+// RevokeRefreshFamily revokes every token of the family the given token belongs to.
+func (s *PostgresStore) RevokeRefreshFamily(ctx context.Context, tokenHash string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE revoked_at IS NULL AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)`
+	_, err := s.db.Exec(ctx, query, tokenHash)
+	return err
+}
+
+// RevokeAccessToken keeps the token id in the revocation list for ttl, after that the token is expired anyway.
+func (s *PostgresStore) RevokeAccessToken(ctx context.Context, jti string, ttl time.Duration) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, CURRENT_TIMESTAMP + make_interval(secs => $2))
+		ON CONFLICT (jti) DO NOTHING`
+	_, err = s.db.Exec(ctx, query, jti, ttl.Seconds())
+	return err
+}
+
+// IsAccessTokenRevoked reports tokens revoked by id, tokens of deleted users and tokens of an older
+// session generation, i.e. issued before the user revoked all sessions. Generations are compared
+// instead of times, so the result doesn't depend on clocks or on the second precision of iat.
+func (s *PostgresStore) IsAccessTokenRevoked(ctx context.Context, userID int64, jti string, generation int64) (bool, error) {
+	var revoked bool
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		OR EXISTS (
+			SELECT 1 FROM users
+			WHERE id = $2 AND (deleted_at IS NOT NULL OR session_generation > $3)
+		)`
+	err := s.db.QueryRow(ctx, query, jti, userID, generation).Scan(&revoked)
+	return revoked, err
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// Middleware starts a server span for each request, continuing the trace of an incoming traceparent header.
+// The span is renamed after the chi route pattern once routing is done, the path itself is not used
+// so order numbers don't end up in span names. A handler that panics, like an export aborting
+// a started response, marks the span as failed before the panic goes on.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method)),
+		)
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			if rec := recover(); rec != nil {
+				finishSpan(ctx, span, r.Method, http.StatusInternalServerError)
+				span.SetStatus(codes.Error, "request aborted")
+				panic(rec)
+			}
+		}()
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		finishSpan(ctx, span, r.Method, status)
+	})
+}
+
+func finishSpan(ctx context.Context, span trace.Span, method string, status int) {
+	if routeCtx := chi.RouteContext(ctx); routeCtx != nil {
+		if pattern := routeCtx.RoutePattern(); pattern != "" {
+			span.SetName(method + " " + pattern)
+			span.SetAttributes(semconv.HTTPRoute(pattern))
+		}
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
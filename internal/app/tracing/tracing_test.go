@@ -0,0 +1,128 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func initRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	values := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		values[kv.Key] = kv.Value
+	}
+	return values
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := initRecorder(t)
+
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/api/user/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders/12345678903", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /api/user/orders/{number}", span.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, int64(http.StatusInternalServerError), attributes(span)["http.response.status_code"].AsInt64())
+	assert.Equal(t, codes.Error, span.Status().Code)
+}
+
+func TestMiddlewareAbortedHandler(t *testing.T) {
+	recorder := initRecorder(t)
+
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/api/user/orders/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic(http.ErrAbortHandler)
+	})
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/user/orders/export", nil))
+	})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /api/user/orders/export", span.Name())
+	assert.Equal(t, int64(http.StatusInternalServerError), attributes(span)["http.response.status_code"].AsInt64())
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Equal(t, "request aborted", span.Status().Description)
+}
+
+func TestQueryTracer(t *testing.T) {
+	recorder := initRecorder(t)
+	tracer := QueryTracer{}
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{
+		SQL:  "SELECT id, password FROM users\n\t\tWHERE login = $1",
+		Args: []any{"alice"},
+	})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("connection reset")})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "db SELECT", span.Name())
+	assert.Equal(t, "SELECT id, password FROM users WHERE login = $1", attributes(span)["db.query.text"].AsString())
+	for _, kv := range span.Attributes() {
+		assert.NotContains(t, kv.Value.Emit(), "alice", "argument values must not be recorded")
+	}
+	assert.Equal(t, codes.Error, span.Status().Code)
+}
+
+func TestLogHook(t *testing.T) {
+	initRecorder(t)
+
+	var out bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&out)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.AddHook(LogHook{})
+
+	ctx, span := Tracer().Start(context.Background(), "test")
+	log.WithContext(ctx).Info("with span")
+	span.End()
+	assert.Contains(t, out.String(), `"trace_id":"`+span.SpanContext().TraceID().String()+`"`)
+	assert.Contains(t, out.String(), `"span_id":"`+span.SpanContext().SpanID().String()+`"`)
+
+	out.Reset()
+	log.WithContext(context.Background()).Info("without span")
+	log.Info("without context")
+	assert.NotContains(t, out.String(), "trace_id")
+}
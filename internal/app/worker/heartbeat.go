@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNotStarted = errors.New("accrual worker has not started")
+	ErrStalled    = errors.New("accrual worker is stalled")
+)
+
+// Heartbeat is the time the pool last made progress, it is read by the readiness probe.
+type Heartbeat struct {
+	last atomic.Int64
+}
+
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// Last returns the time of the last beat, zero before the first one.
+func (h *Heartbeat) Last() time.Time {
+	last := h.last.Load()
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+// Check fails when the pool hasn't made progress for longer than maxAge.
+func (h *Heartbeat) Check(maxAge time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		last := h.Last()
+		if last.IsZero() {
+			return ErrNotStarted
+		}
+		if age := time.Since(last); age > maxAge {
+			return fmt.Errorf("%w: last beat %s ago", ErrStalled, age.Round(time.Second))
+		}
+		return nil
+	}
+}
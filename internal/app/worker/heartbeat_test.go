@@ -0,0 +1,23 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeatCheck(t *testing.T) {
+	ctx := context.Background()
+	heartbeat := &Heartbeat{}
+	check := heartbeat.Check(50 * time.Millisecond)
+
+	assert.ErrorIs(t, check(ctx), ErrNotStarted)
+
+	heartbeat.Beat()
+	assert.NoError(t, check(ctx))
+
+	heartbeat.last.Store(time.Now().Add(-time.Minute).UnixNano())
+	assert.ErrorIs(t, check(ctx), ErrStalled)
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter is a rate limit shared by all workers of the pool. Pause blocks every
+// worker at once, it is used when the accrual system asks to slow down.
+type Limiter struct {
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// NewLimiter allows rate requests per second with bursts of up to burst requests.
+// A non-positive rate disables limiting, only pauses are applied then.
+func NewLimiter(rps int, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	limit := rate.Inf
+	if rps > 0 {
+		limit = rate.Limit(rps)
+	}
+	return &Limiter{limiter: rate.NewLimiter(limit, burst)}
+}
+
+// Wait blocks until the pause is over and a request is allowed, or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	// A pause may be extended while waiting, so it is checked again after each wait.
+	for delay := l.pauseLeft(); delay > 0; delay = l.pauseLeft() {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// Pause stops allowing requests for d. Overlapping pauses are merged, the longest one wins.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// pauseLeft returns how long the current pause lasts, zero without one.
+func (l *Limiter) pauseLeft() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return time.Until(l.pausedUntil)
+}
@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterPause(t *testing.T) {
+	limiter := NewLimiter(0, 1)
+	limiter.Pause(30 * time.Millisecond)
+	limiter.Pause(time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, limiter.Wait(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 25*time.Millisecond, "shorter pause must not cut the longer one")
+	assert.LessOrEqual(t, limiter.pauseLeft(), time.Duration(0))
+}
+
+func TestLimiterWaitCancelled(t *testing.T) {
+	limiter := NewLimiter(1, 1)
+	limiter.Pause(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, limiter.Wait(ctx), context.DeadlineExceeded)
+}
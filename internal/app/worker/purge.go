@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"TimBerk/gophermart/internal/app/settings/config"
+	"context"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const purgeInterval = 10 * time.Minute
+
+// IdempotencyStore drops stored results of requests that are too old to be replayed.
+type IdempotencyStore interface {
+	PurgeIdempotencyKeys(ctx context.Context, ttl time.Duration) (int64, error)
+}
+
+// PurgeIdempotencyKeys deletes expired idempotency keys on start and then every purgeInterval
+// until ctx is cancelled. A failed purge is only logged, the next tick retries it.
+func PurgeIdempotencyKeys(ctx context.Context, cfg *config.Config, dataStore IdempotencyStore, wg *sync.WaitGroup) {
+	defer wg.Done()
+	runPurge(ctx, dataStore, time.Duration(cfg.IdempotencyTTL)*time.Minute, purgeInterval)
+}
+
+func runPurge(ctx context.Context, dataStore IdempotencyStore, ttl time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logFields := logrus.WithFields(logrus.Fields{"action": "W.PurgeIdempotencyKeys"})
+	for {
+		purged, err := dataStore.PurgeIdempotencyKeys(ctx, ttl)
+		if err != nil && ctx.Err() == nil {
+			logFields.WithField("error", err).Error("failed to purge idempotency keys")
+		}
+		if purged > 0 {
+			logFields.WithField("purged", purged).Info("expired idempotency keys purged")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
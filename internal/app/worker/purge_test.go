@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type purgeStub struct {
+	mu    sync.Mutex
+	calls []time.Duration
+	done  chan struct{}
+}
+
+func (s *purgeStub) PurgeIdempotencyKeys(ctx context.Context, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, ttl)
+	if len(s.calls) == 3 {
+		close(s.done)
+	}
+	if len(s.calls) == 1 {
+		return 0, errors.New("db error")
+	}
+	return 1, nil
+}
+
+func TestRunPurge(t *testing.T) {
+	logrus.SetLevel(logrus.PanicLevel)
+	ctx, cancel := context.WithCancel(context.Background())
+	dataStore := &purgeStub{done: make(chan struct{})}
+
+	stopped := make(chan struct{})
+	go func() {
+		runPurge(ctx, dataStore, time.Hour, 10*time.Millisecond)
+		close(stopped)
+	}()
+
+	select {
+	case <-dataStore.done:
+	case <-time.After(time.Second):
+		t.Fatal("keys are not purged on every tick")
+	}
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("purge is not stopped by the context")
+	}
+
+	dataStore.mu.Lock()
+	defer dataStore.mu.Unlock()
+	for _, ttl := range dataStore.calls {
+		assert.Equal(t, time.Hour, ttl)
+	}
+}
@@ -2,101 +2,311 @@ package worker
 
 import (
 	"TimBerk/gophermart/internal/app/client"
+	"TimBerk/gophermart/internal/app/metrics"
 	model "TimBerk/gophermart/internal/app/models/order"
 	"TimBerk/gophermart/internal/app/settings/config"
 	"TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/internal/app/tracing"
+	"TimBerk/gophermart/pkg/money"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"os"
 	"sync"
 	"time"
 )
 
-type APIError struct {
-	Message    string
-	RetryAfter time.Duration
-	StatusCode int
-}
+const (
+	pollInterval  = 2 * time.Second
+	claimLimit    = 100
+	leaseDuration = 5 * time.Minute
+)
 
-func (e *APIError) Error() string {
-	return e.Message
+// StatusStore stores results of the accrual system, it is all the callback endpoint needs.
+type StatusStore interface {
+	UpdateOrderStatus(ctx context.Context, userID int64, order string, status store.Status, accrual money.Amount) error
 }
 
 type OrderStore interface {
-	GetOrdersForAccrual(ctx context.Context) ([]model.UserOrder, error)
-	UpdateOrderStatus(ctx context.Context, userID int64, order string, status store.Status, accrual float64) error
+	StatusStore
+	ClaimOrdersForAccrual(ctx context.Context, owner string, limit int, lease time.Duration) ([]model.UserOrder, error)
+	RenewOrderLeases(ctx context.Context, owner string, lease time.Duration) ([]string, error)
+	ReleaseOrders(ctx context.Context, owner string) error
+}
+
+var ErrMissingAccrual = errors.New("processed order without accrual")
+
+type CheckFunc func(ctx context.Context, order string) (*model.OrderAccrual, error)
+
+// Pool polls the accrual system for pending orders with a fixed number of workers.
+// All workers share one limiter, so a 429 answer seen by any of them pauses the whole pool.
+// Orders are leased in the store under the pool owner, so pools of other replicas skip them.
+// Leases are renewed while the batch is in work, an order whose lease is lost is not checked.
+type Pool struct {
+	owner     string
+	workers   int
+	dataStore OrderStore
+	limiter   *Limiter
+	check     CheckFunc
+	interval  time.Duration
+	limit     int
+	lease     time.Duration
+	heartbeat *Heartbeat
+
+	leaseMu     sync.Mutex
+	leased      map[string]struct{}
+	leasedUntil time.Time
 }
 
-func preparedOrders(
-	ctx context.Context,
-	cfg *config.Config,
-	dataStore OrderStore,
-	action string,
-	checkOrderStatus func(string, model.UserOrder) (*model.OrderAccrual, error),
-) {
-	logFields := logrus.WithFields(logrus.Fields{"action": action})
+func NewPool(owner string, workers int, dataStore OrderStore, limiter *Limiter, check CheckFunc) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		owner:     owner,
+		workers:   workers,
+		dataStore: dataStore,
+		limiter:   limiter,
+		check:     check,
+		interval:  pollInterval,
+		limit:     claimLimit,
+		lease:     leaseDuration,
+		heartbeat: &Heartbeat{},
+	}
+}
+
+// Run fetches pending orders and hands them to the workers until ctx is cancelled.
+// The next batch is fetched only when the previous one is finished, so an order is never checked twice at once.
+func (p *Pool) Run(ctx context.Context) {
+	jobs := make(chan model.UserOrder)
+	var pending, workers sync.WaitGroup
+
+	for i := 0; i < p.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for order := range jobs {
+				p.processOrder(ctx, order)
+				p.heartbeat.Beat()
+				pending.Done()
+			}
+		}()
+	}
+
+	defer func() {
+		close(jobs)
+		workers.Wait()
+	}()
+
+	for {
+		p.heartbeat.Beat()
+		stopRenewal := p.keepLeases(ctx)
+		p.dispatch(ctx, jobs, &pending)
+		pending.Wait()
+		stopRenewal()
+
+		// Orders that were not finished in this round are given back to all replicas.
+		if err := p.dataStore.ReleaseOrders(context.WithoutCancel(ctx), p.owner); err != nil {
+			logrus.WithFields(logrus.Fields{"action": "W.Run", "owner": p.owner, "error": err}).Error("failed to release orders")
+		}
 
-	orders, err := dataStore.GetOrdersForAccrual(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.interval):
+		}
+	}
+}
+
+func (p *Pool) dispatch(ctx context.Context, jobs chan<- model.UserOrder, pending *sync.WaitGroup) {
+	logFields := logrus.WithFields(logrus.Fields{"action": "W.Dispatch", "owner": p.owner})
+
+	start := time.Now()
+	orders, err := p.dataStore.ClaimOrdersForAccrual(ctx, p.owner, p.limit, p.lease)
 	if err != nil {
 		logFields.WithField("error", err).Error("failed to get list orders")
 		return
 	}
+	numbers := make([]string, 0, len(orders))
+	for _, order := range orders {
+		numbers = append(numbers, order.Number)
+	}
+	p.setLeases(numbers, start, false)
 
 	logFields.WithField("count", len(orders)).Info("started work with orders")
 
 	for _, order := range orders {
-		var newStatus store.Status
-
-		respData, errCheck := checkOrderStatus(cfg.AccrualSystemAddress, order)
-		if errCheck != nil {
-			logFields.WithFields(logrus.Fields{"order": order.Number, "error": errCheck}).Error("failed to check order status")
-
-			if retryAfter := getRetryAfterFromError(errCheck); retryAfter > 0 {
-				logFields.WithFields(logrus.Fields{
-					"order":      order.Number,
-					"retryAfter": retryAfter,
-				}).Info("waiting due to Retry-After")
-				time.Sleep(retryAfter)
-			}
-			continue
+		pending.Add(1)
+		select {
+		case <-ctx.Done():
+			pending.Done()
+			return
+		case jobs <- order:
 		}
-		newStatus = store.GetConstStatus(respData.Status)
-		accrual := 0.0
-		if newStatus == store.Processed {
-			if respData.Accrual == nil {
-				logFields.WithField("order", order.Number).Error("incorrect order accrual")
-				continue
+	}
+}
+
+// keepLeases renews the leases of the batch every third of the lease duration until the returned
+// function is called, so a batch slowed by the limiter or paused by a 429 keeps its orders.
+func (p *Pool) keepLeases(ctx context.Context) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(p.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.renewLeases(ctx)
 			}
-			accrual = *respData.Accrual
 		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (p *Pool) renewLeases(ctx context.Context) {
+	start := time.Now()
+	numbers, err := p.dataStore.RenewOrderLeases(ctx, p.owner, p.lease)
+	if err != nil {
+		// The previous leases are still valid until they expire, holdsLease checks that.
+		logrus.WithFields(logrus.Fields{"action": "W.RenewLeases", "owner": p.owner, "error": err}).Error("failed to renew leases")
+		return
+	}
+	p.setLeases(numbers, start, true)
+}
 
-		if err = dataStore.UpdateOrderStatus(ctx, order.UserID, order.Number, newStatus, accrual); err != nil {
-			logFields.WithFields(logrus.Fields{"order": order.Number, "error": err}).Error("failed to update order status")
-			continue
+// setLeases records the orders leased at start. On renewal only orders that are still leased are kept,
+// an order finished or lost in the meantime is not taken back.
+func (p *Pool) setLeases(numbers []string, start time.Time, renewal bool) {
+	p.leaseMu.Lock()
+	defer p.leaseMu.Unlock()
+
+	leased := make(map[string]struct{}, len(numbers))
+	for _, number := range numbers {
+		if _, ok := p.leased[number]; ok || !renewal {
+			leased[number] = struct{}{}
+		}
+	}
+	p.leased = leased
+	// The lease is counted from before the query, so the local estimate never outlives the stored one.
+	p.leasedUntil = start.Add(p.lease)
+}
+
+// holdsLease reports whether the order is still leased by the pool.
+func (p *Pool) holdsLease(number string) bool {
+	p.leaseMu.Lock()
+	defer p.leaseMu.Unlock()
+
+	_, ok := p.leased[number]
+	return ok && time.Now().Before(p.leasedUntil)
+}
+
+func (p *Pool) processOrder(ctx context.Context, order model.UserOrder) {
+	// Each check starts its own trace, the order number links it to the trace of the upload.
+	ctx, span := tracing.Tracer().Start(ctx, "accrual ProcessOrder",
+		trace.WithAttributes(attribute.String("order.number", order.Number), attribute.String("order.status", order.Status)),
+	)
+	defer span.End()
+
+	logFields := logrus.WithContext(ctx).WithFields(logrus.Fields{"action": "W.ProcessOrder", "order": order.Number})
+
+	if err := p.limiter.Wait(ctx); err != nil {
+		return
+	}
+	// Another replica may own the order if the lease could not be renewed in time.
+	if !p.holdsLease(order.Number) {
+		logFields.Warning("order lease is lost, skip the order")
+		return
+	}
+
+	respData, err := p.check(ctx, order.Number)
+	if err != nil {
+		if retryAfter := getRetryAfterFromError(err); retryAfter > 0 {
+			logFields.WithField("retryAfter", retryAfter).Warning("accrual system is overloaded, pause requests")
+			p.limiter.Pause(retryAfter)
+			return
+		}
+		logFields.WithField("error", err).Error("failed to check order status")
+		return
+	}
+
+	err = ApplyAccrual(ctx, p.dataStore, order.UserID, order.Number, respData)
+	if errors.Is(err, ErrMissingAccrual) {
+		logFields.Error("incorrect order accrual")
+		return
+	}
+	if errors.Is(err, store.ErrInvalidStatusTransition) {
+		logFields.WithField("error", err).Warning("order status is not changed")
+		return
+	}
+	if err != nil {
+		logFields.WithField("error", err).Error("failed to update order status")
+		return
+	}
+
+	logFields.Info("status updated")
+}
+
+// ApplyAccrual stores the result reported by the accrual system for an order of the user.
+// It is shared by polling and the callback endpoint, so both follow the same status rules.
+func ApplyAccrual(ctx context.Context, dataStore StatusStore, userID int64, number string, respData *model.OrderAccrual) error {
+	newStatus := store.GetConstStatus(respData.Status)
+	var accrual money.Amount
+	if newStatus == store.Processed {
+		if respData.Accrual == nil {
+			return ErrMissingAccrual
 		}
+		accrual = *respData.Accrual
+	}
 
-		logFields.WithField("order", order.Number).Info("status updated")
+	if err := dataStore.UpdateOrderStatus(ctx, userID, number, newStatus, accrual); err != nil {
+		return err
+	}
+	if accrual.IsPositive() {
+		metrics.BalanceCredited.Add(accrual.Float64())
 	}
+	return nil
 }
 
-func UpdateStateOrders(ctx context.Context, cfg *config.Config, dataStore OrderStore, wg *sync.WaitGroup) {
+// UpdateStateOrders runs the pool until ctx is cancelled, progress of the pool is reported to heartbeat.
+func UpdateStateOrders(ctx context.Context, cfg *config.Config, dataStore OrderStore, heartbeat *Heartbeat, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	action := "W.UpdateStateOrders"
+	accrualClient := client.NewClient(cfg.AccrualSystemAddress)
+	limiter := NewLimiter(cfg.AccrualRateLimit, cfg.AccrualWorkers)
+	pool := NewPool(newOwnerID(), cfg.AccrualWorkers, dataStore, limiter, accrualClient.GetStatus)
+	pool.heartbeat = heartbeat
+	pool.Run(ctx)
 
-	for {
-		preparedOrders(ctx, cfg, dataStore, action, CheckOrderStatus)
-		time.Sleep(2 * time.Second)
-	}
+	logrus.WithField("action", "W.UpdateStateOrders").Info("accrual workers stopped")
 }
 
-func CheckOrderStatus(url string, order model.UserOrder) (*model.OrderAccrual, error) {
-	accrualClient := client.NewClient(url)
-	return accrualClient.GetStatus(order.Number)
+// newOwnerID identifies the replica in order leases, the random part keeps restarts apart.
+func newOwnerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gophermart"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
 }
 
 func getRetryAfterFromError(err error) time.Duration {
-	var apiErr *APIError
+	var apiErr *client.APIError
 	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
 		return apiErr.RetryAfter
 	}
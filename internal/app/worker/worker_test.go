@@ -1,101 +1,353 @@
 package worker
 
 import (
+	"TimBerk/gophermart/internal/app/client"
 	model "TimBerk/gophermart/internal/app/models/order"
-	"TimBerk/gophermart/internal/app/settings/config"
 	handlerStore "TimBerk/gophermart/internal/app/store"
+	"TimBerk/gophermart/pkg/money"
 	"TimBerk/gophermart/pkg/utils"
 	"context"
 	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockStore struct {
 	mock.Mock
 }
 
-func (m *MockStore) GetOrdersForAccrual(ctx context.Context) ([]model.UserOrder, error) {
-	args := m.Called(ctx)
+func (m *MockStore) ClaimOrdersForAccrual(ctx context.Context, owner string, limit int, lease time.Duration) ([]model.UserOrder, error) {
+	args := m.Called(ctx, owner, limit, lease)
 	if args.Get(0) != nil {
 		return args.Get(0).([]model.UserOrder), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
-func (m *MockStore) UpdateOrderStatus(ctx context.Context, userID int64, order string, status handlerStore.Status, accrual float64) error {
+func (m *MockStore) RenewOrderLeases(ctx context.Context, owner string, lease time.Duration) ([]string, error) {
+	args := m.Called(ctx, owner, lease)
+	if args.Get(0) != nil {
+		return args.Get(0).([]string), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStore) ReleaseOrders(ctx context.Context, owner string) error {
+	args := m.Called(ctx, owner)
+	return args.Error(0)
+}
+
+func (m *MockStore) UpdateOrderStatus(ctx context.Context, userID int64, order string, status handlerStore.Status, accrual money.Amount) error {
 	args := m.Called(ctx, userID, order, status, accrual)
 	return args.Error(0)
 }
 
-func TestPreparedOrders(t *testing.T) {
+func TestProcessOrder(t *testing.T) {
 	mockCtx := context.Background()
-	mockCfg := &config.Config{AccrualSystemAddress: "http://test"}
+	order := model.UserOrder{Number: "123456", UserID: int64(777)}
 
 	tests := []struct {
 		name          string
-		mockSetup     func(*MockStore) func(string, model.UserOrder) (*model.OrderAccrual, error)
-		expectError   bool
+		mockSetup     func(*MockStore)
+		check         CheckFunc
 		expectUpdates int
+		expectPause   bool
 	}{
 		{
 			name: "successful order processing",
-			mockSetup: func(store *MockStore) func(string, model.UserOrder) (*model.OrderAccrual, error) {
-				order := model.UserOrder{
-					Number: "123456",
-					UserID: int64(777),
-				}
-				store.On("GetOrdersForAccrual", mock.Anything).Return([]model.UserOrder{order}, nil)
+			mockSetup: func(store *MockStore) {
 				store.On("UpdateOrderStatus",
 					mock.Anything,
 					int64(777),
 					"123456",
 					handlerStore.Processed,
-					100.0).Return(nil)
-
-				return func(addr string, o model.UserOrder) (*model.OrderAccrual, error) {
-					return &model.OrderAccrual{
-						Status:  "PROCESSED",
-						Accrual: utils.PtrFloat64(100.0),
-					}, nil
-				}
+					money.FromUnits(100)).Return(nil)
+			},
+			check: func(ctx context.Context, number string) (*model.OrderAccrual, error) {
+				return &model.OrderAccrual{
+					Status:  "PROCESSED",
+					Accrual: utils.PtrAmount(money.FromUnits(100)),
+				}, nil
 			},
 			expectUpdates: 1,
 		},
 		{
-			name: "error getting orders",
-			mockSetup: func(store *MockStore) func(string, model.UserOrder) (*model.OrderAccrual, error) {
-				store.On("GetOrdersForAccrual", mock.Anything).Return(nil, errors.New("db error"))
-				return nil
+			name:      "error checking order status",
+			mockSetup: func(*MockStore) {},
+			check: func(ctx context.Context, number string) (*model.OrderAccrual, error) {
+				return nil, errors.New("network error")
 			},
-			expectError: true,
 		},
 		{
-			name: "error checking order status",
-			mockSetup: func(store *MockStore) func(string, model.UserOrder) (*model.OrderAccrual, error) {
-				order := model.UserOrder{Number: "123456", UserID: 777}
-				store.On("GetOrdersForAccrual", mock.Anything).Return([]model.UserOrder{order}, nil)
-
-				return func(addr string, o model.UserOrder) (*model.OrderAccrual, error) {
-					return nil, errors.New("network error")
-				}
+			name:      "processed order without accrual",
+			mockSetup: func(*MockStore) {},
+			check: func(ctx context.Context, number string) (*model.OrderAccrual, error) {
+				return &model.OrderAccrual{Status: "PROCESSED"}, nil
 			},
-			expectError: true,
+		},
+		{
+			name:      "too many requests pauses limiter",
+			mockSetup: func(*MockStore) {},
+			check: func(ctx context.Context, number string) (*model.OrderAccrual, error) {
+				return nil, &client.APIError{Message: "too many requests", RetryAfter: time.Minute, StatusCode: 429}
+			},
+			expectPause: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStore := new(MockStore)
-			checker := tt.mockSetup(mockStore)
+			tt.mockSetup(mockStore)
+			limiter := NewLimiter(0, 1)
+			pool := NewPool("test", 1, mockStore, limiter, tt.check)
+			pool.setLeases([]string{order.Number}, time.Now(), false)
 
-			preparedOrders(mockCtx, mockCfg, mockStore, "test", checker)
+			pool.processOrder(mockCtx, order)
 
-			if tt.expectUpdates > 0 {
-				mockStore.AssertNumberOfCalls(t, "UpdateOrderStatus", tt.expectUpdates)
-			}
+			mockStore.AssertNumberOfCalls(t, "UpdateOrderStatus", tt.expectUpdates)
 			mockStore.AssertExpectations(t)
+			assert.Equal(t, tt.expectPause, limiter.pauseLeft() > 0)
+		})
+	}
+}
+
+func TestPoolRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var orders []model.UserOrder
+	for i := 0; i < 20; i++ {
+		orders = append(orders, model.UserOrder{Number: strconv.Itoa(i), UserID: 777})
+	}
+
+	mockStore := new(MockStore)
+	mockStore.On("ClaimOrdersForAccrual", mock.Anything, "test", claimLimit, leaseDuration).Return(orders, nil).Once()
+	mockStore.On("ClaimOrdersForAccrual", mock.Anything, "test", claimLimit, leaseDuration).Return(nil, nil)
+	mockStore.On("ReleaseOrders", mock.Anything, "test").Return(nil)
+	mockStore.On("UpdateOrderStatus", mock.Anything, int64(777), mock.Anything, handlerStore.Invalid, money.Amount(0)).Return(nil)
+
+	var active, maxActive, processed int32
+	check := func(ctx context.Context, number string) (*model.OrderAccrual, error) {
+		current := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			seen := atomic.LoadInt32(&maxActive)
+			if current <= seen || atomic.CompareAndSwapInt32(&maxActive, seen, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&processed, 1)
+		return &model.OrderAccrual{Status: "INVALID"}, nil
+	}
+
+	pool := NewPool("test", 4, mockStore, NewLimiter(0, 1), check)
+	pool.interval = time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&processed) == 20 }, time.Second, time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pool did not stop after cancellation")
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxActive), int32(4))
+	mockStore.AssertNumberOfCalls(t, "UpdateOrderStatus", 20)
+	assert.NoError(t, pool.heartbeat.Check(time.Second)(ctx), "the pool must report progress")
+}
+
+func TestProcessOrderWithoutLease(t *testing.T) {
+	order := model.UserOrder{Number: "123456", UserID: int64(777)}
+	check := func(ctx context.Context, number string) (*model.OrderAccrual, error) {
+		t.Error("order must not be checked without a lease")
+		return nil, nil
+	}
+
+	tests := []struct {
+		name   string
+		leases func(*Pool)
+	}{
+		{name: "never leased", leases: func(*Pool) {}},
+		{name: "lease expired", leases: func(p *Pool) { p.setLeases([]string{order.Number}, time.Now().Add(-2*p.lease), false) }},
+		{name: "lease lost on renewal", leases: func(p *Pool) {
+			p.setLeases([]string{order.Number}, time.Now(), false)
+			p.setLeases(nil, time.Now(), true)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			pool := NewPool("test", 1, mockStore, NewLimiter(0, 1), check)
+			tt.leases(pool)
+
+			pool.processOrder(context.Background(), order)
+
+			mockStore.AssertNotCalled(t, "UpdateOrderStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 		})
 	}
 }
+
+// TestPoolRenewsLeases runs a batch that outlives the lease: the order waiting behind a slow check
+// stays leased, so another replica can't claim it, and it is checked once.
+func TestPoolRenewsLeases(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dataStore := handlerStore.NewMemoryStore()
+	userID, err := dataStore.AddUser(ctx, "user", "hash")
+	require.NoError(t, err)
+	require.NoError(t, dataStore.AddOrder(ctx, userID, "1"))
+	require.NoError(t, dataStore.AddOrder(ctx, userID, "2"))
+
+	const lease = 30 * time.Millisecond
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var checks sync.Map
+	check := func(ctx context.Context, number string) (*model.OrderAccrual, error) {
+		count, _ := checks.LoadOrStore(number, new(int32))
+		atomic.AddInt32(count.(*int32), 1)
+		if number == "1" {
+			close(started)
+			<-release
+		}
+		return &model.OrderAccrual{Status: "PROCESSED", Accrual: utils.PtrAmount(money.FromUnits(1))}, nil
+	}
+
+	pool := NewPool("replica-1", 1, dataStore, NewLimiter(0, 1), check)
+	pool.interval = time.Millisecond
+	pool.lease = lease
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+	deadline := time.Now().Add(4 * lease)
+	for time.Now().Before(deadline) {
+		claimed, errClaim := dataStore.ClaimOrdersForAccrual(ctx, "replica-2", 10, time.Minute)
+		require.NoError(t, errClaim)
+		require.Empty(t, claimed, "leases of a running batch must not expire")
+		time.Sleep(lease / 3)
+	}
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		current, errBalance := dataStore.GetBalance(ctx, userID)
+		return errBalance == nil && current.Current == money.FromUnits(2)
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	for _, number := range []string{"1", "2"} {
+		count, ok := checks.Load(number)
+		require.True(t, ok, "order %s is not checked", number)
+		assert.Equal(t, int32(1), atomic.LoadInt32(count.(*int32)), "order %s checked more than once", number)
+	}
+}
+
+func TestPoolRunStopsWhilePaused(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mockStore := new(MockStore)
+	mockStore.On("ClaimOrdersForAccrual", mock.Anything, "test", claimLimit, leaseDuration).Return(
+		[]model.UserOrder{{Number: "1", UserID: 777}, {Number: "2", UserID: 777}}, nil)
+	mockStore.On("ReleaseOrders", mock.Anything, "test").Return(nil)
+
+	limiter := NewLimiter(0, 1)
+	limiter.Pause(time.Hour)
+	check := func(ctx context.Context, number string) (*model.OrderAccrual, error) {
+		t.Error("order must not be checked while the limiter is paused")
+		return nil, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		NewPool("test", 2, mockStore, limiter, check).Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pool did not stop after cancellation")
+	}
+}
+
+func TestPoolReplicasShareOrders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dataStore := handlerStore.NewMemoryStore()
+	userID, err := dataStore.AddUser(ctx, "user", "hash")
+	require.NoError(t, err)
+
+	const orders = 50
+	for i := 0; i < orders; i++ {
+		require.NoError(t, dataStore.AddOrder(ctx, userID, strconv.Itoa(i)))
+	}
+
+	var mu sync.Mutex
+	checks := make(map[string]int)
+	checkedBy := make(map[string]int)
+	newCheck := func(owner string) CheckFunc {
+		return func(ctx context.Context, number string) (*model.OrderAccrual, error) {
+			mu.Lock()
+			checks[number]++
+			checkedBy[owner]++
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			return &model.OrderAccrual{Status: "PROCESSED", Accrual: utils.PtrAmount(money.FromUnits(1))}, nil
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, owner := range []string{"replica-1", "replica-2"} {
+		pool := NewPool(owner, 3, dataStore, NewLimiter(0, 1), newCheck(owner))
+		pool.interval = time.Millisecond
+		pool.limit = 5
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.Run(ctx)
+		}()
+	}
+
+	assert.Eventually(t, func() bool {
+		current, errBalance := dataStore.GetBalance(ctx, userID)
+		return errBalance == nil && current.Current == money.FromUnits(orders)
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, checks, orders)
+	for number, count := range checks {
+		assert.Equal(t, 1, count, "order %s checked more than once", number)
+	}
+	assert.Len(t, checkedBy, 2, "both replicas must take part")
+
+	current, err := dataStore.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromUnits(orders), current.Current)
+}
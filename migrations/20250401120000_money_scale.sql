@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders ALTER COLUMN accrual TYPE NUMERIC(20, 2);
+ALTER TABLE balance ALTER COLUMN current TYPE NUMERIC(20, 2);
+ALTER TABLE balance ALTER COLUMN withdrawn TYPE NUMERIC(20, 2);
+ALTER TABLE withdrawals ALTER COLUMN sum TYPE NUMERIC(20, 2);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders ALTER COLUMN accrual TYPE DECIMAL;
+ALTER TABLE balance ALTER COLUMN current TYPE DECIMAL;
+ALTER TABLE balance ALTER COLUMN withdrawn TYPE DECIMAL;
+ALTER TABLE withdrawals ALTER COLUMN sum TYPE DECIMAL;
+-- +goose StatementEnd
@@ -0,0 +1,120 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE ledger_kind AS ENUM (
+    'ACCRUAL',
+    'WITHDRAWAL',
+    'ADJUSTMENT'
+);
+
+CREATE TYPE ledger_account AS ENUM (
+    'accrual',
+    'adjustment',
+    'current',
+    'withdrawn'
+);
+
+-- Every row moves amount from debit_account to credit_account of the same user,
+-- so the sum over all accounts of a user is always zero.
+CREATE TABLE IF NOT EXISTS ledger_entries(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind ledger_kind NOT NULL,
+    debit_account ledger_account NOT NULL,
+    credit_account ledger_account NOT NULL,
+    amount NUMERIC(20, 2) NOT NULL CHECK (amount > 0),
+    order_number BIGINT,
+    comment TEXT NOT NULL DEFAULT '',
+
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (debit_account <> credit_account)
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entries_user_id_id_idx ON ledger_entries (user_id, id DESC);
+
+CREATE OR REPLACE FUNCTION apply_ledger_entry()
+    RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE balance SET
+        current = current
+            + CASE WHEN NEW.credit_account = 'current' THEN NEW.amount ELSE 0 END
+            - CASE WHEN NEW.debit_account = 'current' THEN NEW.amount ELSE 0 END,
+        withdrawn = withdrawn
+            + CASE WHEN NEW.credit_account = 'withdrawn' THEN NEW.amount ELSE 0 END
+            - CASE WHEN NEW.debit_account = 'withdrawn' THEN NEW.amount ELSE 0 END
+    WHERE user_id = NEW.user_id;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION forbid_ledger_change()
+    RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Existing balances are rebuilt from history, the remainder of each account is booked as an opening adjustment.
+INSERT INTO ledger_entries (user_id, kind, debit_account, credit_account, amount, order_number, comment, created_at)
+SELECT user_id, 'ACCRUAL', 'accrual', 'current', accrual, order_number, 'backfill', updated_at
+FROM orders WHERE accrual > 0;
+
+INSERT INTO ledger_entries (user_id, kind, debit_account, credit_account, amount, order_number, comment, created_at)
+SELECT user_id, 'WITHDRAWAL', 'current', 'withdrawn', sum, order_number, 'backfill', created_at
+FROM withdrawals WHERE sum > 0;
+
+WITH computed AS (
+    SELECT b.user_id,
+           COALESCE(b.current, 0) - COALESCE(SUM(
+               CASE WHEN l.credit_account = 'current' THEN l.amount
+                    WHEN l.debit_account = 'current' THEN -l.amount
+                    ELSE 0 END), 0) AS diff
+    FROM balance b LEFT JOIN ledger_entries l ON l.user_id = b.user_id
+    GROUP BY b.user_id, b.current
+)
+INSERT INTO ledger_entries (user_id, kind, debit_account, credit_account, amount, comment)
+SELECT user_id, 'ADJUSTMENT',
+       CASE WHEN diff > 0 THEN 'adjustment'::ledger_account ELSE 'current'::ledger_account END,
+       CASE WHEN diff > 0 THEN 'current'::ledger_account ELSE 'adjustment'::ledger_account END,
+       ABS(diff), 'opening balance'
+FROM computed WHERE diff <> 0;
+
+-- withdrawn is reconciled the same way, so both materialized accounts match the ledger.
+WITH computed AS (
+    SELECT b.user_id,
+           COALESCE(b.withdrawn, 0) - COALESCE(SUM(
+               CASE WHEN l.credit_account = 'withdrawn' THEN l.amount
+                    WHEN l.debit_account = 'withdrawn' THEN -l.amount
+                    ELSE 0 END), 0) AS diff
+    FROM balance b LEFT JOIN ledger_entries l ON l.user_id = b.user_id
+    GROUP BY b.user_id, b.withdrawn
+)
+INSERT INTO ledger_entries (user_id, kind, debit_account, credit_account, amount, comment)
+SELECT user_id, 'ADJUSTMENT',
+       CASE WHEN diff > 0 THEN 'adjustment'::ledger_account ELSE 'withdrawn'::ledger_account END,
+       CASE WHEN diff > 0 THEN 'withdrawn'::ledger_account ELSE 'adjustment'::ledger_account END,
+       ABS(diff), 'opening withdrawn'
+FROM computed WHERE diff <> 0;
+
+CREATE TRIGGER after_ledger_entry_insert
+    AFTER INSERT ON ledger_entries
+    FOR EACH ROW
+EXECUTE FUNCTION apply_ledger_entry();
+
+CREATE TRIGGER before_ledger_entry_change
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW
+EXECUTE FUNCTION forbid_ledger_change();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS before_ledger_entry_change ON ledger_entries;
+DROP TRIGGER IF EXISTS after_ledger_entry_insert ON ledger_entries;
+DROP FUNCTION IF EXISTS forbid_ledger_change;
+DROP FUNCTION IF EXISTS apply_ledger_entry;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TYPE IF EXISTS ledger_account;
+DROP TYPE IF EXISTS ledger_kind;
+-- +goose StatementEnd
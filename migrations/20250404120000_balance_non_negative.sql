@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- NOT VALID keeps the migration applicable when old races already left negative balances,
+-- new writes are checked anyway. Run VALIDATE CONSTRAINT after reconciling such users.
+ALTER TABLE balance ADD CONSTRAINT balance_current_non_negative CHECK (current >= 0) NOT VALID;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE balance DROP CONSTRAINT IF EXISTS balance_current_non_negative;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+    ADD COLUMN locked_by TEXT,
+    ADD COLUMN locked_until TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS orders_pending_idx ON orders (locked_until) WHERE status IN ('NEW', 'PROCESSING');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS orders_pending_idx;
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS locked_until,
+    DROP COLUMN IF EXISTS locked_by;
+-- +goose StatementEnd
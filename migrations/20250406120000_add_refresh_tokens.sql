@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Refresh tokens are stored as SHA-256 hashes. Every rotation adds a row to the same family,
+-- presenting an already used token revokes the whole family.
+CREATE TABLE IF NOT EXISTS refresh_tokens(
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- Revoked access tokens are kept only until they expire.
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+-- +goose StatementEnd
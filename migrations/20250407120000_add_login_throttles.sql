@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Failure counters of login attempts per username and per client address.
+-- A counter starts over when no failure happened within the window.
+CREATE TABLE IF NOT EXISTS login_throttles(
+    scope TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP,
+
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (scope, subject)
+);
+
+-- Audit of failed login attempts, usernames are kept as entered, even unknown ones.
+CREATE TABLE IF NOT EXISTS login_attempts(
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    locked_for INTERVAL,
+
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS login_attempts_username_created_at_idx ON login_attempts (username, created_at DESC);
+CREATE INDEX IF NOT EXISTS login_attempts_ip_created_at_idx ON login_attempts (ip, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS login_throttles;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Deleted users are anonymized instead of removed: withdrawals and the append-only ledger keep referencing them.
+-- Their login is cleared, NULL is not compared by the unique index, so an anonymized row can't collide with a registered name.
+-- Access tokens carry the session generation of the user, revoking all sessions bumps it.
+ALTER TABLE users
+    ALTER COLUMN username DROP NOT NULL,
+    ADD COLUMN deleted_at TIMESTAMP,
+    ADD COLUMN session_generation BIGINT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE users SET username = 'deleted-' || id WHERE username IS NULL;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS session_generation,
+    DROP COLUMN IF EXISTS deleted_at,
+    ALTER COLUMN username SET NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Keyset pagination and monthly totals of user withdrawals, newest first.
+CREATE INDEX IF NOT EXISTS withdrawals_user_id_created_at_id_idx ON withdrawals (user_id, created_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS withdrawals_user_id_created_at_id_idx;
+-- +goose StatementEnd
@@ -0,0 +1,252 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mailru/easyjson/jlexer"
+	"github.com/mailru/easyjson/jwriter"
+)
+
+// Scale is the number of decimal places kept by Amount.
+const Scale = 2
+
+const minorInUnit = 100
+
+var (
+	ErrInvalidAmount = errors.New("invalid money amount")
+	ErrOverflow      = errors.New("money amount overflow")
+	ErrNull          = errors.New("money amount is null")
+)
+
+// Amount is a fixed-point money value stored as an integer number of minor units (hundredths).
+//
+// Values with more than Scale fractional digits are rounded half away from zero,
+// both when parsed from JSON and when scanned from a DECIMAL column.
+type Amount int64
+
+// FromMinor builds Amount from a number of minor units, e.g. FromMinor(1050) is 10.50.
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// FromUnits builds Amount from a whole number of units, e.g. FromUnits(10) is 10.00.
+func FromUnits(units int64) Amount {
+	return Amount(units * minorInUnit)
+}
+
+// Parse reads a decimal string like "729.98", "-1", "1e2" or "0.125" and rounds it to Scale digits.
+func Parse(value string) (Amount, error) {
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	return fromRat(rat)
+}
+
+// MustParse is like Parse but panics on error. It is intended for constants and tests.
+func MustParse(value string) Amount {
+	amount, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return amount
+}
+
+func fromRat(rat *big.Rat) (Amount, error) {
+	scaled := new(big.Rat).Mul(rat, big.NewRat(minorInUnit, 1))
+
+	quo, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	// Round half away from zero: compare the doubled remainder with the denominator.
+	rem.Abs(rem).Lsh(rem, 1)
+	if rem.Cmp(scaled.Denom()) >= 0 {
+		if scaled.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	if !quo.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return Amount(quo.Int64()), nil
+}
+
+// Minor returns the amount as a number of minor units.
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+func (a Amount) IsZero() bool {
+	return a == 0
+}
+
+func (a Amount) IsPositive() bool {
+	return a > 0
+}
+
+func (a Amount) IsNegative() bool {
+	return a < 0
+}
+
+// Add returns a+b and fails instead of silently wrapping around on overflow.
+func (a Amount) Add(b Amount) (Amount, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrOverflow
+	}
+	return a + b, nil
+}
+
+// Sub returns a-b and fails instead of silently wrapping around on overflow.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return a.Add(-b)
+}
+
+// String returns the amount with exactly Scale fractional digits, e.g. "10.50".
+func (a Amount) String() string {
+	sign := ""
+	minor := uint64(a)
+	if a < 0 {
+		sign = "-"
+		minor = uint64(-(a + 1)) + 1
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/minorInUnit, minor%minorInUnit)
+}
+
+// Number returns the shortest decimal representation, e.g. "10.5" or "20",
+// matching the numeric format used by the API specification.
+func (a Amount) Number() string {
+	value := a.String()
+	value = strings.TrimRight(value, "0")
+	return strings.TrimSuffix(value, ".")
+}
+
+// Float64 is lossy and must only be used for presentation or metrics, never for arithmetic.
+func (a Amount) Float64() float64 {
+	value, _ := strconv.ParseFloat(a.String(), 64)
+	return value
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.Number()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, data)
+	}
+	amount, err := Parse(number.String())
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+func (a Amount) MarshalEasyJSON(w *jwriter.Writer) {
+	w.RawString(a.Number())
+}
+
+func (a *Amount) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	number := l.JsonNumber()
+	if !l.Ok() {
+		return
+	}
+	amount, err := Parse(number.String())
+	if err != nil {
+		l.AddError(err)
+		return
+	}
+	*a = amount
+}
+
+func (a *Amount) ScanNumeric(v pgtype.Numeric) error {
+	if !v.Valid {
+		return ErrNull
+	}
+	amount, err := numericToAmount(v)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+func (a Amount) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(a)), Exp: -Scale, Valid: true}, nil
+}
+
+func numericToAmount(v pgtype.Numeric) (Amount, error) {
+	if v.NaN || v.InfinityModifier != pgtype.Finite {
+		return 0, fmt.Errorf("%w: not a finite number", ErrInvalidAmount)
+	}
+	if v.Int == nil {
+		return 0, nil
+	}
+
+	rat := new(big.Rat).SetInt(v.Int)
+	exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(absInt32(v.Exp))), nil)
+	if v.Exp >= 0 {
+		rat.Mul(rat, new(big.Rat).SetInt(exp))
+	} else {
+		rat.Quo(rat, new(big.Rat).SetInt(exp))
+	}
+	return fromRat(rat)
+}
+
+func absInt32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// NullAmount represents an Amount that may be NULL in the database or absent in JSON.
+type NullAmount struct {
+	Amount Amount
+	Valid  bool
+}
+
+func NewNullAmount(amount Amount) NullAmount {
+	return NullAmount{Amount: amount, Valid: true}
+}
+
+// Ptr returns nil for an invalid value, which easyjson omits for `omitempty` fields.
+func (n NullAmount) Ptr() *Amount {
+	if !n.Valid {
+		return nil
+	}
+	amount := n.Amount
+	return &amount
+}
+
+func (n *NullAmount) ScanNumeric(v pgtype.Numeric) error {
+	if !v.Valid {
+		*n = NullAmount{}
+		return nil
+	}
+	amount, err := numericToAmount(v)
+	if err != nil {
+		return err
+	}
+	*n = NewNullAmount(amount)
+	return nil
+}
+
+func (n NullAmount) NumericValue() (pgtype.Numeric, error) {
+	if !n.Valid {
+		return pgtype.Numeric{}, nil
+	}
+	return n.Amount.NumericValue()
+}
@@ -0,0 +1,145 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mailru/easyjson/jlexer"
+	"github.com/mailru/easyjson/jwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected Amount
+		wantErr  bool
+	}{
+		{name: "integer", input: "100", expected: 10000},
+		{name: "one fractional digit", input: "100.5", expected: 10050},
+		{name: "two fractional digits", input: "729.98", expected: 72998},
+		{name: "float drift value", input: "0.30000000000000004", expected: 30},
+		{name: "half rounds up", input: "0.125", expected: 13},
+		{name: "below half rounds down", input: "0.1249", expected: 12},
+		{name: "negative half rounds away from zero", input: "-0.125", expected: -13},
+		{name: "exponent", input: "1.5e2", expected: 15000},
+		{name: "invalid", input: "abc", wantErr: true},
+		{name: "overflow", input: "1e30", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := Parse(tc.input)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		amount Amount
+		str    string
+		number string
+	}{
+		{amount: 0, str: "0.00", number: "0"},
+		{amount: 10050, str: "100.50", number: "100.5"},
+		{amount: 2000, str: "20.00", number: "20"},
+		{amount: 5, str: "0.05", number: "0.05"},
+		{amount: -5025, str: "-50.25", number: "-50.25"},
+		{amount: math.MinInt64, str: "-92233720368547758.08", number: "-92233720368547758.08"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.str, func(t *testing.T) {
+			assert.Equal(t, tc.str, tc.amount.String())
+			assert.Equal(t, tc.number, tc.amount.Number())
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	sum, err := MustParse("0.1").Add(MustParse("0.2"))
+	require.NoError(t, err)
+	assert.Equal(t, MustParse("0.3"), sum)
+
+	diff, err := FromUnits(1).Sub(MustParse("0.99"))
+	require.NoError(t, err)
+	assert.Equal(t, FromMinor(1), diff)
+
+	_, err = Amount(math.MaxInt64).Add(1)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	_, err = Amount(math.MinInt64).Sub(1)
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestJSON(t *testing.T) {
+	type payload struct {
+		Sum     Amount  `json:"sum"`
+		Accrual *Amount `json:"accrual,omitempty"`
+	}
+
+	data, err := json.Marshal(payload{Sum: MustParse("751.1")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sum":751.1}`, string(data))
+
+	var decoded payload
+	require.NoError(t, json.Unmarshal([]byte(`{"sum":0.125,"accrual":500}`), &decoded))
+	assert.Equal(t, FromMinor(13), decoded.Sum)
+	require.NotNil(t, decoded.Accrual)
+	assert.Equal(t, FromUnits(500), *decoded.Accrual)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"sum":"abc"}`), &decoded))
+}
+
+func TestEasyJSON(t *testing.T) {
+	w := jwriter.Writer{}
+	MustParse("100.50").MarshalEasyJSON(&w)
+	assert.Equal(t, "100.5", string(w.Buffer.BuildBytes()))
+
+	var amount Amount
+	l := jlexer.Lexer{Data: []byte("42.424")}
+	amount.UnmarshalEasyJSON(&l)
+	require.NoError(t, l.Error())
+	assert.Equal(t, FromMinor(4242), amount)
+
+	l = jlexer.Lexer{Data: []byte(`"oops"`)}
+	amount.UnmarshalEasyJSON(&l)
+	assert.Error(t, l.Error())
+}
+
+func TestNumeric(t *testing.T) {
+	var amount Amount
+	require.NoError(t, amount.ScanNumeric(pgtype.Numeric{Int: big.NewInt(729985), Exp: -3, Valid: true}))
+	assert.Equal(t, FromMinor(72999), amount)
+
+	require.NoError(t, amount.ScanNumeric(pgtype.Numeric{Int: big.NewInt(12), Exp: 1, Valid: true}))
+	assert.Equal(t, FromUnits(120), amount)
+
+	assert.ErrorIs(t, amount.ScanNumeric(pgtype.Numeric{}), ErrNull)
+	assert.Error(t, amount.ScanNumeric(pgtype.Numeric{NaN: true, Valid: true}))
+
+	value, err := MustParse("-10.05").NumericValue()
+	require.NoError(t, err)
+	assert.Equal(t, int32(-Scale), value.Exp)
+	assert.Equal(t, int64(-1005), value.Int.Int64())
+
+	var nullable NullAmount
+	require.NoError(t, nullable.ScanNumeric(pgtype.Numeric{}))
+	assert.False(t, nullable.Valid)
+	assert.Nil(t, nullable.Ptr())
+
+	require.NoError(t, nullable.ScanNumeric(pgtype.Numeric{Int: big.NewInt(15075), Exp: -2, Valid: true}))
+	require.NotNil(t, nullable.Ptr())
+	assert.Equal(t, MustParse("150.75"), *nullable.Ptr())
+}
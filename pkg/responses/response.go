@@ -2,7 +2,6 @@ package responses
 
 import (
 	"encoding/json"
-	"github.com/sirupsen/logrus"
 	"net/http"
 )
 
@@ -11,19 +10,14 @@ type JSONErrorResponse struct {
 }
 
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 func WriteJSONError(w http.ResponseWriter, message string, statusCode int) {
 	w.WriteHeader(statusCode)
 	errorResponse := JSONErrorResponse{Error: message}
 
-	logrus.WithFields(logrus.Fields{
-		"header":     w.Header(),
-		"message":    message,
-		"statusCode": statusCode,
-	}).Error("JSON Error")
-
 	encoder := json.NewEncoder(w)
 	encoder.SetEscapeHTML(false)
 	encoder.Encode(errorResponse)
@@ -36,9 +30,9 @@ func WriteJSONEmpty(w http.ResponseWriter, statusCode int) {
 	encoder.SetEscapeHTML(false)
 }
 
-func WriteJSONToken(w http.ResponseWriter, token string) {
+func WriteJSONToken(w http.ResponseWriter, token string, refreshToken string) {
 	w.WriteHeader(http.StatusOK)
-	tokenResponse := TokenResponse{Token: token}
+	tokenResponse := TokenResponse{Token: token, RefreshToken: refreshToken}
 
 	encoder := json.NewEncoder(w)
 	encoder.SetEscapeHTML(false)
@@ -0,0 +1,143 @@
+package secure
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+
+	argon2SaltSize = 16
+	argon2KeySize  = 32
+
+	// Upper bounds keep a misconfiguration or a forged stored hash from exhausting the server.
+	Argon2MaxTime   = 64
+	Argon2MaxMemory = 4 << 20
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Argon2Params are argon2id costs, Memory is in KiB.
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// Validate rejects costs argon2.IDKey panics on or that are too expensive to compute per request.
+func (p Argon2Params) Validate() error {
+	if p.Time < 1 || p.Time > Argon2MaxTime {
+		return fmt.Errorf("argon2 time must be between 1 and %d, got %d", Argon2MaxTime, p.Time)
+	}
+	if p.Threads < 1 {
+		return errors.New("argon2 threads must be at least 1")
+	}
+	if p.Memory < 8*uint32(p.Threads) || p.Memory > Argon2MaxMemory {
+		return fmt.Errorf("argon2 memory must be between %d and %d KiB, got %d", 8*uint32(p.Threads), Argon2MaxMemory, p.Memory)
+	}
+	return nil
+}
+
+// PasswordHasher hashes new passwords with the configured algorithm and verifies hashes of
+// every supported algorithm, so stored hashes can be upgraded on login.
+type PasswordHasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// Validate checks the algorithm and the costs new hashes are made with.
+func (h PasswordHasher) Validate() error {
+	switch h.Algorithm {
+	case AlgorithmBcrypt, AlgorithmArgon2id:
+	default:
+		return fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+	}
+	if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, h.BcryptCost)
+	}
+	return h.Argon2.Validate()
+}
+
+func (h PasswordHasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(hash), err
+	case AlgorithmArgon2id:
+		salt := make([]byte, argon2SaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, h.Argon2.Time, h.Argon2.Memory, h.Argon2.Threads, argon2KeySize)
+		return encodeArgon2(h.Argon2, salt, key), nil
+	}
+	return "", fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+}
+
+// Verify checks the password and reports whether the hash should be replaced,
+// because it uses another algorithm or outdated costs.
+func (h PasswordHasher) Verify(password string, hash string) (bool, bool) {
+	if strings.HasPrefix(hash, "$"+AlgorithmArgon2id+"$") {
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, false
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false
+		}
+		return true, h.Algorithm != AlgorithmArgon2id || params != h.Argon2
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false, false
+	}
+	cost, _ := bcrypt.Cost([]byte(hash))
+	return true, h.Algorithm != AlgorithmBcrypt || cost != h.BcryptCost
+}
+
+// encodeArgon2 uses the PHC string format: $argon2id$v=19$m=65536,t=3,p=2$salt$key.
+func encodeArgon2(params Argon2Params, salt []byte, key []byte) string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", AlgorithmArgon2id, argon2.Version,
+		params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if params.Validate() != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,112 @@
+package secure
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2 = Argon2Params{Time: 1, Memory: 64, Threads: 1}
+
+func TestPasswordHasher(t *testing.T) {
+	argonHasher := PasswordHasher{Algorithm: AlgorithmArgon2id, BcryptCost: bcrypt.MinCost, Argon2: testArgon2}
+	bcryptHasher := PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost, Argon2: testArgon2}
+
+	argonHash, err := argonHasher.Hash("secret")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(argonHash, "$argon2id$v=19$m=64,t=1,p=1$"), argonHash)
+
+	bcryptHash, err := bcryptHasher.Hash("secret")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		hasher         PasswordHasher
+		password       string
+		hash           string
+		expectedValid  bool
+		expectedRehash bool
+	}{
+		{name: "argon2id up to date", hasher: argonHasher, password: "secret", hash: argonHash, expectedValid: true},
+		{name: "argon2id wrong password", hasher: argonHasher, password: "wrong", hash: argonHash},
+		{
+			name:           "argon2id outdated costs",
+			hasher:         PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{Time: 2, Memory: 64, Threads: 1}},
+			password:       "secret",
+			hash:           argonHash,
+			expectedValid:  true,
+			expectedRehash: true,
+		},
+		{name: "bcrypt up to date", hasher: bcryptHasher, password: "secret", hash: bcryptHash, expectedValid: true},
+		{name: "bcrypt wrong password", hasher: bcryptHasher, password: "wrong", hash: bcryptHash},
+		{
+			name:           "bcrypt outdated cost",
+			hasher:         PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1},
+			password:       "secret",
+			hash:           bcryptHash,
+			expectedValid:  true,
+			expectedRehash: true,
+		},
+		{name: "bcrypt upgraded to argon2id", hasher: argonHasher, password: "secret", hash: bcryptHash, expectedValid: true, expectedRehash: true},
+		{name: "argon2id downgraded to bcrypt", hasher: bcryptHasher, password: "secret", hash: argonHash, expectedValid: true, expectedRehash: true},
+		{name: "unknown format", hasher: argonHasher, password: "secret", hash: "secret"},
+		{name: "broken argon2id", hasher: argonHasher, password: "secret", hash: "$argon2id$v=19$m=64,t=1,p=1$!!!$!!!"},
+		{name: "argon2id without threads", hasher: argonHasher, password: "secret", hash: strings.Replace(argonHash, "p=1", "p=0", 1)},
+		{name: "argon2id unbounded memory", hasher: argonHasher, password: "secret", hash: strings.Replace(argonHash, "m=64", "m=4294967295", 1)},
+		{name: "argon2id unbounded time", hasher: argonHasher, password: "secret", hash: strings.Replace(argonHash, "t=1", "t=100000", 1)},
+		{name: "other argon2 version", hasher: argonHasher, password: "secret", hash: strings.Replace(argonHash, "v=19", "v=16", 1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, rehash := tc.hasher.Verify(tc.password, tc.hash)
+			assert.Equal(t, tc.expectedValid, valid)
+			assert.Equal(t, tc.expectedRehash, rehash)
+		})
+	}
+
+	_, err = PasswordHasher{Algorithm: "md5"}.Hash("secret")
+	assert.Error(t, err)
+}
+
+func TestPasswordHasherValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		hasher  PasswordHasher
+		wantErr bool
+	}{
+		{name: "argon2id", hasher: PasswordHasher{Algorithm: AlgorithmArgon2id, BcryptCost: bcrypt.DefaultCost, Argon2: testArgon2}},
+		{name: "bcrypt", hasher: PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MaxCost, Argon2: testArgon2}},
+		{name: "unknown algorithm", hasher: PasswordHasher{Algorithm: "md5", BcryptCost: bcrypt.DefaultCost, Argon2: testArgon2}, wantErr: true},
+		{name: "bcrypt cost too low", hasher: PasswordHasher{Algorithm: AlgorithmBcrypt, Argon2: testArgon2}, wantErr: true},
+		{name: "bcrypt cost too high", hasher: PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MaxCost + 1, Argon2: testArgon2}, wantErr: true},
+		{name: "argon2 without threads", hasher: PasswordHasher{Algorithm: AlgorithmArgon2id, BcryptCost: bcrypt.DefaultCost, Argon2: Argon2Params{Time: 1, Memory: 64}}, wantErr: true},
+		{name: "argon2 without time", hasher: PasswordHasher{Algorithm: AlgorithmArgon2id, BcryptCost: bcrypt.DefaultCost, Argon2: Argon2Params{Memory: 64, Threads: 1}}, wantErr: true},
+		{name: "argon2 memory below threads", hasher: PasswordHasher{Algorithm: AlgorithmArgon2id, BcryptCost: bcrypt.DefaultCost, Argon2: Argon2Params{Time: 1, Memory: 8, Threads: 2}}, wantErr: true},
+		{name: "argon2 memory too high", hasher: PasswordHasher{Algorithm: AlgorithmArgon2id, BcryptCost: bcrypt.DefaultCost, Argon2: Argon2Params{Time: 1, Memory: Argon2MaxMemory + 1, Threads: 1}}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.hasher.Validate()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestPasswordHasherSalt(t *testing.T) {
+	hasher := PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2: testArgon2}
+
+	first, err := hasher.Hash("secret")
+	require.NoError(t, err)
+	second, err := hasher.Hash("secret")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
@@ -0,0 +1,89 @@
+package secure
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BcryptMaxLength is the longest password bcrypt can hash.
+const BcryptMaxLength = 72
+
+var ErrWeakPassword = errors.New("password is too weak")
+
+//go:embed common_passwords.txt
+var commonPasswordsData string
+
+var commonPasswords = parseBlocklist(commonPasswordsData)
+
+func parseBlocklist(data string) map[string]struct{} {
+	blocklist := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			blocklist[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return blocklist
+}
+
+// PasswordPolicy describes passwords accepted on registration. MinClasses is the number of
+// character classes required out of lowercase, uppercase, digits and other symbols.
+// MaxLength is in bytes, zero values disable the checks.
+type PasswordPolicy struct {
+	MinLength  int
+	MaxLength  int
+	MinClasses int
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			classes++
+		}
+	}
+	return classes
+}
+
+// Validate returns an error wrapping ErrWeakPassword with a message suitable for the client.
+func (p PasswordPolicy) Validate(username string, password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("%w: it must be at least %d characters long", ErrWeakPassword, p.MinLength)
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		return fmt.Errorf("%w: it must be at most %d bytes long", ErrWeakPassword, p.MaxLength)
+	}
+	if characterClasses(password) < p.MinClasses {
+		return fmt.Errorf("%w: it must contain %d of lowercase letters, uppercase letters, digits and symbols",
+			ErrWeakPassword, p.MinClasses)
+	}
+
+	lowered := strings.ToLower(password)
+	if _, common := commonPasswords[lowered]; common {
+		return fmt.Errorf("%w: it is too common", ErrWeakPassword)
+	}
+	if username != "" && strings.Contains(lowered, strings.ToLower(username)) {
+		return fmt.Errorf("%w: it must not contain the login", ErrWeakPassword)
+	}
+	return nil
+}
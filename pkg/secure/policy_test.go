@@ -0,0 +1,50 @@
+package secure
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MaxLength: BcryptMaxLength, MinClasses: 3}
+
+	testCases := []struct {
+		name     string
+		username string
+		password string
+		expected string
+	}{
+		{name: "strong", username: "user", password: "Tr0ub4dor&3"},
+		{name: "unicode letters", username: "user", password: "Пароль-2025"},
+		{name: "too short", username: "user", password: "Ab1!", expected: "at least 8 characters"},
+		{name: "too long", username: "user", password: "Ab1!" + strings.Repeat("a", BcryptMaxLength), expected: "at most 72 bytes"},
+		{name: "too few classes", username: "user", password: "abcdefgh1", expected: "must contain 3 of"},
+		{name: "common", username: "user", password: "P@ssw0rd", expected: "too common"},
+		{name: "contains login", username: "Gopher", password: "my-gopher-2025", expected: "must not contain the login"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Validate(tc.username, tc.password)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrWeakPassword)
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+
+	assert.NoError(t, PasswordPolicy{}.Validate("user", "x"), "zero policy accepts any password")
+}
+
+func TestCommonPasswordsBlocklist(t *testing.T) {
+	assert.Contains(t, commonPasswords, "qwerty123")
+	assert.NotContains(t, commonPasswords, "", "comments and blank lines are skipped")
+	for password := range commonPasswords {
+		assert.Equal(t, strings.ToLower(password), password)
+		assert.False(t, strings.HasPrefix(password, "#"))
+	}
+}
@@ -51,3 +51,18 @@ func TestCheckLuhn(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateToken(t *testing.T) {
+	first, err := GenerateToken(32)
+	assert.NoError(t, err)
+	second, err := GenerateToken(32)
+	assert.NoError(t, err)
+
+	assert.Len(t, first, 43)
+	assert.NotEqual(t, first, second)
+}
+
+func TestHashToken(t *testing.T) {
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", HashToken("hello"))
+	assert.NotEqual(t, HashToken("hello"), HashToken("hello!"))
+}
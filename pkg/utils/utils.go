@@ -1,5 +1,11 @@
 package utils
 
+import "TimBerk/gophermart/pkg/money"
+
 func PtrFloat64(f float64) *float64 {
 	return &f
 }
+
+func PtrAmount(a money.Amount) *money.Amount {
+	return &a
+}
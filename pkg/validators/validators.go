@@ -31,7 +31,7 @@ func ValidateAuthorization(w http.ResponseWriter, r *http.Request, action string
 	userID, ok := ctx.Value(auth.UserIDKey).(int64)
 	if !ok {
 		errMessage := "User is not authorized"
-		logrus.WithFields(logrus.Fields{"action": action, "error": ok}).Error(errMessage)
+		logrus.WithContext(ctx).WithFields(logrus.Fields{"action": action, "error": ok}).Error(errMessage)
 		responses.WriteJSONError(w, errMessage, http.StatusUnauthorized)
 	}
 	return userID, ok